@@ -0,0 +1,168 @@
+package main
+
+import (
+	"channel-test/internal/consumer/consumertest"
+	"channel-test/internal/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usageError reports invalid flags or arguments
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+// run serves a fake score stream until ctx is done
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("fake-scores", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, `Usage: fake-scores [flags]
+
+Serves a fake upstream score stream as Server-Sent Events on /scores, for
+running scores-api without the live upstream (SSE_URL=http://localhost:8090/scores).
+GET /stats reports what has been served.
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+
+	addr := flags.String("addr", ":8090", "listen address")
+	rate := flags.Float64("rate", 10, "events per second on each connection, 0 for as fast as possible")
+	students := flags.Int("students", 50, "distinct students")
+	exams := flags.Int("exams", 10, "distinct exams")
+	distribution := flags.String("distribution", "normal", "score distribution: normal or uniform")
+	mean := flags.Float64("mean", 0.75, "mean score of the normal distribution")
+	stddev := flags.Float64("stddev", 0.15, "standard deviation of the normal distribution")
+	malformed := flags.Float64("malformed", 0, "fraction of events that are malformed, between 0 and 1")
+	disconnectAfter := flags.Int("disconnect-after", 0, "events per connection before it is cut mid-event, 0 to never disconnect")
+	dripSize := flags.Int("drip-size", 0, "bytes per write, 0 to write whole events")
+	dripDelay := flags.Duration("drip-delay", 0, "pause between drip writes")
+	retry := flags.Duration("retry", 0, "reconnection time sent to clients, 0 to send none")
+	maxEvents := flags.Int64("max-events", 0, "last event ID to send, 0 for unlimited")
+	seed := flags.Uint64("seed", uint64(time.Now().UnixNano()), "seed events are generated from")
+	logLevel := flags.String("log-level", "info", "log level: debug, info, warn or error")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageError{fmt.Sprintf("unexpected argument %q", flags.Arg(0))}
+	}
+
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	logger := logging.New(stdout, level)
+
+	opts := []consumertest.Option{
+		consumertest.WithRate(*rate),
+		consumertest.WithCardinality(*students, *exams),
+		consumertest.WithMalformed(*malformed),
+		consumertest.WithDisconnectAfter(*disconnectAfter),
+		consumertest.WithSlowDrip(*dripSize, *dripDelay),
+		consumertest.WithRetry(*retry),
+		consumertest.WithMaxEvents(*maxEvents),
+		consumertest.WithSeed(*seed),
+	}
+	switch *distribution {
+	case "normal":
+		opts = append(opts, consumertest.WithDistribution(consumertest.Normal{Mean: *mean, StdDev: *stddev}))
+	case "uniform":
+		opts = append(opts, consumertest.WithDistribution(consumertest.Uniform{Min: 0, Max: 1}))
+	default:
+		return usageError{fmt.Sprintf("-distribution must be normal or uniform, got %q", *distribution)}
+	}
+
+	switch {
+	case *rate < 0:
+		return usageError{fmt.Sprintf("-rate must not be negative, got %g", *rate)}
+	case *students < 1 || *exams < 1:
+		return usageError{"-students and -exams must be positive"}
+	case *malformed < 0 || *malformed > 1:
+		return usageError{fmt.Sprintf("-malformed must be between 0 and 1, got %g", *malformed)}
+	case *disconnectAfter < 0 || *dripSize < 0 || *maxEvents < 0:
+		return usageError{"-disconnect-after, -drip-size and -max-events must not be negative"}
+	}
+
+	stream := consumertest.NewStream(opts...)
+
+	mux := http.NewServeMux()
+	mux.Handle("/scores", logConnections(logger, stream))
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stream.Stats())
+	})
+
+	server := &http.Server{
+		Addr:     *addr,
+		Handler:  mux,
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Serving fake score stream", "addr", *addr, "seed", *seed)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Streams never finish on their own, so close them rather than wait
+	server.Close()
+	logger.Info("Fake score stream stopped", "stats", stream.Stats())
+	return nil
+}
+
+// logConnections logs each client connecting to and leaving the stream
+func logConnections(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lastEventID := r.Header.Get("Last-Event-ID")
+		logger.Info("Client connected", "remoteAddr", r.RemoteAddr, "lastEventId", lastEventID)
+
+		next.ServeHTTP(w, r)
+
+		logger.Info("Client disconnected", "remoteAddr", r.RemoteAddr,
+			"durationMs", float64(time.Since(start).Microseconds())/1000)
+	})
+}
@@ -2,10 +2,24 @@ package main
 
 import (
 	"channel-test/internal/api"
+	"channel-test/internal/auth"
+	"channel-test/internal/config"
 	"channel-test/internal/consumer"
+	"channel-test/internal/deadletter"
+	"channel-test/internal/logging"
+	"channel-test/internal/metrics"
+	"channel-test/internal/sources"
 	"channel-test/internal/store"
+	"channel-test/internal/stream"
+	"channel-test/internal/validation"
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,55 +27,159 @@ import (
 	"time"
 )
 
-const (
-	defaultPort     = "8000"
-	sseURL          = "http://live-test-scores.herokuapp.com/scores"
-	shutdownTimeout = 10 * time.Second
-)
-
 func main() {
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	cfg, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	if cfg.PrintConfig {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(cfg)
+		return
+	}
+
+	// Log JSON lines; the level was checked when loading the config
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+
+	// Restored snapshots are held to the configured rules, less those
+	// comparing with earlier scores, which the snapshot carries itself
+	restoreRules, err := newValidator(cfg, nil)
+	if err != nil {
+		fatal(logger, "Failed to load validation rules", err)
 	}
 
 	// Initialize store
-	dataStore := store.NewMemoryStore()
-	log.Println("Initialized in-memory store")
+	dataStore, err := newStore(cfg, restoreRules, logger)
+	if err != nil {
+		fatal(logger, "Failed to initialize store", err)
+	}
+
+	// Rebroadcast stored scores to API clients
+	broadcaster := stream.NewBroadcaster()
+	dataStore.OnScore(broadcaster.Publish)
+
+	// Initialize SSE consumers, one per upstream source
+	backoff := consumer.NewExponentialBackoff()
+	backoff.InitialDelay = cfg.ReconnectInitialDelay.Std()
+	backoff.MaxDelay = cfg.ReconnectMaxDelay.Std()
+	backoff.MaxAttempts = cfg.ReconnectMaxAttempts
+
+	validator, err := newValidator(cfg, dataStore)
+	if err != nil {
+		fatal(logger, "Failed to load validation rules", err)
+	}
+
+	var consumerOpts []consumer.Option
 
-	// Initialize SSE consumer
-	sseConsumer := consumer.NewSSEConsumer(sseURL, dataStore)
+	// Optionally keep the raw upstream events for debugging and replay
+	var recorder *consumer.FileRecorder
+	if cfg.RecordDir != "" {
+		recorder, err = consumer.NewFileRecorder(cfg.RecordDir,
+			consumer.WithSegmentSize(int64(cfg.RecordSegmentSize)<<20),
+			consumer.WithSegmentAge(cfg.RecordSegmentAge.Std()),
+			consumer.WithRecorderLogger(logger),
+		)
+		if err != nil {
+			fatal(logger, "Failed to initialize event recorder", err)
+		}
+		consumerOpts = append(consumerOpts, consumer.WithRecorder(recorder))
+		logger.Info("Recording upstream events", "dir", cfg.RecordDir)
+	}
+
+	// Keep rejected events for investigation on /admin/rejected
+	var deadLetters *deadletter.Queue
+	if cfg.DeadLetterSize > 0 {
+		deadLetters = deadletter.NewQueue(cfg.DeadLetterSize)
+		consumerOpts = append(consumerOpts, consumer.WithDeadLetterQueue(deadLetters))
+	}
+
+	sourceConfig, err := newSources(cfg)
+	if err != nil {
+		fatal(logger, "Failed to load sources", err)
+	}
+	consumers, err := sourceConfig.Build(dataStore, sources.Defaults{
+		CheckpointFile: cfg.CheckpointFile,
+		Backoff:        backoff,
+		Validator:      validator,
+		Logger:         logger,
+	}, consumerOpts...)
+	if err != nil {
+		fatal(logger, "Failed to initialize sources", err)
+	}
+	group := consumer.NewGroup(consumers...)
 
-	// Start SSE consumer in background
+	// Start SSE consumers in background
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	consumerDone := make(chan struct{})
 	go func() {
-		log.Println("Starting SSE consumer...")
-		if err := sseConsumer.Start(ctx); err != nil && err != context.Canceled {
-			log.Printf("SSE consumer error: %v", err)
+		defer close(consumerDone)
+		for _, source := range sourceConfig.Sources {
+			logger.Info("Starting SSE consumer", "source", source.Name, "url", source.URL)
+		}
+		if err := group.Start(ctx); err != nil {
+			logger.Error("SSE consumers stopped", "error", err)
 		}
 	}()
 
+	registerMetrics(dataStore, group, broadcaster, deadLetters)
+
+	handlerOpts := []api.HandlerOption{
+		// The deprecated /status keeps showing the first source for
+		// existing clients; /admin/sources and /readyz cover every source
+		api.WithConsumer(consumers[0]),
+		api.WithSources(group),
+		api.WithBroadcaster(broadcaster),
+		api.WithLogger(logger),
+		api.WithDeadLetterQueue(deadLetters),
+		api.WithValidator(validator),
+		api.WithReadinessThresholds(api.ReadinessThresholds{
+			MaxDisconnected: cfg.ReadyMaxDisconnected.Std(),
+			MaxEventAge:     cfg.ReadyMaxEventAge.Std(),
+		}),
+		api.WithIdempotencyTTL(cfg.IdempotencyTTL.Std()),
+	}
+
+	// Score submission over HTTP is only enabled with API keys
+	if cfg.APIKeysFile != "" {
+		keys, err := auth.LoadKeys(cfg.APIKeysFile)
+		if err != nil {
+			fatal(logger, "Failed to load API keys", err)
+		}
+		handlerOpts = append(handlerOpts, api.WithAPIKeys(keys))
+		logger.Info("Score submission enabled", "apiKeys", keys.Len())
+	}
+
 	// Initialize HTTP handler and router
-	handler := api.NewHandler(dataStore)
+	handler := api.NewHandler(dataStore, handlerOpts...)
 	router := api.NewRouter(handler)
 
 	// Configure HTTP server
 	server := &http.Server{
-		Addr:         ":" + port,
+		Addr:         ":" + cfg.Port,
 		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.ReadTimeout.Std(),
+		WriteTimeout: cfg.WriteTimeout.Std(),
+		IdleTimeout:  cfg.IdleTimeout.Std(),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
+	server.RegisterOnShutdown(broadcaster.Close)
 
 	// Start HTTP server in background
 	go func() {
-		log.Printf("Starting HTTP server on port %s", port)
+		logger.Info("Starting HTTP server", "port", cfg.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+			fatal(logger, "HTTP server error", err)
 		}
 	}()
 
@@ -70,18 +188,139 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server")
 
-	// Cancel SSE consumer
+	// Cancel SSE consumers
 	cancel()
+	<-consumerDone
+
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			logger.Error("Failed to close event recorder", "error", err)
+		}
+	}
 
 	// Gracefully shut down HTTP server
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Std())
 	defer shutdownCancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		logger.Error("Server forced to shutdown", "error", err)
+	}
+
+	if closer, ok := dataStore.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("Failed to close store", "error", err)
+		}
+	}
+
+	logger.Info("Server stopped")
+}
+
+// fatal logs err and exits
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// registerMetrics exposes store size, upstream freshness and stream
+// subscribers, which are read on each scrape
+func registerMetrics(dataStore store.Store, group *consumer.Group, broadcaster *stream.Broadcaster, deadLetters *deadletter.Queue) {
+	metrics.Default.NewGaugeFunc("scores_store_students", "Students in the store.", func() float64 {
+		return float64(dataStore.Stats().Students)
+	})
+	metrics.Default.NewGaugeFunc("scores_store_exams", "Exams in the store.", func() float64 {
+		return float64(dataStore.Stats().Exams)
+	})
+	metrics.Default.NewGaugeFunc("scores_store_scores", "Scores in the store, including rescored attempts.", func() float64 {
+		return float64(dataStore.Stats().Scores)
+	})
+	metrics.Default.NewGaugeFunc("scores_store_duplicates", "Score events the store ignored as already received.", func() float64 {
+		return float64(dataStore.Stats().Duplicates)
+	})
+	metrics.Default.NewGaugeFunc("scores_sse_last_event_age_seconds", "Seconds since an upstream event was last read, from the stalest source.", func() float64 {
+		age := math.NaN()
+		for _, status := range group.Sources() {
+			if status.LastEventAt == nil {
+				return math.NaN()
+			}
+			if since := time.Since(*status.LastEventAt).Seconds(); math.IsNaN(age) || since > age {
+				age = since
+			}
+		}
+		return age
+	})
+	metrics.Default.NewGaugeFunc("scores_stream_subscribers", "Clients subscribed to score streams.", func() float64 {
+		return float64(broadcaster.Subscribers())
+	})
+	if deadLetters != nil {
+		metrics.Default.NewGaugeFunc("scores_deadletter_events", "Rejected score events held for inspection.", func() float64 {
+			return float64(deadLetters.Len())
+		})
+	}
+}
+
+// newValidator loads the configured validation rules, or the defaults
+// when no rules file is set. Rules comparing with earlier scores are left
+// out when history is nil.
+func newValidator(cfg *config.Config, history validation.History) (validation.Validator, error) {
+	if cfg.ValidationRules == "" {
+		return validation.Default(), nil
+	}
+
+	rules, err := validation.LoadConfig(cfg.ValidationRules)
+	if err != nil {
+		return nil, err
+	}
+	return rules.Build(history)
+}
+
+// newSources loads the configured upstream sources, or a single source
+// named "sse" reading sseUrl when no sources file is set
+func newSources(cfg *config.Config) (*sources.Config, error) {
+	if cfg.SourcesFile == "" {
+		return &sources.Config{Sources: []sources.Source{{
+			Name:           "sse",
+			URL:            cfg.SSEURL,
+			CheckpointFile: cfg.CheckpointFile,
+		}}}, nil
 	}
+	return sources.LoadConfig(cfg.SourcesFile)
+}
 
-	log.Println("Server stopped")
+// newStore creates the configured store: "memory", "sharded", which
+// spreads students over lock-striped shards, or "file", which persists to
+// the configured directory
+func newStore(cfg *config.Config, restoreRules validation.Validator, logger *slog.Logger) (store.Store, error) {
+	policy, err := store.ParseScorePolicy(cfg.ScorePolicy)
+	if err != nil {
+		return nil, err
+	}
+	opts := []store.Option{
+		store.WithScorePolicy(policy),
+		store.WithLogger(logger),
+		store.WithDedup(cfg.DedupWindow.Std(), cfg.DedupSize),
+		store.WithRestoreValidator(restoreRules),
+	}
+	if cfg.DedupContent {
+		opts = append(opts, store.WithContentDedup())
+	}
+
+	switch cfg.Store {
+	case "memory":
+		logger.Info("Initialized in-memory store", "scorePolicy", policy)
+		return store.NewMemoryStore(opts...), nil
+	case "sharded":
+		logger.Info("Initialized sharded in-memory store", "shards", cfg.StoreShards, "scorePolicy", policy)
+		return store.NewShardedStore(cfg.StoreShards, opts...), nil
+	case "file":
+		s, err := store.NewFileStore(cfg.StoreDir, opts...)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Initialized file store", "dir", cfg.StoreDir, "scorePolicy", policy)
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown store type %q", cfg.Store)
+	}
 }
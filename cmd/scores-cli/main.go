@@ -0,0 +1,233 @@
+package main
+
+import (
+	"channel-test/internal/logging"
+	"channel-test/internal/replay"
+	"channel-test/internal/store"
+	"channel-test/internal/validation"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const usage = `Usage: scores-cli <command> [flags]
+
+Commands:
+  replay   Replay recorded events into a running instance or a store directory
+
+Run "scores-cli <command> -h" for a command's flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "replay":
+		err = runReplay(ctx, os.Args[2:], os.Stdin, os.Stdout, os.Stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usageError reports invalid flags or arguments
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+// runReplay implements "scores-cli replay [flags] [file ...]"
+func runReplay(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, `Usage: scores-cli replay [flags] [file ...]
+
+Replays recorded score events from text/event-stream captures or NDJSON
+files ("-" or no files reads stdin). Every event goes through the same
+validation as the live stream. Statistics are printed as JSON when done.
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+
+	target := flags.String("target", "", "base URL of a running instance to post scores to, e.g. http://localhost:8080")
+	apiKey := flags.String("api-key", os.Getenv("SCORES_API_KEY"), "API key for -target (env SCORES_API_KEY)")
+	storeDir := flags.String("store-dir", "", "file store directory to write scores to; the instance using it must be stopped")
+	scorePolicy := flags.String("score-policy", string(store.PolicyLatest), "score policy of the file store: latest, best, first or average")
+	dedupWindow := flags.Duration("dedup-window", store.DefaultDedupWindow, "time a score written to the file store is remembered to ignore repeats, 0 to disable")
+	dedupContent := flags.Bool("dedup-content", false, "also ignore scores without an event ID that repeat a recent exam, student and score; drops genuine repeats too")
+	dryRun := flags.Bool("dry-run", false, "validate events and print statistics without storing anything")
+	timing := flags.String("timing", "fast", "fast to replay as quickly as possible, original to keep the recorded spacing")
+	speed := flags.Float64("speed", 1, "speed-up applied to original timing")
+	rate := flags.Float64("rate", 0, "maximum events per second, 0 for unlimited")
+	batchSize := flags.Int("batch-size", 500, "scores sent to the target per request")
+	source := flags.String("source", "replay", "source name recorded with each replayed score")
+	logLevel := flags.String("log-level", "info", "log level: debug, info, warn or error")
+	rulesFile := flags.String("validation-rules", "", "JSON file of score validation rules, empty for scores in [0,1]")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	logger := logging.New(stderr, level)
+
+	var opts []replay.Option
+	switch *timing {
+	case "fast":
+	case "original":
+		if *speed <= 0 {
+			return usageError{fmt.Sprintf("-speed must be positive, got %g", *speed)}
+		}
+		opts = append(opts, replay.WithOriginalTiming(*speed))
+	default:
+		return usageError{fmt.Sprintf("-timing must be fast or original, got %q", *timing)}
+	}
+	if *rate < 0 {
+		return usageError{fmt.Sprintf("-rate must not be negative, got %g", *rate)}
+	}
+	if *batchSize < 1 {
+		return usageError{fmt.Sprintf("-batch-size must be positive, got %d", *batchSize)}
+	}
+	opts = append(opts, replay.WithRate(*rate), replay.WithBatchSize(*batchSize), replay.WithSource(*source))
+
+	if *dedupWindow < 0 {
+		return usageError{fmt.Sprintf("-dedup-window must not be negative, got %s", *dedupWindow)}
+	}
+
+	storeOpts := []store.Option{store.WithDedup(*dedupWindow, store.DefaultDedupSize), store.WithLogger(logger)}
+	if *dedupContent {
+		storeOpts = append(storeOpts, store.WithContentDedup())
+	}
+	sink, history, closeSink, err := newSink(*target, *apiKey, *storeDir, *scorePolicy, storeOpts, *dryRun, logger)
+	if err != nil {
+		return err
+	}
+	defer closeSink()
+
+	if *rulesFile != "" {
+		rules, err := validation.LoadConfig(*rulesFile)
+		if err != nil {
+			return err
+		}
+		validator, err := rules.Build(history)
+		if err != nil {
+			return fmt.Errorf("invalid validation rules:\n%w", err)
+		}
+		opts = append(opts, replay.WithValidator(validator))
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	replayer := replay.NewReplayer(sink, opts...)
+	started := time.Now()
+	for _, name := range files {
+		if err := replayFile(ctx, replayer, name, stdin); err != nil {
+			printStats(stdout, replayer.Stats())
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		logger.Info("Replayed file", "file", name, "events", replayer.Stats().Events)
+	}
+
+	logger.Info("Replay complete", "dryRun", *dryRun, "durationMs", time.Since(started).Milliseconds())
+	printStats(stdout, replayer.Stats())
+	return nil
+}
+
+// newSink picks the replay target from the flags. A store is opened with
+// storeOpts and also returned as the history for validation rules that
+// compare with earlier scores. The returned func releases the sink and
+// must be called when done.
+func newSink(target, apiKey, storeDir, scorePolicy string, storeOpts []store.Option, dryRun bool, logger *slog.Logger) (replay.Sink, validation.History, func(), error) {
+	noop := func() {}
+
+	switch {
+	case target != "" && storeDir != "":
+		return nil, nil, noop, usageError{"-target and -store-dir cannot be used together"}
+	case dryRun:
+		return nil, nil, noop, nil
+	case target != "":
+		client := &http.Client{Timeout: 30 * time.Second}
+		return replay.NewHTTPSink(target, apiKey, client), nil, noop, nil
+	case storeDir != "":
+		policy, err := store.ParseScorePolicy(scorePolicy)
+		if err != nil {
+			return nil, nil, noop, usageError{err.Error()}
+		}
+		s, err := store.NewFileStore(storeDir, append(storeOpts, store.WithScorePolicy(policy))...)
+		if err != nil {
+			return nil, nil, noop, fmt.Errorf("failed to open store: %w", err)
+		}
+		return replay.NewStoreSink(s), s, func() {
+			if err := s.Close(); err != nil {
+				logger.Error("Failed to close store", "error", err)
+			}
+		}, nil
+	default:
+		return nil, nil, noop, usageError{"one of -target, -store-dir or -dry-run is required"}
+	}
+}
+
+// replayFile replays one capture file, or stdin for "-"
+func replayFile(ctx context.Context, replayer *replay.Replayer, name string, stdin io.Reader) error {
+	if name == "-" {
+		return replayer.Replay(ctx, replay.NewReader(stdin))
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return replayer.Replay(ctx, replay.NewReader(f))
+}
+
+func printStats(w io.Writer, stats replay.Stats) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(stats)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"channel-test/internal/loadgen"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usageError reports invalid flags or arguments
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+// run drives load against a running instance and prints the report
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("scores-load", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, `Usage: scores-load [flags]
+
+Sends a mix of score submissions and queries to a running instance from
+concurrent workers, then reports throughput and latency percentiles for
+each kind of request. Interrupting the run reports what was sent so far.
+
+Operations for -mix: ingest, batch, students, student, history, exams,
+exam, examStats, examLeaderboard and leaderboard. Writes need -api-key.
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+
+	target := flags.String("target", "http://localhost:8080", "base URL of the instance to load")
+	apiKey := flags.String("api-key", os.Getenv("SCORES_API_KEY"), "API key for score submissions (env SCORES_API_KEY)")
+	duration := flags.Duration("duration", 30*time.Second, "how long to send requests")
+	concurrency := flags.Int("concurrency", 16, "concurrent workers")
+	rate := flags.Float64("rate", 0, "maximum requests per second across all workers, 0 for unlimited")
+	mixFlag := flags.String("mix", loadgen.DefaultMix, "comma-separated op=weight pairs")
+	batchSize := flags.Int("batch-size", 100, "scores per batch request and per prefill request")
+	students := flags.Int("students", 1000, "distinct students written and queried")
+	exams := flags.Int("exams", 20, "distinct exams written and queried")
+	prefill := flags.Int("prefill", 0, "scores to submit before the run so queries find data")
+	seed := flags.Uint64("seed", uint64(time.Now().UnixNano()), "seed requests are generated from")
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageError{fmt.Sprintf("unexpected argument %q", flags.Arg(0))}
+	}
+
+	mix, err := loadgen.ParseMix(*mixFlag)
+	if err != nil {
+		return usageError{fmt.Sprintf("-mix: %v", err)}
+	}
+	if u, err := url.Parse(*target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return usageError{fmt.Sprintf("-target must be an http or https URL, got %q", *target)}
+	}
+	switch {
+	case *duration <= 0:
+		return usageError{fmt.Sprintf("-duration must be positive, got %s", *duration)}
+	case *concurrency < 1:
+		return usageError{fmt.Sprintf("-concurrency must be positive, got %d", *concurrency)}
+	case *rate < 0:
+		return usageError{fmt.Sprintf("-rate must not be negative, got %g", *rate)}
+	case *batchSize < 1:
+		return usageError{fmt.Sprintf("-batch-size must be positive, got %d", *batchSize)}
+	case *students < 1 || *exams < 1:
+		return usageError{"-students and -exams must be positive"}
+	case *prefill < 0:
+		return usageError{fmt.Sprintf("-prefill must not be negative, got %d", *prefill)}
+	}
+
+	g := loadgen.NewGenerator(*target,
+		loadgen.WithAPIKey(*apiKey),
+		loadgen.WithConcurrency(*concurrency),
+		loadgen.WithRate(*rate),
+		loadgen.WithMix(mix),
+		loadgen.WithBatchSize(*batchSize),
+		loadgen.WithCardinality(*students, *exams),
+		loadgen.WithSeed(*seed),
+	)
+
+	if *prefill > 0 {
+		fmt.Fprintf(stderr, "Prefilling %d scores...\n", *prefill)
+		if err := g.Prefill(ctx, *prefill); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(stderr, "Loading %s for %s with %d workers...\n", *target, *duration, *concurrency)
+	report := g.Run(ctx, *duration)
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(stdout)
+}
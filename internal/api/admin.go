@@ -0,0 +1,148 @@
+package api
+
+import (
+	"channel-test/internal/consumer"
+	"channel-test/internal/deadletter"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// resubmitSource is the source name recorded with resubmitted scores
+const resubmitSource = "resubmit"
+
+// maxResubmitBytes bounds the body of a resubmitted event
+const maxResubmitBytes = 64 << 10
+
+// WithDeadLetterQueue exposes rejected score events on /admin/rejected
+func WithDeadLetterQueue(queue *deadletter.Queue) HandlerOption {
+	return func(h *Handler) {
+		h.deadLetters = queue
+	}
+}
+
+// ListRejected handles GET /admin/rejected
+// Returns rejected score events, newest first.
+// Supports reason, limit and before (an entry ID) for paging.
+func (h *Handler) ListRejected(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.deadLetters == nil {
+		http.Error(w, "Dead-letter queue unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	reason, before, limit, err := parseRejectedQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, more := h.deadLetters.List(reason, before, limit)
+
+	response := map[string]interface{}{
+		"rejected": entries,
+		"count":    len(entries),
+		"total":    h.deadLetters.Len(),
+		"reasons":  h.deadLetters.Counts(),
+		"dropped":  h.deadLetters.Dropped(),
+	}
+	if more {
+		response["nextBefore"] = entries[len(entries)-1].ID
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetRejected handles GET /admin/rejected/{id}
+func (h *Handler) GetRejected(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entry, ok := h.rejectedEntry(w, r)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, entry)
+}
+
+// ResubmitRejected handles POST /admin/rejected/{id}/resubmit
+// The body is the corrected score event; an empty body retries the
+// original payload. Valid events are stored and removed from the queue.
+func (h *Handler) ResubmitRejected(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entry, ok := h.rejectedEntry(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxResubmitBytes))
+	if err != nil {
+		http.Error(w, "Failed to read event", http.StatusBadRequest)
+		return
+	}
+	data := strings.TrimSpace(string(body))
+	if data == "" {
+		data = entry.Data
+	}
+
+	event, rejectErr := consumer.ParseScoreEvent(data, h.validator)
+	if rejectErr != nil {
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":      "Score event rejected",
+			"reason":     rejectErr.Reason,
+			"message":    rejectErr.Message,
+			"violations": rejectErr.Violations,
+		})
+		return
+	}
+	event.Source = resubmitSource
+
+	stored, err := h.store.AddScore(event)
+	if err != nil {
+		h.logger.Error("Failed to store score", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.deadLetters.Remove(entry.ID)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"resubmitted": entry.ID,
+		"score":       event,
+		"duplicate":   !stored,
+	})
+}
+
+// rejectedEntry looks up the entry named by /admin/rejected/{id}[/...],
+// writing an error response if there is none
+func (h *Handler) rejectedEntry(w http.ResponseWriter, r *http.Request) (deadletter.Entry, bool) {
+	if h.deadLetters == nil {
+		http.Error(w, "Dead-letter queue unavailable", http.StatusServiceUnavailable)
+		return deadletter.Entry{}, false
+	}
+
+	parts := strings.Split(extractPathParam(r.URL.Path, "/admin/rejected/"), "/")
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid rejected event ID", http.StatusBadRequest)
+		return deadletter.Entry{}, false
+	}
+
+	entry, ok := h.deadLetters.Get(id)
+	if !ok {
+		http.Error(w, "Rejected event not found", http.StatusNotFound)
+		return deadletter.Entry{}, false
+	}
+	return entry, true
+}
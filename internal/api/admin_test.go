@@ -0,0 +1,203 @@
+package api
+
+import (
+	"channel-test/internal/auth"
+	"channel-test/internal/consumer"
+	"channel-test/internal/deadletter"
+	"channel-test/internal/store"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setupDeadLetters() *deadletter.Queue {
+	q := deadletter.NewQueue(10)
+	q.Add(deadletter.Entry{Reason: consumer.ReasonInvalidJSON, Data: `{"exam":1,`, Source: "sse"})
+	q.Add(deadletter.Entry{Reason: consumer.ReasonScoreOutOfRange, Data: `{"exam":1,"studentId":"alice","score":95}`, Source: "sse"})
+	q.Add(deadletter.Entry{Reason: consumer.ReasonInvalidJSON, Data: `oops`, Source: "sse"})
+	return q
+}
+
+func TestHandler_ListRejected(t *testing.T) {
+	handler := NewHandler(store.NewMemoryStore(), WithDeadLetterQueue(setupDeadLetters()))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/rejected?reason=invalid_json&limit=1", nil)
+	w := httptest.NewRecorder()
+
+	handler.ListRejected(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Rejected   []deadletter.Entry `json:"rejected"`
+		Total      int                `json:"total"`
+		Reasons    map[string]int     `json:"reasons"`
+		NextBefore uint64             `json:"nextBefore"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(resp.Rejected) != 1 || resp.Rejected[0].ID != 3 {
+		t.Errorf("Expected newest invalid_json entry 3, got %+v", resp.Rejected)
+	}
+	if resp.NextBefore != 3 {
+		t.Errorf("Expected nextBefore 3, got %d", resp.NextBefore)
+	}
+	if resp.Total != 3 || resp.Reasons[consumer.ReasonInvalidJSON] != 2 {
+		t.Errorf("Expected 3 entries, 2 invalid_json, got %d and %v", resp.Total, resp.Reasons)
+	}
+}
+
+func TestHandler_ListRejected_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    *Handler
+		url        string
+		expectCode int
+	}{
+		{"no queue", NewHandler(store.NewMemoryStore()), "/admin/rejected", http.StatusServiceUnavailable},
+		{"bad limit", NewHandler(store.NewMemoryStore(), WithDeadLetterQueue(setupDeadLetters())), "/admin/rejected?limit=0", http.StatusBadRequest},
+		{"bad before", NewHandler(store.NewMemoryStore(), WithDeadLetterQueue(setupDeadLetters())), "/admin/rejected?before=x", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		w := httptest.NewRecorder()
+
+		tt.handler.ListRejected(w, req)
+
+		if w.Code != tt.expectCode {
+			t.Errorf("%s: Expected status %d, got %d", tt.name, tt.expectCode, w.Code)
+		}
+	}
+}
+
+func TestHandler_ResubmitRejected(t *testing.T) {
+	s := store.NewMemoryStore()
+	q := setupDeadLetters()
+	handler := NewHandler(s, WithDeadLetterQueue(q))
+
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		expectCode int
+	}{
+		{"unknown entry", "9", `{"exam":1,"studentId":"alice","score":0.95}`, http.StatusNotFound},
+		{"invalid id", "x", ``, http.StatusBadRequest},
+		{"original still invalid", "2", ``, http.StatusUnprocessableEntity},
+		{"correction invalid", "2", `{"exam":1,"studentId":"","score":0.95}`, http.StatusUnprocessableEntity},
+		{"corrected", "2", `{"exam":1,"studentId":"alice","score":0.95}`, http.StatusOK},
+		{"already resubmitted", "2", `{"exam":1,"studentId":"alice","score":0.95}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/admin/rejected/"+tt.id+"/resubmit", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		handler.ResubmitRejected(w, req)
+
+		if w.Code != tt.expectCode {
+			t.Errorf("%s: Expected status %d, got %d", tt.name, tt.expectCode, w.Code)
+		}
+	}
+
+	history, err := s.GetScoreHistory("alice", 1)
+	if err != nil {
+		t.Fatalf("GetScoreHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Score != 0.95 || history[0].Source != "resubmit" {
+		t.Errorf("Expected one resubmitted score, got %+v", history)
+	}
+	if q.Len() != 2 {
+		t.Errorf("Expected 2 entries left, got %d", q.Len())
+	}
+}
+
+const adminKey = "0123456789abcdef"
+
+func setupAdminRouter(t *testing.T, opts ...HandlerOption) http.Handler {
+	t.Helper()
+	keys, err := auth.ParseKeys([]byte("ops: " + adminKey + "\n"))
+	if err != nil {
+		t.Fatalf("ParseKeys failed: %v", err)
+	}
+	return NewRouter(NewHandler(store.NewMemoryStore(), append(opts, WithAPIKeys(keys))...))
+}
+
+func TestRouter_AdminRejected(t *testing.T) {
+	router := setupAdminRouter(t, WithDeadLetterQueue(setupDeadLetters()))
+
+	tests := []struct {
+		method     string
+		path       string
+		expectCode int
+	}{
+		{http.MethodGet, "/admin/rejected", http.StatusOK},
+		{http.MethodGet, "/admin/rejected/1", http.StatusOK},
+		{http.MethodPost, "/admin/rejected/1", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/rejected/1/resubmit", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/sources", http.StatusServiceUnavailable},
+		{http.MethodGet, "/admin/other", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+adminKey)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expectCode {
+			t.Errorf("%s %s: Expected status %d, got %d", tt.method, tt.path, tt.expectCode, w.Code)
+		}
+	}
+}
+
+func TestRouter_AdminAuth(t *testing.T) {
+	queue := setupDeadLetters()
+	router := setupAdminRouter(t, WithDeadLetterQueue(queue), WithSources(setupSources()))
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/admin/rejected"},
+		{http.MethodGet, "/admin/rejected/1"},
+		{http.MethodPost, "/admin/rejected/2/resubmit"},
+		{http.MethodGet, "/admin/sources"},
+	}
+
+	for _, tt := range tests {
+		for _, key := range []string{"", "fedcba9876543210"} {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"exam":1,"studentId":"alice","score":0.95}`))
+			if key != "" {
+				req.Header.Set("Authorization", "Bearer "+key)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with key %q: Expected status 401, got %d", tt.method, tt.path, key, w.Code)
+			}
+		}
+	}
+	if queue.Len() != 3 {
+		t.Errorf("Expected resubmit without a key to leave 3 entries, got %d", queue.Len())
+	}
+
+	// Without configured keys the admin routes are closed
+	router = NewRouter(NewHandler(store.NewMemoryStore(), WithDeadLetterQueue(setupDeadLetters())))
+	req := httptest.NewRequest(http.MethodGet, "/admin/rejected", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without keys, got %d", w.Code)
+	}
+}
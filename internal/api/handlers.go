@@ -1,24 +1,79 @@
 package api
 
 import (
+	"channel-test/internal/auth"
+	"channel-test/internal/consumer"
+	"channel-test/internal/deadletter"
 	"channel-test/internal/store"
+	"channel-test/internal/stream"
+	"channel-test/internal/validation"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
+// StatusProvider reports the state of the score stream consumer
+type StatusProvider interface {
+	Status() consumer.Status
+}
+
 // Handler handles HTTP requests for the scores API
 type Handler struct {
-	store store.Store
+	store       store.Store
+	consumer    StatusProvider
+	sources     SourcesProvider
+	broadcaster *stream.Broadcaster
+	logger      *slog.Logger
+	readiness   ReadinessThresholds
+	deadLetters *deadletter.Queue
+	validator   validation.Validator
+	apiKeys     *auth.Keys
+	idempotency *idempotencyCache
+}
+
+// HandlerOption configures a Handler
+type HandlerOption func(*Handler)
+
+// WithConsumer exposes the consumer's connection state on GET /status. With
+// several sources, WithSources reports all of them.
+func WithConsumer(consumer StatusProvider) HandlerOption {
+	return func(h *Handler) {
+		h.consumer = consumer
+	}
+}
+
+// WithBroadcaster enables the live score streams under /stream/
+func WithBroadcaster(broadcaster *stream.Broadcaster) HandlerOption {
+	return func(h *Handler) {
+		h.broadcaster = broadcaster
+	}
+}
+
+// WithLogger sets the logger request logs are written to. The default
+// is slog.Default().
+func WithLogger(logger *slog.Logger) HandlerOption {
+	return func(h *Handler) {
+		h.logger = logger
+	}
 }
 
 // NewHandler creates a new API handler
-func NewHandler(store store.Store) *Handler {
-	return &Handler{
-		store: store,
+func NewHandler(store store.Store, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		store:       store,
+		logger:      slog.Default(),
+		readiness:   DefaultReadinessThresholds,
+		idempotency: newIdempotencyCache(DefaultIdempotencyTTL),
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 // In internal/api/handlers.go, add:
@@ -28,28 +83,72 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
         "version": "1.0.0",
         "endpoints": []string{
             "GET /health",
+            "GET /livez",
+            "GET /readyz",
+            "GET /status",
+            "POST /scores",
+            "POST /scores/batch",
+            "GET /metrics",
             "GET /students",
             "GET /students/{id}",
+            "GET /students/{id}/exams/{number}/history",
             "GET /exams",
             "GET /exams/{number}",
+            "GET /exams/{number}/stats",
+            "GET /exams/{number}/leaderboard",
+            "GET /leaderboard",
+            "GET /stream/scores",
+            "GET /stream/students/{id}",
+            "GET /stream/exams/{number}",
+            "GET /admin/rejected",
+            "GET /admin/rejected/{id}",
+            "POST /admin/rejected/{id}/resubmit",
+            "GET /admin/sources",
+            "GET /admin/snapshot",
+            "POST /admin/restore",
         },
     })
 }
 
 // ListStudents handles GET /students
-// Returns all students that have received at least one test score
+// Returns a page of students that have received at least one test score.
+// Supports limit, cursor, sort, order, prefix, minAverage, maxAverage and exam.
 func (h *Handler) ListStudents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	students := h.store.GetAllStudents()
+	query, err := parseStudentQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	page, err := h.store.QueryStudents(query)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	students := make([]string, len(page.Students))
+	for i, student := range page.Students {
+		students[i] = student.ID
+	}
+
+	response := map[string]interface{}{
 		"students": students,
 		"count":    len(students),
-	})
+		"total":    page.Total,
+	}
+	if page.NextCursor != "" {
+		response["nextCursor"] = page.NextCursor
+	}
+	respondJSON(w, http.StatusOK, response)
 }
 
 // GetStudent handles GET /students/{id}
@@ -80,20 +179,87 @@ func (h *Handler) GetStudent(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, student)
 }
 
+// GetScoreHistory handles GET /students/{id}/exams/{number}/history
+// Returns every score received for the student on the exam, oldest first
+func (h *Handler) GetScoreHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expect {id}/exams/{number}/history
+	parts := strings.Split(extractPathParam(r.URL.Path, "/students/"), "/")
+	if len(parts) != 4 || parts[0] == "" || parts[1] != "exams" || parts[3] != "history" {
+		h.NotFound(w, r)
+		return
+	}
+
+	number, err := strconv.Atoi(parts[2])
+	if err != nil {
+		http.Error(w, "Invalid exam number", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.store.GetScoreHistory(parts[0], number)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrStudentNotFound):
+			http.Error(w, "Student not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrExamNotFound):
+			http.Error(w, "Exam not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"studentId": parts[0],
+		"exam":      number,
+		"history":   history,
+		"count":     len(history),
+	})
+}
+
 // ListExams handles GET /exams
-// Returns all exams that have been recorded
+// Returns a page of exams that have been recorded.
+// Supports limit, cursor, sort, order, minAverage, maxAverage and student.
 func (h *Handler) ListExams(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	exams := h.store.GetAllExams()
+	query, err := parseExamQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	page, err := h.store.QueryExams(query)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	exams := make([]int, len(page.Exams))
+	for i, exam := range page.Exams {
+		exams[i] = exam.Number
+	}
+
+	response := map[string]interface{}{
 		"exams": exams,
 		"count": len(exams),
-	})
+		"total": page.Total,
+	}
+	if page.NextCursor != "" {
+		response["nextCursor"] = page.NextCursor
+	}
+	respondJSON(w, http.StatusOK, response)
 }
 
 // GetExam handles GET /exams/{number}
@@ -130,6 +296,169 @@ func (h *Handler) GetExam(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, exam)
 }
 
+// GetExamStats handles GET /exams/{number}/stats
+// Returns the distribution of scores on an exam.
+// Supports percentiles (e.g. 10,50,99) and bucketWidth.
+func (h *Handler) GetExamStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expect {number}/stats
+	parts := strings.Split(extractPathParam(r.URL.Path, "/exams/"), "/")
+	if len(parts) != 2 || parts[1] != "stats" {
+		h.NotFound(w, r)
+		return
+	}
+
+	number, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid exam number", http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseExamStatsQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.store.GetExamStats(number, query)
+	if err != nil {
+		if errors.Is(err, store.ErrExamNotFound) {
+			http.Error(w, "Exam not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, store.ErrInvalidStatsQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// GetExamLeaderboard handles GET /exams/{number}/leaderboard
+// Returns the top students on an exam. Supports top (default 10, 0 for
+// every student).
+func (h *Handler) GetExamLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expect {number}/leaderboard
+	parts := strings.Split(extractPathParam(r.URL.Path, "/exams/"), "/")
+	if len(parts) != 2 || parts[1] != "leaderboard" {
+		h.NotFound(w, r)
+		return
+	}
+
+	number, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid exam number", http.StatusBadRequest)
+		return
+	}
+
+	top, _, err := parseLeaderboardQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	leaderboard, err := h.store.GetExamLeaderboard(number, top)
+	if err != nil {
+		if errors.Is(err, store.ErrExamNotFound) {
+			http.Error(w, "Exam not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"exam":        number,
+		"leaderboard": leaderboard,
+		"count":       len(leaderboard),
+	})
+}
+
+// GetLeaderboard handles GET /leaderboard
+// Returns the top students by overall average.
+// Supports top (default 10, 0 for every student) and minExams.
+func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	top, minExams, err := parseLeaderboardQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	leaderboard := h.store.GetLeaderboard(top, minExams)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"leaderboard": leaderboard,
+		"count":       len(leaderboard),
+		"minExams":    minExams,
+	})
+}
+
+// StreamScores handles GET /stream/scores
+// Streams every stored score as Server-Sent Events
+func (h *Handler) StreamScores(w http.ResponseWriter, r *http.Request) {
+	h.serveStream(w, r, stream.AllScores)
+}
+
+// StreamStudent handles GET /stream/students/{id}
+// Streams scores stored for one student as Server-Sent Events
+func (h *Handler) StreamStudent(w http.ResponseWriter, r *http.Request) {
+	id := extractPathParam(r.URL.Path, "/stream/students/")
+	if id == "" {
+		http.Error(w, "Student ID required", http.StatusBadRequest)
+		return
+	}
+
+	h.serveStream(w, r, stream.StudentFilter(id))
+}
+
+// StreamExam handles GET /stream/exams/{number}
+// Streams scores stored for one exam as Server-Sent Events
+func (h *Handler) StreamExam(w http.ResponseWriter, r *http.Request) {
+	numberStr := extractPathParam(r.URL.Path, "/stream/exams/")
+	if numberStr == "" {
+		http.Error(w, "Exam number required", http.StatusBadRequest)
+		return
+	}
+
+	number, err := strconv.Atoi(numberStr)
+	if err != nil {
+		http.Error(w, "Invalid exam number", http.StatusBadRequest)
+		return
+	}
+
+	h.serveStream(w, r, stream.ExamFilter(number))
+}
+
+func (h *Handler) serveStream(w http.ResponseWriter, r *http.Request, filter stream.Filter) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.broadcaster == nil {
+		http.Error(w, "Streaming unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	h.broadcaster.Serve(w, r, filter)
+}
+
 // HealthCheck handles GET /health
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -142,6 +471,28 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Status handles GET /status
+// Returns the SSE consumer's connection state and last seen event ID.
+// Deprecated: it shows a single source, so responses point clients to
+// GET /admin/sources, which shows every source. GET /readyz checks them all
+// without an API key.
+func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</admin/sources>; rel="successor-version"`)
+
+	if h.consumer == nil {
+		http.Error(w, "Consumer status unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, h.consumer.Status())
+}
+
 // respondJSON writes a JSON response
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"channel-test/internal/consumer"
 	"channel-test/internal/store"
+	"channel-test/internal/stream"
 	"channel-test/pkg/models"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -265,3 +269,456 @@ func TestExtractPathParam(t *testing.T) {
 		}
 	}
 }
+
+type fakeStatusProvider struct {
+	status consumer.Status
+}
+
+func (f fakeStatusProvider) Status() consumer.Status {
+	return f.status
+}
+
+func TestHandler_Status(t *testing.T) {
+	provider := fakeStatusProvider{status: consumer.Status{
+		URL:         "http://example.com/scores",
+		Connected:   true,
+		LastEventID: "42",
+	}}
+	handler := NewHandler(setupTestStore(), WithConsumer(provider))
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	w := httptest.NewRecorder()
+
+	handler.Status(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var status consumer.Status
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if status.LastEventID != "42" {
+		t.Errorf("Expected last event ID 42, got %s", status.LastEventID)
+	}
+	if !status.Connected {
+		t.Error("Expected connected status")
+	}
+	if w.Header().Get("Deprecation") != "true" || !strings.Contains(w.Header().Get("Link"), "</admin/sources>") {
+		t.Errorf("Expected a deprecation pointing to /admin/sources, got %v", w.Header())
+	}
+}
+
+func TestHandler_Status_NoConsumer(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	w := httptest.NewRecorder()
+
+	handler.Status(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestHandler_GetScoreHistory(t *testing.T) {
+	s := setupTestStore()
+	s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "alice", Score: 0.95})
+	handler := NewHandler(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/students/alice/exams/1/history", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetScoreHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		History []models.ScoreRecord `json:"history"`
+		Count   int                  `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Count != 2 || len(response.History) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(response.History))
+	}
+	if response.History[0].Score != 0.85 || response.History[1].Score != 0.95 {
+		t.Errorf("Expected scores 0.85 then 0.95, got %.2f then %.2f",
+			response.History[0].Score, response.History[1].Score)
+	}
+}
+
+func TestHandler_GetScoreHistory_Errors(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/students/nonexistent/exams/1/history", http.StatusNotFound},
+		{"/students/charlie/exams/2/history", http.StatusNotFound},
+		{"/students/alice/exams/abc/history", http.StatusBadRequest},
+		{"/students/alice/exams/1/other", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetScoreHistory(w, req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s: Expected status %d, got %d", tt.path, tt.expected, w.Code)
+		}
+	}
+}
+
+func TestHandler_ListStudents_Query(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/students?sort=average&order=desc&limit=2", nil)
+	w := httptest.NewRecorder()
+
+	handler.ListStudents(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Students   []string `json:"students"`
+		Total      int      `json:"total"`
+		NextCursor string   `json:"nextCursor"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Students) != 2 || response.Students[0] != "charlie" || response.Students[1] != "alice" {
+		t.Errorf("Expected [charlie alice], got %v", response.Students)
+	}
+	if response.Total != 3 {
+		t.Errorf("Expected total 3, got %d", response.Total)
+	}
+	if response.NextCursor == "" {
+		t.Fatal("Expected a next cursor")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/students?sort=average&order=desc&limit=2&cursor="+response.NextCursor, nil)
+	w = httptest.NewRecorder()
+
+	handler.ListStudents(w, req)
+
+	response.NextCursor = ""
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Students) != 1 || response.Students[0] != "bob" {
+		t.Errorf("Expected [bob], got %v", response.Students)
+	}
+	if response.NextCursor != "" {
+		t.Errorf("Expected no cursor on the last page, got %q", response.NextCursor)
+	}
+}
+
+func TestHandler_ListStudents_Unpaged(t *testing.T) {
+	s := store.NewMemoryStore()
+	for i := 0; i < 150; i++ {
+		s.AddScore(models.ScoreEvent{Exam: 1, StudentID: fmt.Sprintf("student%03d", i), Score: 0.5})
+	}
+	handler := NewHandler(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/students", nil)
+	w := httptest.NewRecorder()
+
+	handler.ListStudents(w, req)
+
+	var response struct {
+		Students   []string `json:"students"`
+		NextCursor string   `json:"nextCursor"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Without paging parameters every student is listed
+	if len(response.Students) != 150 || response.NextCursor != "" {
+		t.Errorf("Expected all 150 students and no cursor, got %d and %q", len(response.Students), response.NextCursor)
+	}
+}
+
+func TestHandler_ListExams_Query(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/exams?student=charlie", nil)
+	w := httptest.NewRecorder()
+
+	handler.ListExams(w, req)
+
+	var response struct {
+		Exams []int `json:"exams"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Exams) != 1 || response.Exams[0] != 1 {
+		t.Errorf("Expected [1], got %v", response.Exams)
+	}
+}
+
+func TestHandler_ListStudents_BadQuery(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	for _, query := range []string{"limit=0", "limit=abc", "sort=name", "order=up", "minAverage=x", "exam=x", "cursor=bogus"} {
+		req := httptest.NewRequest(http.MethodGet, "/students?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.ListStudents(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHandler_Stream_Unavailable(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/stream/scores", nil)
+	w := httptest.NewRecorder()
+
+	handler.StreamScores(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestHandler_StreamExam_InvalidNumber(t *testing.T) {
+	handler := NewHandler(setupTestStore(), WithBroadcaster(stream.NewBroadcaster()))
+
+	req := httptest.NewRequest(http.MethodGet, "/stream/exams/abc", nil)
+	w := httptest.NewRecorder()
+
+	handler.StreamExam(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandler_GetExamStats(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/exams/1/stats?percentiles=50,p90&bucketWidth=0.5", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetExamStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var stats models.ExamStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if stats.Number != 1 || stats.Count != 3 {
+		t.Errorf("Expected exam 1 with 3 scores, got %+v", stats)
+	}
+	if stats.Median != 0.85 {
+		t.Errorf("Expected median 0.85, got %v", stats.Median)
+	}
+	if _, ok := stats.Percentiles["p90"]; !ok || len(stats.Percentiles) != 2 {
+		t.Errorf("Expected p50 and p90, got %v", stats.Percentiles)
+	}
+	if len(stats.Histogram) != 1 || stats.Histogram[0].Count != 3 {
+		t.Errorf("Expected one bucket holding 3 scores, got %+v", stats.Histogram)
+	}
+}
+
+// Bucket widths are limited by the exam's own scores, so exams on a
+// custom scale get sensible histograms
+func TestHandler_GetExamStats_WideScale(t *testing.T) {
+	s := store.NewMemoryStore()
+	for i, score := range []float64{12, 48, 50, 97} {
+		s.AddScore(models.ScoreEvent{Exam: 1, StudentID: fmt.Sprintf("student%d", i), Score: score})
+	}
+	handler := NewHandler(s)
+
+	tests := []struct {
+		path     string
+		expected int
+		buckets  int
+	}{
+		{"/exams/1/stats?bucketWidth=5", http.StatusOK, 18},
+		{"/exams/1/stats", http.StatusOK, 11},
+		{"/exams/1/stats?bucketWidth=0.1", http.StatusOK, 851},
+		{"/exams/1/stats?bucketWidth=0.01", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetExamStats(w, req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s: Expected status %d, got %d", tt.path, tt.expected, w.Code)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var stats models.ExamStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(stats.Histogram) != tt.buckets {
+			t.Errorf("%s: Expected %d buckets, got %d", tt.path, tt.buckets, len(stats.Histogram))
+		}
+	}
+}
+
+func TestHandler_GetExamStats_Errors(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/exams/999/stats", http.StatusNotFound},
+		{"/exams/abc/stats", http.StatusBadRequest},
+		{"/exams/1/other", http.StatusNotFound},
+		{"/exams/1/stats?percentiles=101", http.StatusBadRequest},
+		{"/exams/1/stats?percentiles=x", http.StatusBadRequest},
+		{"/exams/1/stats?bucketWidth=0", http.StatusBadRequest},
+		{"/exams/1/stats?percentiles=NaN", http.StatusBadRequest},
+		{"/exams/1/stats?percentiles=50,nan", http.StatusBadRequest},
+		{"/exams/1/stats?percentiles=Inf", http.StatusBadRequest},
+		{"/exams/1/stats?percentiles=-Inf", http.StatusBadRequest},
+		{"/exams/1/stats?bucketWidth=NaN", http.StatusBadRequest},
+		{"/exams/1/stats?bucketWidth=Inf", http.StatusBadRequest},
+		{"/exams/1/stats?bucketWidth=+Inf", http.StatusBadRequest},
+		{"/exams/1/stats?bucketWidth=-Inf", http.StatusBadRequest},
+		{"/exams/1/stats?bucketWidth=1e-300", http.StatusBadRequest},
+		{"/exams/1/stats?bucketWidth=1e300", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetExamStats(w, req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s: Expected status %d, got %d", tt.path, tt.expected, w.Code)
+		}
+	}
+}
+
+func TestHandler_GetExamLeaderboard(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/exams/1/leaderboard?top=2", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetExamLeaderboard(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Leaderboard []models.RankedScore `json:"leaderboard"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if len(response.Leaderboard) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(response.Leaderboard))
+	}
+	if response.Leaderboard[0].StudentID != "charlie" || response.Leaderboard[1].StudentID != "alice" {
+		t.Errorf("Expected charlie then alice, got %+v", response.Leaderboard)
+	}
+}
+
+func TestHandler_GetLeaderboard(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/leaderboard?minExams=2", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetLeaderboard(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Leaderboard []models.RankedStudent `json:"leaderboard"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	// charlie has one exam and is excluded
+	if len(response.Leaderboard) != 2 || response.Leaderboard[0].StudentID != "alice" {
+		t.Errorf("Expected alice to lead 2 entries, got %+v", response.Leaderboard)
+	}
+}
+
+func TestHandler_GetExamLeaderboard_Everyone(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/exams/1/leaderboard?top=0", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetExamLeaderboard(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Leaderboard []models.RankedScore `json:"leaderboard"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if len(response.Leaderboard) != 3 {
+		t.Errorf("Expected every student with top=0, got %+v", response.Leaderboard)
+	}
+}
+
+func TestHandler_Leaderboard_BadQuery(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	tests := []struct {
+		path     string
+		handler  http.HandlerFunc
+		expected int
+	}{
+		{"/leaderboard?top=-1", handler.GetLeaderboard, http.StatusBadRequest},
+		{"/leaderboard?top=1001", handler.GetLeaderboard, http.StatusBadRequest},
+		{"/leaderboard?minExams=-1", handler.GetLeaderboard, http.StatusBadRequest},
+		{"/exams/abc/leaderboard", handler.GetExamLeaderboard, http.StatusBadRequest},
+		{"/exams/999/leaderboard", handler.GetExamLeaderboard, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+
+		tt.handler(w, req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s: Expected status %d, got %d", tt.path, tt.expected, w.Code)
+		}
+	}
+}
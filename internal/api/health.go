@@ -0,0 +1,182 @@
+package api
+
+import (
+	"channel-test/internal/consumer"
+	"channel-test/internal/store"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ReadinessThresholds bound how stale ingest may get before GET /readyz
+// reports the service unready. A zero MaxDisconnected fails readiness as
+// soon as the consumer disconnects; a zero MaxEventAge disables that check.
+type ReadinessThresholds struct {
+	// MaxDisconnected is how long the consumer may stay disconnected
+	MaxDisconnected time.Duration
+	// MaxEventAge is how long may pass without a score being stored
+	MaxEventAge time.Duration
+}
+
+// DefaultReadinessThresholds are used unless WithReadinessThresholds is given
+var DefaultReadinessThresholds = ReadinessThresholds{
+	MaxDisconnected: 30 * time.Second,
+	MaxEventAge:     5 * time.Minute,
+}
+
+// WithReadinessThresholds sets the staleness limits checked by GET /readyz
+func WithReadinessThresholds(thresholds ReadinessThresholds) HandlerOption {
+	return func(h *Handler) {
+		h.readiness = thresholds
+	}
+}
+
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+)
+
+// readinessCheck is the outcome of one readiness check
+type readinessCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Livez handles GET /livez
+// Reports that the process is running and serving requests
+func (h *Handler) Livez(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"status": "alive",
+	})
+}
+
+// Readyz handles GET /readyz
+// Returns 200 when every upstream consumer is connected, scores are
+// arriving and the store accepts writes, and 503 with the failing checks
+// otherwise
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checks := h.readinessChecks(time.Now())
+
+	failing := make([]string, 0)
+	for _, check := range checks {
+		if check.Status == checkFail {
+			failing = append(failing, check.Name)
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	if len(failing) > 0 {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	respondJSON(w, code, map[string]interface{}{
+		"status":  status,
+		"checks":  checks,
+		"failing": failing,
+	})
+}
+
+// readinessChecks runs every check that applies to this handler
+func (h *Handler) readinessChecks(now time.Time) []readinessCheck {
+	var checks []readinessCheck
+
+	// With several sources each is checked on its own, named after it
+	statuses := h.sourceStatuses()
+	for _, status := range statuses {
+		suffix := ""
+		if len(statuses) > 1 {
+			suffix = ":" + status.Source
+		}
+
+		check := consumerCheck(status, h.readiness.MaxDisconnected, now)
+		check.Name += suffix
+		checks = append(checks, check)
+		if h.readiness.MaxEventAge > 0 {
+			check = eventAgeCheck(status.LastAcceptedAt, status.StartedAt, h.readiness.MaxEventAge, now)
+			check.Name += suffix
+			checks = append(checks, check)
+		}
+	}
+
+	checks = append(checks, storeCheck(h.store))
+	return checks
+}
+
+// consumerCheck fails once the consumer has been disconnected for longer
+// than maxDisconnected, or at all if maxDisconnected is zero
+func consumerCheck(status consumer.Status, maxDisconnected time.Duration, now time.Time) readinessCheck {
+	check := readinessCheck{Name: "consumer", Status: checkPass}
+
+	if status.Connected {
+		check.Message = "connected"
+		return check
+	}
+
+	since := status.DisconnectedAt
+	if since == nil {
+		since = status.StartedAt
+	}
+	if since == nil {
+		check.Status = checkFail
+		check.Message = "not started"
+		return check
+	}
+
+	outage := now.Sub(*since)
+	check.Message = fmt.Sprintf("disconnected for %s", outage.Round(time.Second))
+	if status.LastError != "" {
+		check.Message += ": " + status.LastError
+	}
+	if maxDisconnected == 0 || outage > maxDisconnected {
+		check.Status = checkFail
+	}
+	return check
+}
+
+// eventAgeCheck fails when no score has been stored for longer than
+// maxAge, counting from startup until the first one arrives
+func eventAgeCheck(lastAccepted, started *time.Time, maxAge time.Duration, now time.Time) readinessCheck {
+	check := readinessCheck{Name: "eventAge", Status: checkPass}
+
+	since := lastAccepted
+	what := "last score stored"
+	if since == nil {
+		since, what = started, "no scores stored since start"
+	}
+	if since == nil {
+		check.Status = checkFail
+		check.Message = "consumer not started"
+		return check
+	}
+
+	age := now.Sub(*since)
+	check.Message = fmt.Sprintf("%s %s ago (limit %s)", what, age.Round(time.Second), maxAge)
+	if age > maxAge {
+		check.Status = checkFail
+	}
+	return check
+}
+
+// storeCheck asks stores that can fail whether they accept writes
+func storeCheck(s store.Store) readinessCheck {
+	check := readinessCheck{Name: "store", Status: checkPass, Message: "ok"}
+
+	if checker, ok := s.(store.HealthChecker); ok {
+		if err := checker.Check(); err != nil {
+			check.Status = checkFail
+			check.Message = err.Error()
+		}
+	}
+	return check
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"channel-test/internal/consumer"
+	"channel-test/internal/store"
+	"channel-test/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type readyzResponse struct {
+	Status  string           `json:"status"`
+	Checks  []readinessCheck `json:"checks"`
+	Failing []string         `json:"failing"`
+}
+
+func ago(d time.Duration) *time.Time {
+	t := time.Now().Add(-d)
+	return &t
+}
+
+func TestHandler_Livez(t *testing.T) {
+	handler := NewHandler(setupTestStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+	w := httptest.NewRecorder()
+
+	handler.Livez(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestHandler_Readyz(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      consumer.Status
+		expectCode  int
+		expectFails []string
+	}{
+		{
+			name:        "connected and fresh",
+			status:      consumer.Status{StartedAt: ago(time.Hour), Connected: true, LastAcceptedAt: ago(time.Second)},
+			expectCode:  http.StatusOK,
+			expectFails: []string{},
+		},
+		{
+			name:        "briefly disconnected",
+			status:      consumer.Status{StartedAt: ago(time.Hour), DisconnectedAt: ago(5 * time.Second), LastAcceptedAt: ago(10 * time.Second)},
+			expectCode:  http.StatusOK,
+			expectFails: []string{},
+		},
+		{
+			name:        "disconnected for an hour",
+			status:      consumer.Status{StartedAt: ago(2 * time.Hour), DisconnectedAt: ago(time.Hour), LastAcceptedAt: ago(time.Hour), LastError: "connection refused"},
+			expectCode:  http.StatusServiceUnavailable,
+			expectFails: []string{"consumer", "eventAge"},
+		},
+		{
+			name:        "never connected",
+			status:      consumer.Status{StartedAt: ago(time.Minute)},
+			expectCode:  http.StatusServiceUnavailable,
+			expectFails: []string{"consumer"},
+		},
+		{
+			name:        "connected but no scores",
+			status:      consumer.Status{StartedAt: ago(time.Hour), Connected: true},
+			expectCode:  http.StatusServiceUnavailable,
+			expectFails: []string{"eventAge"},
+		},
+		{
+			name:        "not started",
+			status:      consumer.Status{},
+			expectCode:  http.StatusServiceUnavailable,
+			expectFails: []string{"consumer", "eventAge"},
+		},
+	}
+
+	for _, tt := range tests {
+		handler := NewHandler(setupTestStore(),
+			WithConsumer(fakeStatusProvider{status: tt.status}),
+			WithReadinessThresholds(ReadinessThresholds{MaxDisconnected: 30 * time.Second, MaxEventAge: 5 * time.Minute}),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		w := httptest.NewRecorder()
+
+		handler.Readyz(w, req)
+
+		if w.Code != tt.expectCode {
+			t.Errorf("%s: Expected status %d, got %d", tt.name, tt.expectCode, w.Code)
+		}
+
+		var resp readyzResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: Failed to decode response: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(resp.Failing, tt.expectFails) {
+			t.Errorf("%s: Expected failing checks %v, got %v", tt.name, tt.expectFails, resp.Failing)
+		}
+		if len(resp.Checks) != 3 {
+			t.Errorf("%s: Expected 3 checks, got %d", tt.name, len(resp.Checks))
+		}
+	}
+}
+
+func TestHandler_Readyz_Thresholds(t *testing.T) {
+	status := consumer.Status{StartedAt: ago(time.Hour), DisconnectedAt: ago(time.Second), LastAcceptedAt: ago(time.Hour)}
+
+	// No grace for disconnects, and no event age check
+	handler := NewHandler(setupTestStore(),
+		WithConsumer(fakeStatusProvider{status: status}),
+		WithReadinessThresholds(ReadinessThresholds{}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	handler.Readyz(w, req)
+
+	var resp readyzResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Failing, []string{"consumer"}) {
+		t.Errorf("Expected only consumer failing, got %v", resp.Failing)
+	}
+	for _, check := range resp.Checks {
+		if check.Name == "eventAge" {
+			t.Error("Expected event age check to be disabled")
+		}
+	}
+}
+
+func TestHandler_Readyz_StoreClosed(t *testing.T) {
+	s, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "alice", Score: 0.5})
+	handler := NewHandler(s)
+
+	for _, expectCode := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		w := httptest.NewRecorder()
+
+		handler.Readyz(w, req)
+
+		if w.Code != expectCode {
+			t.Errorf("Expected status %d, got %d", expectCode, w.Code)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader names a client-chosen key that makes retrying a
+	// POST safe: a repeat with the same key gets the first response back
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// DefaultIdempotencyTTL is how long responses are kept for replay
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	maxIdempotencyKeyLength = 255
+	maxIdempotencyEntries   = 10000
+	// maxIdempotencyBytes bounds the responses kept for replay, as a batch
+	// response can run to megabytes
+	maxIdempotencyBytes = 64 << 20
+)
+
+// WithIdempotencyTTL sets how long responses to requests carrying an
+// Idempotency-Key are kept for replay. The default is DefaultIdempotencyTTL.
+func WithIdempotencyTTL(ttl time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.idempotency = newIdempotencyCache(ttl)
+	}
+}
+
+// idempotencyState is what a request's Idempotency-Key lookup found
+type idempotencyState int
+
+const (
+	idempotencyNew        idempotencyState = iota // first use; run the request
+	idempotencyReplay                             // completed; replay its response
+	idempotencyInProgress                         // the first request is still running
+	idempotencyMismatch                           // the key was used for a different request
+)
+
+// idempotentResponse is the response kept for one Idempotency-Key
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	size        int // bytes of header and body
+	expires     time.Time
+}
+
+// idempotencyExpiry is when the response kept for scope expires
+type idempotencyExpiry struct {
+	scope   string
+	expires time.Time
+}
+
+// idempotencyCache keeps the responses to recent requests by scope, which
+// combines the client, path and Idempotency-Key. It holds at most
+// maxIdempotencyEntries responses of maxBytes in all, forgetting the
+// oldest first.
+type idempotencyCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxBytes int
+	bytes    int
+	entries  map[string]*idempotentResponse
+	order    []idempotencyExpiry // oldest first
+	now      func() time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:      ttl,
+		maxBytes: maxIdempotencyBytes,
+		entries:  make(map[string]*idempotentResponse),
+		now:      time.Now,
+	}
+}
+
+// begin looks up scope. For a new scope it reserves the key, and the
+// caller must call finish with the response.
+func (c *idempotencyCache) begin(scope string, fingerprint [sha256.Size]byte) (*idempotentResponse, idempotencyState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.expire(now)
+
+	if entry, ok := c.entries[scope]; ok {
+		switch {
+		case entry.fingerprint != fingerprint:
+			return nil, idempotencyMismatch
+		case !entry.done:
+			return nil, idempotencyInProgress
+		default:
+			return entry, idempotencyReplay
+		}
+	}
+
+	expires := now.Add(c.ttl)
+	c.entries[scope] = &idempotentResponse{fingerprint: fingerprint, expires: expires}
+	c.order = append(c.order, idempotencyExpiry{scope: scope, expires: expires})
+	return nil, idempotencyNew
+}
+
+// finish keeps the response for scope. Server errors, and responses too
+// large to keep, are forgotten so the request can be retried with the same
+// key.
+func (c *idempotencyCache) finish(scope string, status int, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[scope]
+	if !ok || entry.done {
+		return
+	}
+	size := len(body)
+	for name, values := range header {
+		size += len(name)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	if status >= http.StatusInternalServerError || size > c.maxBytes {
+		delete(c.entries, scope)
+		return
+	}
+	entry.done = true
+	entry.status = status
+	entry.header = header
+	entry.body = body
+	entry.size = size
+	c.bytes += size
+	c.expire(c.now())
+}
+
+// release forgets scope if its request never finished, such as when the
+// handler panicked, so the request can be retried with the same key
+func (c *idempotencyCache) release(scope string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[scope]; ok && !entry.done {
+		delete(c.entries, scope)
+	}
+}
+
+// expire forgets expired responses and, beyond maxIdempotencyEntries or
+// maxBytes, the oldest ones. The caller must hold c.mu.
+func (c *idempotencyCache) expire(now time.Time) {
+	for len(c.order) > 0 {
+		oldest := c.order[0]
+		if now.Before(oldest.expires) && len(c.entries) < maxIdempotencyEntries && c.bytes <= c.maxBytes {
+			break
+		}
+		// A scope retried after a server error has a newer entry
+		if entry, ok := c.entries[oldest.scope]; ok && entry.expires.Equal(oldest.expires) {
+			delete(c.entries, oldest.scope)
+			c.bytes -= entry.size
+		}
+		c.order = c.order[1:]
+	}
+}
+
+// idempotent makes next safe to retry: a request with an Idempotency-Key
+// the same client already used on the same path gets the first response
+// back instead of running again. Bodies are read up to limit, next's own
+// limit, to be fingerprinted.
+func (h *Handler) idempotent(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+			return
+		}
+
+		body, ok := readBody(w, r, limit)
+		if !ok {
+			return
+		}
+
+		scope := clientName(r.Context()) + "\x00" + r.URL.Path + "\x00" + key
+		entry, state := h.idempotency.begin(scope, sha256.Sum256(body))
+		switch state {
+		case idempotencyReplay:
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		case idempotencyInProgress:
+			http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+			return
+		case idempotencyMismatch:
+			http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+			return
+		}
+
+		// A panicking handler never finishes, so release the key for a retry
+		defer h.idempotency.release(scope)
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		recorder := &recordingWriter{header: make(http.Header), status: http.StatusOK}
+		next(recorder, r)
+		h.idempotency.finish(scope, recorder.status, recorder.header, recorder.body.Bytes())
+
+		for name, values := range recorder.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(recorder.status)
+		w.Write(recorder.body.Bytes())
+	}
+}
+
+// recordingWriter holds a response so it can be kept before being sent
+type recordingWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.status = status
+	rw.wroteHeader = true
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.body.Write(p)
+}
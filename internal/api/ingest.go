@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bytes"
+	"channel-test/internal/auth"
+	"channel-test/internal/consumer"
+	"channel-test/internal/logging"
+	"channel-test/internal/validation"
+	"channel-test/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxBatchBytes bounds the size of a POST /scores/batch body
+	maxBatchBytes = 10 << 20
+	// maxScoreBytes bounds the size of a POST /scores body
+	maxScoreBytes = 64 << 10
+	// batchSource is the source name recorded with scores posted to the API
+	batchSource = "api"
+	// reasonFutureReceivedAt rejects a backfilled score received after now
+	reasonFutureReceivedAt = "received_at_in_future"
+)
+
+// Outcomes of ingesting one score
+const (
+	statusAccepted  = "accepted"
+	statusDuplicate = "duplicate"
+	statusRejected  = "rejected"
+)
+
+// WithValidator sets the rules scores posted to the API must pass. The
+// default is validation.Default().
+func WithValidator(validator validation.Validator) HandlerOption {
+	return func(h *Handler) {
+		h.validator = validator
+	}
+}
+
+// WithAPIKeys enables POST /scores, POST /scores/batch and the /admin routes
+// for callers presenting one of keys. Without keys those endpoints are
+// unavailable.
+func WithAPIKeys(keys *auth.Keys) HandlerOption {
+	return func(h *Handler) {
+		h.apiKeys = keys
+	}
+}
+
+// ingestResult is the outcome of ingesting one score
+type ingestResult struct {
+	Status     string                 `json:"status"`
+	Reason     string                 `json:"reason,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Violations []validation.Violation `json:"violations,omitempty"`
+}
+
+// batchResult is the outcome of one item of a batch. Index counts items
+// from 0; Line is the line number of an NDJSON item.
+type batchResult struct {
+	Index int `json:"index"`
+	Line  int `json:"line,omitempty"`
+	ingestResult
+}
+
+// batchItem is one score event read from a batch body
+type batchItem struct {
+	line int
+	data []byte
+}
+
+// PostScore handles POST /scores
+// The body is one score event. It is validated like the upstream stream's
+// events and answered with 201 when stored, 200 when it was already
+// received and 422 when rejected.
+func (h *Handler) PostScore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := readBody(w, r, maxScoreBytes)
+	if !ok {
+		return
+	}
+
+	event, result := h.ingest(r.Context(), bytes.TrimSpace(body))
+
+	status := http.StatusCreated
+	switch {
+	case result.Reason == consumer.ReasonStoreError:
+		status = http.StatusInternalServerError
+	case result.Status == statusRejected:
+		status = http.StatusUnprocessableEntity
+	case result.Status == statusDuplicate:
+		status = http.StatusOK
+	}
+
+	response := struct {
+		ingestResult
+		Score *models.ScoreEvent `json:"score,omitempty"`
+	}{ingestResult: result}
+	if result.Status != statusRejected {
+		response.Score = &event
+	}
+	respondJSON(w, status, response)
+}
+
+// PostScoreBatch handles POST /scores/batch
+// The body is a JSON array of score events or newline-delimited JSON with
+// one event per line. Each item is validated and stored independently and
+// reported in results, without failing the rest of the batch. Like
+// PostScore it writes to the store, so the router only serves it to
+// clients holding an API key.
+func (h *Handler) PostScoreBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Read the whole batch first so a body that is too large or cut off
+	// is refused without storing any of it
+	body, ok := readBody(w, r, maxBatchBytes)
+	if !ok {
+		return
+	}
+
+	items, err := splitBatch(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON array: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	counts := make(map[string]int)
+	results := make([]batchResult, len(items))
+	for i, item := range items {
+		_, result := h.ingest(r.Context(), item.data)
+		counts[result.Status]++
+		results[i] = batchResult{Index: i, Line: item.line, ingestResult: result}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"accepted":   counts[statusAccepted],
+		"duplicates": counts[statusDuplicate],
+		"rejected":   counts[statusRejected],
+		"results":    results,
+	})
+}
+
+// ingest validates and stores one posted score event. A receivedAt field,
+// as sent by scores-cli replay, backfills the score at its recorded time.
+func (h *Handler) ingest(ctx context.Context, data []byte) (models.ScoreEvent, ingestResult) {
+	event, rejectErr := consumer.ParseScoreEvent(string(data), h.validator)
+	if rejectErr != nil {
+		return event, ingestResult{
+			Status:     statusRejected,
+			Reason:     rejectErr.Reason,
+			Message:    rejectErr.Message,
+			Violations: rejectErr.Violations,
+		}
+	}
+	event.Source = batchSource
+
+	var stamp struct {
+		ReceivedAt *time.Time `json:"receivedAt"`
+	}
+	if err := json.Unmarshal(data, &stamp); err != nil {
+		return event, ingestResult{Status: statusRejected, Reason: consumer.ReasonInvalidJSON, Message: err.Error()}
+	}
+	if stamp.ReceivedAt != nil {
+		if stamp.ReceivedAt.After(time.Now()) {
+			return event, ingestResult{Status: statusRejected, Reason: reasonFutureReceivedAt, Message: "receivedAt is in the future"}
+		}
+		event.ReceivedAt = *stamp.ReceivedAt
+	}
+
+	stored, err := h.store.AddScore(event)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to store score", "error", err)
+		return event, ingestResult{Status: statusRejected, Reason: consumer.ReasonStoreError, Message: "failed to store score"}
+	}
+	if !stored {
+		return event, ingestResult{Status: statusDuplicate}
+	}
+	return event, ingestResult{Status: statusAccepted}
+}
+
+// splitBatch reads the items of a batch: the elements of a JSON array, or
+// the non-blank lines of NDJSON
+func splitBatch(body []byte) ([]batchItem, error) {
+	var items []batchItem
+
+	if trimmed := bytes.TrimSpace(body); bytes.HasPrefix(trimmed, []byte("[")) {
+		var array []json.RawMessage
+		if err := json.Unmarshal(trimmed, &array); err != nil {
+			return nil, err
+		}
+		for _, data := range array {
+			items = append(items, batchItem{data: data})
+		}
+		return items, nil
+	}
+
+	for i, data := range bytes.Split(body, []byte("\n")) {
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		items = append(items, batchItem{line: i + 1, data: data})
+	}
+	return items, nil
+}
+
+// readBody reads a request body of up to limit bytes, writing an error
+// response if it can't
+func readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Body larger than %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// clientKey carries the name of the authenticated API client
+type clientKey struct{}
+
+// clientName returns the API client that made the request, if any
+func clientName(ctx context.Context) string {
+	name, _ := ctx.Value(clientKey{}).(string)
+	return name
+}
+
+// authenticate lets through requests carrying a configured API key,
+// recording the client's name in the request's context and logs
+func (h *Handler) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.apiKeys == nil {
+			http.Error(w, "Unavailable: no API keys configured", http.StatusServiceUnavailable)
+			return
+		}
+
+		name, ok := h.apiKeys.Authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scores"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), clientKey{}, name)
+		ctx = logging.WithContext(ctx, logging.FromContext(ctx).With("client", name))
+		next(w, r.WithContext(ctx))
+	}
+}
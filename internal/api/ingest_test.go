@@ -0,0 +1,429 @@
+package api
+
+import (
+	"channel-test/internal/auth"
+	"channel-test/internal/consumer"
+	"channel-test/internal/store"
+	"channel-test/pkg/models"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_PostScoreBatch(t *testing.T) {
+	s := store.NewMemoryStore()
+	handler := NewHandler(s)
+
+	body := strings.Join([]string{
+		`{"exam":1,"studentId":"alice","score":0.9}`,
+		`{"exam":1,"studentId":"bob","score":1.5}`,
+		``,
+		`not json`,
+		`{"exam":2,"studentId":"alice","score":0.7}`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/scores/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.PostScoreBatch(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Accepted int           `json:"accepted"`
+		Rejected int           `json:"rejected"`
+		Results  []batchResult `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.Accepted != 2 || resp.Rejected != 2 {
+		t.Errorf("Expected 2 accepted and 2 rejected, got %d and %d", resp.Accepted, resp.Rejected)
+	}
+	if len(resp.Results) != 4 {
+		t.Fatalf("Expected 4 results, got %+v", resp.Results)
+	}
+	expected := []struct {
+		line   int
+		status string
+		reason string
+	}{
+		{1, statusAccepted, ""},
+		{2, statusRejected, consumer.ReasonScoreOutOfRange},
+		{4, statusRejected, consumer.ReasonInvalidJSON},
+		{5, statusAccepted, ""},
+	}
+	for i, e := range expected {
+		result := resp.Results[i]
+		if result.Index != i || result.Line != e.line || result.Status != e.status || result.Reason != e.reason {
+			t.Errorf("Expected item %d on line %d to be %s %s, got %+v", i, e.line, e.status, e.reason, result)
+		}
+	}
+
+	student, err := s.GetStudent("alice")
+	if err != nil {
+		t.Fatalf("GetStudent failed: %v", err)
+	}
+	if len(student.Scores) != 2 {
+		t.Errorf("Expected 2 scores for alice, got %d", len(student.Scores))
+	}
+}
+
+func TestHandler_PostScoreBatch_ReceivedAt(t *testing.T) {
+	s := store.NewMemoryStore()
+	handler := NewHandler(s)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	body := strings.Join([]string{
+		`{"exam":1,"studentId":"alice","score":0.9,"receivedAt":"2024-01-02T15:00:00Z"}`,
+		`{"exam":2,"studentId":"alice","score":0.8,"receivedAt":"` + future + `"}`,
+		`{"exam":3,"studentId":"alice","score":0.7,"receivedAt":"yesterday"}`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/scores/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.PostScoreBatch(w, req)
+
+	var resp struct {
+		Accepted int           `json:"accepted"`
+		Results  []batchResult `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Accepted != 1 || len(resp.Results) != 3 {
+		t.Fatalf("Expected 1 of 3 accepted, got %+v", resp)
+	}
+	if resp.Results[1].Reason != reasonFutureReceivedAt || resp.Results[2].Reason != consumer.ReasonInvalidJSON {
+		t.Errorf("Expected future and invalid receivedAt to be rejected, got %+v", resp.Results)
+	}
+
+	history, err := s.GetScoreHistory("alice", 1)
+	if err != nil {
+		t.Fatalf("GetScoreHistory failed: %v", err)
+	}
+	if expected := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC); !history[0].ReceivedAt.Equal(expected) {
+		t.Errorf("Expected receivedAt %s, got %s", expected, history[0].ReceivedAt)
+	}
+}
+
+func TestHandler_PostScoreBatch_Duplicates(t *testing.T) {
+	s := store.NewMemoryStore(store.WithDedup(time.Minute, 100), store.WithContentDedup())
+	handler := NewHandler(s)
+
+	body := strings.Join([]string{
+		`{"id":"e1","exam":1,"studentId":"alice","score":0.9}`,
+		`{"id":"e1","exam":1,"studentId":"alice","score":0.9}`,
+		`{"exam":2,"studentId":"alice","score":0.7}`,
+		`{"exam":2,"studentId":"alice","score":0.7}`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/scores/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.PostScoreBatch(w, req)
+
+	var resp struct {
+		Accepted   int `json:"accepted"`
+		Duplicates int `json:"duplicates"`
+		Rejected   int `json:"rejected"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.Accepted != 2 || resp.Duplicates != 2 || resp.Rejected != 0 {
+		t.Errorf("Expected 2 accepted and 2 duplicates, got %+v", resp)
+	}
+}
+
+func TestHandler_PostScoreBatch_JSONArray(t *testing.T) {
+	s := store.NewMemoryStore()
+	handler := NewHandler(s)
+
+	body := `[
+		{"exam":1,"studentId":"alice","score":0.9},
+		{"exam":1,"score":0.5}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/scores/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.PostScoreBatch(w, req)
+
+	var resp struct {
+		Accepted int           `json:"accepted"`
+		Rejected int           `json:"rejected"`
+		Results  []batchResult `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.Accepted != 1 || resp.Rejected != 1 || len(resp.Results) != 2 {
+		t.Fatalf("Expected 1 accepted and 1 rejected, got %+v", resp)
+	}
+	if resp.Results[1].Index != 1 || resp.Results[1].Line != 0 || resp.Results[1].Reason != consumer.ReasonMissingStudentID {
+		t.Errorf("Expected item 1 rejected for a missing student ID, got %+v", resp.Results[1])
+	}
+
+	// A malformed array is refused as a whole
+	req = httptest.NewRequest(http.MethodPost, "/scores/batch", strings.NewReader(`[{"exam":1}`))
+	w = httptest.NewRecorder()
+	handler.PostScoreBatch(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a malformed array, got %d", w.Code)
+	}
+}
+
+func TestHandler_PostScore(t *testing.T) {
+	s := store.NewMemoryStore(store.WithDedup(time.Minute, 100))
+	handler := NewHandler(s)
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+		status   string
+	}{
+		{"valid", `{"id":"e1","exam":1,"studentId":"alice","score":0.9}`, http.StatusCreated, statusAccepted},
+		{"retried", `{"id":"e1","exam":1,"studentId":"alice","score":0.9}`, http.StatusOK, statusDuplicate},
+		{"out of range", `{"exam":1,"studentId":"alice","score":9}`, http.StatusUnprocessableEntity, statusRejected},
+		{"invalid JSON", `{"exam":`, http.StatusUnprocessableEntity, statusRejected},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/scores", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		handler.PostScore(w, req)
+
+		var resp struct {
+			Status string             `json:"status"`
+			Score  *models.ScoreEvent `json:"score"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+
+		if w.Code != tt.expected || resp.Status != tt.status {
+			t.Errorf("%s: Expected %d %s, got %d %s", tt.name, tt.expected, tt.status, w.Code, resp.Status)
+		}
+		if (resp.Score != nil) != (tt.status != statusRejected) {
+			t.Errorf("%s: Expected the score only when not rejected, got %+v", tt.name, resp.Score)
+		}
+	}
+
+	history, _ := s.GetScoreHistory("alice", 1)
+	if len(history) != 1 || history[0].Source != batchSource {
+		t.Errorf("Expected one score from the API, got %+v", history)
+	}
+}
+
+func TestRouter_ScoreSubmissionAuth(t *testing.T) {
+	keys, err := auth.ParseKeys([]byte("gradebook: 0123456789abcdef\n"))
+	if err != nil {
+		t.Fatalf("ParseKeys failed: %v", err)
+	}
+	body := `{"exam":1,"studentId":"alice","score":0.9}`
+
+	tests := []struct {
+		name     string
+		path     string
+		opts     []HandlerOption
+		key      string
+		expected int
+	}{
+		{"no keys configured", "/scores", nil, "0123456789abcdef", http.StatusServiceUnavailable},
+		{"missing key", "/scores", []HandlerOption{WithAPIKeys(keys)}, "", http.StatusUnauthorized},
+		{"wrong key", "/scores", []HandlerOption{WithAPIKeys(keys)}, "fedcba9876543210", http.StatusUnauthorized},
+		{"valid key", "/scores", []HandlerOption{WithAPIKeys(keys)}, "0123456789abcdef", http.StatusCreated},
+		{"batch no keys configured", "/scores/batch", nil, "0123456789abcdef", http.StatusServiceUnavailable},
+		{"batch missing key", "/scores/batch", []HandlerOption{WithAPIKeys(keys)}, "", http.StatusUnauthorized},
+		{"batch wrong key", "/scores/batch", []HandlerOption{WithAPIKeys(keys)}, "fedcba9876543210", http.StatusUnauthorized},
+		{"batch valid key", "/scores/batch", []HandlerOption{WithAPIKeys(keys)}, "0123456789abcdef", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		s := store.NewMemoryStore()
+		router := NewRouter(NewHandler(s, tt.opts...))
+
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(body))
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s: Expected status %d, got %d", tt.name, tt.expected, w.Code)
+		}
+		// Nothing is written without a valid key
+		if stored := len(s.GetAllStudents()) > 0; stored != (w.Code < 300) {
+			t.Errorf("%s: Expected stored to be %v, got %v", tt.name, w.Code < 300, stored)
+		}
+	}
+}
+
+func TestRouter_IdempotencyKey(t *testing.T) {
+	keys, _ := auth.ParseKeys([]byte("gradebook: 0123456789abcdef\nexams: fedcba9876543210\n"))
+	s := store.NewMemoryStore()
+	router := NewRouter(NewHandler(s, WithAPIKeys(keys)))
+
+	post := func(apiKey, idempotencyKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/scores/batch", strings.NewReader(body))
+		req.Header.Set(auth.KeyHeader, apiKey)
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	batch := `[{"exam":1,"studentId":"alice","score":0.9},{"exam":2,"studentId":"alice","score":0.8}]`
+
+	first := post("0123456789abcdef", "upload-1", batch)
+	retry := post("0123456789abcdef", "upload-1", batch)
+
+	if first.Code != http.StatusOK || retry.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 twice, got %d and %d", first.Code, retry.Code)
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first response to be replayed, got %s", retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected replayed response to be marked")
+	}
+	if stats := s.Stats(); stats.Scores != 2 {
+		t.Errorf("Expected the batch to be stored once, got %d scores", stats.Scores)
+	}
+
+	if w := post("0123456789abcdef", "upload-1", `[{"exam":3,"studentId":"alice","score":0.9}]`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a reused key, got %d", w.Code)
+	}
+
+	// Keys are scoped to the client
+	if w := post("fedcba9876543210", "upload-1", batch); w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("Expected another client's key not to be replayed")
+	}
+	if stats := s.Stats(); stats.Scores != 4 {
+		t.Errorf("Expected 4 scores, got %d", stats.Scores)
+	}
+}
+
+func TestIdempotencyCache_Expiry(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	cache := newIdempotencyCache(time.Hour)
+	cache.now = func() time.Time { return now }
+	fingerprint := sha256.Sum256([]byte("body"))
+
+	if _, state := cache.begin("a", fingerprint); state != idempotencyNew {
+		t.Fatalf("Expected a new key, got %v", state)
+	}
+	if _, state := cache.begin("a", fingerprint); state != idempotencyInProgress {
+		t.Errorf("Expected the key to be in progress, got %v", state)
+	}
+
+	// Server errors are forgotten so the request can be retried
+	cache.finish("a", http.StatusInternalServerError, nil, nil)
+	if _, state := cache.begin("a", fingerprint); state != idempotencyNew {
+		t.Errorf("Expected a retry after a server error, got %v", state)
+	}
+	cache.finish("a", http.StatusOK, nil, []byte("ok"))
+	if entry, state := cache.begin("a", fingerprint); state != idempotencyReplay || string(entry.body) != "ok" {
+		t.Errorf("Expected the response to be replayed, got %v", state)
+	}
+
+	now = now.Add(time.Hour)
+	if _, state := cache.begin("a", fingerprint); state != idempotencyNew {
+		t.Errorf("Expected the key to expire, got %v", state)
+	}
+}
+
+func TestIdempotencyCache_ByteLimit(t *testing.T) {
+	cache := newIdempotencyCache(time.Hour)
+	cache.maxBytes = 10
+	fingerprint := sha256.Sum256([]byte("body"))
+
+	cache.begin("a", fingerprint)
+	cache.finish("a", http.StatusOK, nil, []byte("first"))
+	cache.begin("b", fingerprint)
+	cache.finish("b", http.StatusOK, nil, []byte("second"))
+
+	// Keeping both would hold 11 bytes, so the oldest goes
+	if _, state := cache.begin("a", fingerprint); state != idempotencyNew {
+		t.Errorf("Expected the oldest response to be forgotten, got %v", state)
+	}
+	if _, state := cache.begin("b", fingerprint); state != idempotencyReplay {
+		t.Errorf("Expected the newest response to be kept, got %v", state)
+	}
+
+	cache.begin("c", fingerprint)
+	cache.finish("c", http.StatusOK, nil, []byte("much too large"))
+	if _, state := cache.begin("c", fingerprint); state != idempotencyNew {
+		t.Errorf("Expected a response over the limit not to be kept, got %v", state)
+	}
+}
+
+func TestIdempotent_ReleasesKeyOnPanic(t *testing.T) {
+	handler := NewHandler(store.NewMemoryStore())
+	panics := true
+	next := handler.idempotent(maxScoreBytes, func(w http.ResponseWriter, r *http.Request) {
+		if panics {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/scores", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "retry-me")
+		w := httptest.NewRecorder()
+		next(w, req)
+		return w
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected the handler to panic")
+			}
+		}()
+		post()
+	}()
+
+	panics = false
+	if w := post(); w.Code != http.StatusCreated {
+		t.Errorf("Expected the retry to run, got status %d", w.Code)
+	}
+}
+
+func TestRouter_IdempotencyKey_ScoreBodyLimit(t *testing.T) {
+	keys, _ := auth.ParseKeys([]byte("gradebook: 0123456789abcdef\n"))
+	router := NewRouter(NewHandler(store.NewMemoryStore(), WithAPIKeys(keys)))
+
+	body := `{"exam":1,"studentId":"alice","score":0.9,"padding":"` + strings.Repeat("x", maxScoreBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/scores", strings.NewReader(body))
+	req.Header.Set(auth.KeyHeader, "0123456789abcdef")
+	req.Header.Set(IdempotencyKeyHeader, "large")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for a score over %d bytes, got %d", maxScoreBytes, w.Code)
+	}
+}
+
+func TestHandler_PostScoreBatch_MethodNotAllowed(t *testing.T) {
+	handler := NewHandler(store.NewMemoryStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/scores/batch", nil)
+	w := httptest.NewRecorder()
+
+	handler.PostScoreBatch(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
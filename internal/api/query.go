@@ -0,0 +1,209 @@
+package api
+
+import (
+	"channel-test/internal/store"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	defaultLeaderboardSize = 10
+)
+
+// parseStudentQuery builds a store query from GET /students parameters:
+// limit, cursor, sort (id|average|exams), order (asc|desc), prefix,
+// minAverage, maxAverage and exam
+func parseStudentQuery(values url.Values) (store.StudentQuery, error) {
+	var q store.StudentQuery
+	var err error
+
+	if q.Limit, err = parseListLimit(values); err != nil {
+		return q, err
+	}
+	if q.Descending, err = parseOrder(values); err != nil {
+		return q, err
+	}
+	if q.MinAverage, err = parseOptionalFloat(values, "minAverage"); err != nil {
+		return q, err
+	}
+	if q.MaxAverage, err = parseOptionalFloat(values, "maxAverage"); err != nil {
+		return q, err
+	}
+	if q.Exam, err = parseOptionalInt(values, "exam"); err != nil {
+		return q, err
+	}
+
+	if sortBy := values.Get("sort"); sortBy != "" {
+		if q.SortBy, err = store.ParseStudentSort(sortBy); err != nil {
+			return q, err
+		}
+	}
+
+	q.Prefix = values.Get("prefix")
+	q.Cursor = values.Get("cursor")
+	return q, nil
+}
+
+// parseExamQuery builds a store query from GET /exams parameters:
+// limit, cursor, sort (number|average|students), order (asc|desc),
+// minAverage, maxAverage and student
+func parseExamQuery(values url.Values) (store.ExamQuery, error) {
+	var q store.ExamQuery
+	var err error
+
+	if q.Limit, err = parseListLimit(values); err != nil {
+		return q, err
+	}
+	if q.Descending, err = parseOrder(values); err != nil {
+		return q, err
+	}
+	if q.MinAverage, err = parseOptionalFloat(values, "minAverage"); err != nil {
+		return q, err
+	}
+	if q.MaxAverage, err = parseOptionalFloat(values, "maxAverage"); err != nil {
+		return q, err
+	}
+
+	if sortBy := values.Get("sort"); sortBy != "" {
+		if q.SortBy, err = store.ParseExamSort(sortBy); err != nil {
+			return q, err
+		}
+	}
+
+	q.Student = values.Get("student")
+	q.Cursor = values.Get("cursor")
+	return q, nil
+}
+
+// parseExamStatsQuery builds a store query from GET /exams/{number}/stats
+// parameters: percentiles (comma separated, 0-100) and bucketWidth. How
+// fine the width may be depends on the exam's scores, so the store checks
+// that.
+func parseExamStatsQuery(values url.Values) (store.ExamStatsQuery, error) {
+	var q store.ExamStatsQuery
+
+	if raw := values.Get("percentiles"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			p, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(field), "p"), 64)
+			if err != nil || math.IsNaN(p) || p < 0 || p > 100 {
+				return q, fmt.Errorf("invalid percentile %q", field)
+			}
+			q.Percentiles = append(q.Percentiles, p)
+		}
+	}
+
+	if raw := values.Get("bucketWidth"); raw != "" {
+		width, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(width) || math.IsInf(width, 0) || width <= 0 {
+			return q, fmt.Errorf("bucketWidth must be a positive number")
+		}
+		q.BucketWidth = width
+	}
+
+	return q, nil
+}
+
+// parseLeaderboardQuery reads leaderboard parameters: top (the number of
+// entries, 1-1000, or 0 for every ranked student) and minExams
+func parseLeaderboardQuery(values url.Values) (top int, minExams int, err error) {
+	top = defaultLeaderboardSize
+	if raw := values.Get("top"); raw != "" {
+		top, err = strconv.Atoi(raw)
+		if err != nil || top < 0 || top > maxPageLimit {
+			return 0, 0, fmt.Errorf("top must be between 0 (everyone) and %d", maxPageLimit)
+		}
+	}
+
+	if raw := values.Get("minExams"); raw != "" {
+		minExams, err = strconv.Atoi(raw)
+		if err != nil || minExams < 0 {
+			return 0, 0, fmt.Errorf("invalid minExams %q", raw)
+		}
+	}
+
+	return top, minExams, nil
+}
+
+// parseRejectedQuery reads dead-letter listing parameters: reason, before
+// (an entry ID to page back from) and limit
+func parseRejectedQuery(values url.Values) (reason string, before uint64, limit int, err error) {
+	limit, err = parseLimit(values)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	if raw := values.Get("before"); raw != "" {
+		before, err = strconv.ParseUint(raw, 10, 64)
+		if err != nil || before == 0 {
+			return "", 0, 0, fmt.Errorf("invalid before %q", raw)
+		}
+	}
+
+	return values.Get("reason"), before, limit, nil
+}
+
+// parseListLimit reads the page size of a student or exam list. Without
+// limit or cursor the whole list is returned, as it was before lists were
+// paged; a cursor alone pages by defaultPageLimit.
+func parseListLimit(values url.Values) (int, error) {
+	if values.Get("limit") == "" && values.Get("cursor") == "" {
+		return 0, nil
+	}
+	return parseLimit(values)
+}
+
+func parseLimit(values url.Values) (int, error) {
+	raw := values.Get("limit")
+	if raw == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	return limit, nil
+}
+
+func parseOrder(values url.Values) (bool, error) {
+	switch order := values.Get("order"); order {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown order %q", order)
+	}
+}
+
+func parseOptionalFloat(values url.Values, name string) (*float64, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return &v, nil
+}
+
+func parseOptionalInt(values url.Values, name string) (*int, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return &v, nil
+}
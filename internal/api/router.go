@@ -1,25 +1,45 @@
 package api
 
 import (
-	"log"
+	"channel-test/internal/logging"
+	"channel-test/internal/metrics"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
+var (
+	httpRequests = metrics.Default.NewCounterVec("scores_http_requests_total",
+		"HTTP requests handled, by route, method and status.", "route", "method", "status")
+	httpDuration = metrics.Default.NewHistogramVec("scores_http_request_duration_seconds",
+		"HTTP request latency in seconds, by route, method and status.", metrics.DefaultBuckets,
+		"route", "method", "status")
+)
+
 // NewRouter creates and configures the HTTP router
 func NewRouter(handler *Handler) http.Handler {
 	mux := http.NewServeMux()
 
 	// Register routes
 	mux.HandleFunc("/health", handler.HealthCheck)
+	mux.HandleFunc("/livez", handler.Livez)
+	mux.HandleFunc("/readyz", handler.Readyz)
+	mux.HandleFunc("/status", handler.Status)
+	mux.Handle("/metrics", metrics.Default)
+	mux.HandleFunc("/scores", handler.authenticate(handler.idempotent(maxScoreBytes, handler.PostScore)))
+	mux.HandleFunc("/scores/batch", handler.authenticate(handler.idempotent(maxBatchBytes, handler.PostScoreBatch)))
 	mux.HandleFunc("/students/", handleStudentsRoutes(handler))
 	mux.HandleFunc("/exams/", handleExamsRoutes(handler))
+	mux.HandleFunc("/leaderboard", handler.GetLeaderboard)
+	mux.HandleFunc("/stream/", handleStreamRoutes(handler))
+	mux.HandleFunc("/admin/", handleAdminRoutes(handler))
 
 	mux.HandleFunc("/", handler.Index)
 
-	// Wrap with logging middleware
-	return loggingMiddleware(mux)
+	// Wrap with request ID, logging and metrics middleware
+	return requestIDMiddleware(handler.logger, loggingMiddleware(metricsMiddleware(mux)))
 }
 
 // handleStudentsRoutes routes requests for /students and /students/{id}
@@ -33,6 +53,12 @@ func handleStudentsRoutes(handler *Handler) http.HandlerFunc {
 			return
 		}
 
+		// Match /students/{id}/exams/{number}/history
+		if strings.Count(strings.Trim(path, "/"), "/") > 1 {
+			handler.GetScoreHistory(w, r)
+			return
+		}
+
 		// Match /students/{id}
 		if strings.HasPrefix(path, "/students/") {
 			handler.GetStudent(w, r)
@@ -43,7 +69,8 @@ func handleStudentsRoutes(handler *Handler) http.HandlerFunc {
 	}
 }
 
-// handleExamsRoutes routes requests for /exams and /exams/{number}
+// handleExamsRoutes routes requests for /exams, /exams/{number},
+// /exams/{number}/stats and /exams/{number}/leaderboard
 func handleExamsRoutes(handler *Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
@@ -54,6 +81,18 @@ func handleExamsRoutes(handler *Handler) http.HandlerFunc {
 			return
 		}
 
+		// Match /exams/{number}/leaderboard
+		if strings.HasSuffix(path, "/leaderboard") {
+			handler.GetExamLeaderboard(w, r)
+			return
+		}
+
+		// Match /exams/{number}/stats
+		if strings.Count(strings.Trim(path, "/"), "/") > 1 {
+			handler.GetExamStats(w, r)
+			return
+		}
+
 		// Match /exams/{number}
 		if strings.HasPrefix(path, "/exams/") {
 			handler.GetExam(w, r)
@@ -64,6 +103,69 @@ func handleExamsRoutes(handler *Handler) http.HandlerFunc {
 	}
 }
 
+// handleStreamRoutes routes requests for the live score streams
+func handleStreamRoutes(handler *Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		switch {
+		case path == "/stream/scores":
+			handler.StreamScores(w, r)
+		case strings.HasPrefix(path, "/stream/students/"):
+			handler.StreamStudent(w, r)
+		case strings.HasPrefix(path, "/stream/exams/"):
+			handler.StreamExam(w, r)
+		default:
+			handler.NotFound(w, r)
+		}
+	}
+}
+
+// handleAdminRoutes routes requests for /admin/rejected,
+// /admin/rejected/{id}, /admin/rejected/{id}/resubmit, /admin/sources,
+// /admin/snapshot and /admin/restore. They expose raw payloads and
+// upstream URLs or write to the store, so they need an API key.
+func handleAdminRoutes(handler *Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		switch {
+		case len(parts) == 2 && parts[1] == "rejected":
+			handler.authenticate(handler.ListRejected)(w, r)
+		case len(parts) == 3 && parts[1] == "rejected":
+			handler.authenticate(handler.GetRejected)(w, r)
+		case len(parts) == 4 && parts[1] == "rejected" && parts[3] == "resubmit":
+			handler.authenticate(handler.ResubmitRejected)(w, r)
+		case len(parts) == 2 && parts[1] == "sources":
+			handler.authenticate(handler.ListSources)(w, r)
+		case len(parts) == 2 && parts[1] == "snapshot":
+			handler.authenticate(handler.GetSnapshot)(w, r)
+		case len(parts) == 2 && parts[1] == "restore":
+			handler.authenticate(handler.RestoreSnapshot)(w, r)
+		default:
+			handler.NotFound(w, r)
+		}
+	}
+}
+
+// requestIDHeader carries the ID that ties a request to its log lines
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware propagates the caller's X-Request-ID, or generates
+// one, echoes it in the response and attaches it to the request's logger
+func requestIDMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !logging.ValidID(id) {
+			id = logging.NewID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := logging.WithContext(r.Context(), logger.With(logging.RequestIDKey, id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // loggingMiddleware logs HTTP requests
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -75,10 +177,102 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(wrapped, r)
 
 		duration := time.Since(start)
-		log.Printf("%s %s %d %v", r.Method, r.URL.Path, wrapped.statusCode, duration)
+		logging.FromContext(r.Context()).Info("HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", wrapped.statusCode,
+			"durationMs", float64(duration.Microseconds())/1000,
+		)
+	})
+}
+
+// metricsMiddleware records request counts and latency by route
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(wrapped, r)
+
+		labels := []string{routeLabel(r.URL.Path), methodLabel(r.Method), strconv.Itoa(wrapped.statusCode)}
+		httpRequests.With(labels...).Inc()
+		httpDuration.With(labels...).Observe(time.Since(start).Seconds())
 	})
 }
 
+// methodLabel maps a request method to one of the standard methods, or
+// "other", so clients cannot create a series per made-up method
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodDelete, http.MethodPatch, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
+
+// routeLabel maps a request path to its route pattern so metrics are not
+// partitioned by student ID or exam number
+func routeLabel(path string) string {
+	trimmed := strings.Trim(path, "/")
+	parts := strings.Split(trimmed, "/")
+
+	switch parts[0] {
+	case "":
+		return "/"
+	case "health", "livez", "readyz", "status", "metrics", "leaderboard":
+		if len(parts) == 1 {
+			return "/" + parts[0]
+		}
+	case "scores":
+		if trimmed == "scores" || trimmed == "scores/batch" {
+			return "/" + trimmed
+		}
+	case "students":
+		switch len(parts) {
+		case 1:
+			return "/students"
+		case 2:
+			return "/students/{id}"
+		case 5:
+			return "/students/{id}/exams/{number}/history"
+		}
+	case "exams":
+		switch len(parts) {
+		case 1:
+			return "/exams"
+		case 2:
+			return "/exams/{number}"
+		case 3:
+			if parts[2] == "stats" || parts[2] == "leaderboard" {
+				return "/exams/{number}/" + parts[2]
+			}
+		}
+	case "admin":
+		switch {
+		case trimmed == "admin/rejected":
+			return "/admin/rejected"
+		case len(parts) == 3 && parts[1] == "rejected":
+			return "/admin/rejected/{id}"
+		case len(parts) == 4 && parts[1] == "rejected" && parts[3] == "resubmit":
+			return "/admin/rejected/{id}/resubmit"
+		case trimmed == "admin/sources", trimmed == "admin/snapshot", trimmed == "admin/restore":
+			return "/" + trimmed
+		}
+	case "stream":
+		switch {
+		case trimmed == "stream/scores":
+			return "/stream/scores"
+		case len(parts) == 3 && parts[1] == "students":
+			return "/stream/students/{id}"
+		case len(parts) == 3 && parts[1] == "exams":
+			return "/stream/exams/{number}"
+		}
+	}
+	return "other"
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -89,3 +283,9 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer so
+// streaming handlers can flush and extend deadlines
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
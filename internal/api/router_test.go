@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"channel-test/internal/logging"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteLabel(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/", "/"},
+		{"/health", "/health"},
+		{"/metrics", "/metrics"},
+		{"/readyz", "/readyz"},
+		{"/scores", "/scores"},
+		{"/scores/batch", "/scores/batch"},
+		{"/scores/other", "other"},
+		{"/students", "/students"},
+		{"/students/alice", "/students/{id}"},
+		{"/students/alice/exams/3/history", "/students/{id}/exams/{number}/history"},
+		{"/exams/", "/exams"},
+		{"/exams/3", "/exams/{number}"},
+		{"/exams/3/stats", "/exams/{number}/stats"},
+		{"/exams/3/leaderboard", "/exams/{number}/leaderboard"},
+		{"/leaderboard", "/leaderboard"},
+		{"/stream/scores", "/stream/scores"},
+		{"/stream/students/alice", "/stream/students/{id}"},
+		{"/stream/exams/3", "/stream/exams/{number}"},
+		{"/admin/rejected", "/admin/rejected"},
+		{"/admin/rejected/7", "/admin/rejected/{id}"},
+		{"/admin/rejected/7/resubmit", "/admin/rejected/{id}/resubmit"},
+		{"/admin/sources", "/admin/sources"},
+		{"/admin/snapshot", "/admin/snapshot"},
+		{"/admin/restore", "/admin/restore"},
+		{"/admin/other", "other"},
+		{"/favicon.ico", "other"},
+		{"/students/alice/extra", "other"},
+	}
+
+	for _, tt := range tests {
+		if got := routeLabel(tt.path); got != tt.expected {
+			t.Errorf("%s: Expected %s, got %s", tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	tests := []struct {
+		method   string
+		expected string
+	}{
+		{http.MethodGet, "GET"},
+		{http.MethodOptions, "OPTIONS"},
+		{"get", "other"},
+		{"PROPFIND", "other"},
+		{"X-RANDOM-1234", "other"},
+	}
+
+	for _, tt := range tests {
+		if got := methodLabel(tt.method); got != tt.expected {
+			t.Errorf("%s: Expected %s, got %s", tt.method, tt.expected, got)
+		}
+	}
+}
+
+func TestRouter_Metrics(t *testing.T) {
+	router := NewRouter(NewHandler(setupTestStore()))
+
+	req := httptest.NewRequest(http.MethodGet, "/students/alice", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	expected := `scores_http_requests_total{route="/students/{id}",method="GET",status="200"}`
+	if !strings.Contains(body, expected) {
+		t.Errorf("Expected %s in metrics output, got:\n%s", expected, body)
+	}
+	if !strings.Contains(body, "# TYPE scores_http_request_duration_seconds histogram") {
+		t.Error("Expected request duration histogram in metrics output")
+	}
+}
+
+func TestRouter_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	router := NewRouter(NewHandler(setupTestStore(), WithLogger(logging.New(&buf, slog.LevelInfo))))
+
+	// A valid ID from the caller is propagated
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if id := w.Header().Get("X-Request-ID"); id != "abc-123" {
+		t.Errorf("Expected request ID abc-123, got %q", id)
+	}
+	if !strings.Contains(buf.String(), `"requestId":"abc-123"`) {
+		t.Errorf("Expected request ID in log line, got %s", buf.String())
+	}
+
+	// A missing or unsafe ID is replaced
+	for _, header := range []string{"", "has space", strings.Repeat("x", 200)} {
+		req = httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set("X-Request-ID", header)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if id := w.Header().Get("X-Request-ID"); len(id) != 16 {
+			t.Errorf("%q: Expected generated request ID, got %q", header, id)
+		}
+	}
+}
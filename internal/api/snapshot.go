@@ -0,0 +1,128 @@
+package api
+
+import (
+	"channel-test/internal/logging"
+	"channel-test/internal/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRestoreBytes bounds the body of a restore upload. The archive is
+// decoded in memory before the store is touched, so this also bounds the
+// memory a restore takes: a few million scores.
+const maxRestoreBytes = 256 << 20
+
+// GetSnapshot handles GET /admin/snapshot
+// Streams a checksummed archive of every score held, as of one instant.
+// Supports format: json (the default) or binary.
+func (h *Handler) GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snapshotter, ok := h.store.(store.Snapshotter)
+	if !ok {
+		http.Error(w, "Snapshots unsupported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	format, err := store.ParseSnapshotFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Large snapshots outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	snap := snapshotter.Snapshot()
+
+	contentType, extension := "application/json", "json"
+	if format == store.FormatBinary {
+		contentType, extension = "application/octet-stream", "snap"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="scores-%s.%s"`, snap.CreatedAt.UTC().Format("20060102T150405Z"), extension))
+	w.Header().Set("X-Snapshot-Records", strconv.Itoa(len(snap.Records)))
+	w.WriteHeader(http.StatusOK)
+
+	// The status is already sent, but a cut-off archive fails its checksum
+	if err := snap.Encode(w, format); err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to stream snapshot", "error", err)
+	}
+}
+
+// RestoreSnapshot handles POST /admin/restore
+// The body is an archive from GET /admin/snapshot, in either format.
+// Supports mode: merge (the default) adds the archive's scores to those
+// held, skipping any already held; replace drops every score held first.
+func (h *Handler) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snapshotter, ok := h.store.(store.Snapshotter)
+	if !ok {
+		http.Error(w, "Snapshots unsupported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	mode, err := store.ParseRestoreMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Large uploads outlive the server's read timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	snap, err := store.DecodeSnapshot(http.MaxBytesReader(w, r.Body, maxRestoreBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Snapshot too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger := logging.FromContext(r.Context())
+	result, err := snapshotter.Restore(snap, mode)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidSnapshot) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error("Failed to restore snapshot", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Restored snapshot",
+		"mode", result.Mode,
+		"restored", result.Restored,
+		"skipped", result.Skipped,
+		"scores", result.Scores,
+	)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"mode":              result.Mode,
+		"restored":          result.Restored,
+		"skipped":           result.Skipped,
+		"scores":            result.Scores,
+		"snapshotCreatedAt": snap.CreatedAt,
+	})
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"channel-test/internal/auth"
+	"channel-test/internal/store"
+	"channel-test/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const restoreKey = "0123456789abcdef"
+
+func setupSnapshotRouter(t *testing.T, s store.Store) http.Handler {
+	t.Helper()
+	keys, err := auth.ParseKeys([]byte("ops: " + restoreKey + "\n"))
+	if err != nil {
+		t.Fatalf("ParseKeys failed: %v", err)
+	}
+	return NewRouter(NewHandler(s, WithAPIKeys(keys)))
+}
+
+func restoreRequest(path string, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+restoreKey)
+	return req
+}
+
+func TestHandler_SnapshotRestore(t *testing.T) {
+	for _, format := range []string{"json", "binary"} {
+		source := store.NewMemoryStore()
+		source.AddScore(models.ScoreEvent{Exam: 1, StudentID: "alice", Score: 0.9})
+		source.AddScore(models.ScoreEvent{Exam: 2, StudentID: "bob", Score: 0.7})
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/snapshot?format="+format, nil)
+		req.Header.Set("Authorization", "Bearer "+restoreKey)
+		w := httptest.NewRecorder()
+		setupSnapshotRouter(t, source).ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: Expected status 200, got %d", format, w.Code)
+		}
+		if records := w.Header().Get("X-Snapshot-Records"); records != "2" {
+			t.Errorf("%s: Expected 2 records, got %q", format, records)
+		}
+		if disposition := w.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment; filename=\"scores-") {
+			t.Errorf("%s: Expected attachment, got %q", format, disposition)
+		}
+		archive := w.Body.Bytes()
+
+		target := store.NewMemoryStore()
+		target.AddScore(models.ScoreEvent{Exam: 3, StudentID: "carol", Score: 0.5})
+		router := setupSnapshotRouter(t, target)
+
+		tests := []struct {
+			mode     string
+			restored int
+			skipped  int
+			scores   int
+			students int
+		}{
+			{"", 2, 0, 3, 3},
+			{"merge", 0, 2, 3, 3},
+			{"replace", 2, 0, 2, 2},
+		}
+
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, restoreRequest("/admin/restore?mode="+tt.mode, archive))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s %q: Expected status 200, got %d: %s", format, tt.mode, w.Code, w.Body.String())
+			}
+			var resp struct {
+				Restored int `json:"restored"`
+				Skipped  int `json:"skipped"`
+				Scores   int `json:"scores"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Restored != tt.restored || resp.Skipped != tt.skipped || resp.Scores != tt.scores {
+				t.Errorf("%s %q: Expected %d restored, %d skipped of %d, got %+v", format, tt.mode, tt.restored, tt.skipped, tt.scores, resp)
+			}
+			if students := target.GetAllStudents(); len(students) != tt.students {
+				t.Errorf("%s %q: Expected %d students, got %v", format, tt.mode, tt.students, students)
+			}
+		}
+	}
+}
+
+func TestHandler_SnapshotRestoreErrors(t *testing.T) {
+	var archive bytes.Buffer
+	store.NewMemoryStore().Snapshot().Encode(&archive, store.FormatJSON)
+	target := store.NewMemoryStore()
+	router := setupSnapshotRouter(t, target)
+
+	// Intact archives holding scores ingest would have rejected
+	var outOfRange, noStudent bytes.Buffer
+	(&store.Snapshot{Records: []models.ScoreRecord{{Exam: 1, StudentID: "alice", Score: 95}}}).Encode(&outOfRange, store.FormatBinary)
+	(&store.Snapshot{Records: []models.ScoreRecord{{Exam: 1, Score: 0.5}}}).Encode(&noStudent, store.FormatJSON)
+
+	snapshotRequest := func(key string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/admin/snapshot", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		expected int
+	}{
+		{"bad format", func() *http.Request {
+			req := snapshotRequest(restoreKey)
+			req.URL.RawQuery = "format=xml"
+			return req
+		}(), http.StatusBadRequest},
+		{"snapshot method", func() *http.Request {
+			req := snapshotRequest(restoreKey)
+			req.Method = http.MethodPost
+			return req
+		}(), http.StatusMethodNotAllowed},
+		{"snapshot no key", snapshotRequest(""), http.StatusUnauthorized},
+		{"snapshot wrong key", snapshotRequest("fedcba9876543210"), http.StatusUnauthorized},
+		{"no key", httptest.NewRequest(http.MethodPost, "/admin/restore", bytes.NewReader(archive.Bytes())), http.StatusUnauthorized},
+		{"out of range score", restoreRequest("/admin/restore", outOfRange.Bytes()), http.StatusBadRequest},
+		{"missing student", restoreRequest("/admin/restore", noStudent.Bytes()), http.StatusBadRequest},
+		{"bad mode", restoreRequest("/admin/restore?mode=append", archive.Bytes()), http.StatusBadRequest},
+		{"corrupt archive", restoreRequest("/admin/restore", bytes.Replace(archive.Bytes(), []byte(`"version":1`), []byte(`"version":9`), 1)), http.StatusBadRequest},
+		{"restore method", func() *http.Request {
+			req := restoreRequest("/admin/restore", nil)
+			req.Method = http.MethodGet
+			return req
+		}(), http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt.req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s: Expected status %d, got %d", tt.name, tt.expected, w.Code)
+		}
+	}
+	if stats := target.Stats(); stats.Scores != 0 {
+		t.Errorf("Expected rejected archives to leave the store empty, got %d scores", stats.Scores)
+	}
+}
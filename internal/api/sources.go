@@ -0,0 +1,60 @@
+package api
+
+import (
+	"channel-test/internal/consumer"
+	"net/http"
+)
+
+// SourcesProvider reports the state of every upstream source consumer
+type SourcesProvider interface {
+	Sources() []consumer.Status
+}
+
+// WithSources exposes the state of every upstream source on
+// GET /admin/sources and checks each of them in GET /readyz
+func WithSources(sources SourcesProvider) HandlerOption {
+	return func(h *Handler) {
+		h.sources = sources
+	}
+}
+
+// ListSources handles GET /admin/sources
+// Returns each upstream source's connection state, event counts and
+// last error
+func (h *Handler) ListSources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.sources == nil {
+		http.Error(w, "Sources unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	sources := h.sources.Sources()
+	connected := 0
+	for _, source := range sources {
+		if source.Connected {
+			connected++
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"sources":   sources,
+		"count":     len(sources),
+		"connected": connected,
+	})
+}
+
+// sourceStatuses returns the statuses readiness checks: every source when
+// known, otherwise the single consumer's
+func (h *Handler) sourceStatuses() []consumer.Status {
+	if h.sources != nil {
+		return h.sources.Sources()
+	}
+	if h.consumer != nil {
+		return []consumer.Status{h.consumer.Status()}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"channel-test/internal/consumer"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeSourcesProvider reports fixed source statuses
+type fakeSourcesProvider []consumer.Status
+
+func (f fakeSourcesProvider) Sources() []consumer.Status {
+	return f
+}
+
+func setupSources() fakeSourcesProvider {
+	return fakeSourcesProvider{
+		{Source: "primary", Connected: true, StartedAt: ago(time.Hour), LastAcceptedAt: ago(time.Second), EventsRead: 12, ScoresAccepted: 10, ScoresRejected: 2},
+		{Source: "partner", StartedAt: ago(time.Hour), DisconnectedAt: ago(time.Hour), Reconnects: 7, LastError: "unexpected status code: 401"},
+	}
+}
+
+func TestHandler_ListSources(t *testing.T) {
+	handler := NewHandler(setupTestStore(), WithSources(setupSources()))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/sources", nil)
+	w := httptest.NewRecorder()
+
+	handler.ListSources(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Sources   []consumer.Status `json:"sources"`
+		Count     int               `json:"count"`
+		Connected int               `json:"connected"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || resp.Connected != 1 {
+		t.Errorf("Expected 2 sources with 1 connected, got %d with %d", resp.Count, resp.Connected)
+	}
+	if resp.Sources[0].ScoresAccepted != 10 || resp.Sources[1].LastError != "unexpected status code: 401" {
+		t.Errorf("Expected per-source counts and errors, got %+v", resp.Sources)
+	}
+
+	// Without sources the endpoint is unavailable
+	w = httptest.NewRecorder()
+	NewHandler(setupTestStore()).ListSources(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestHandler_Readyz_Sources(t *testing.T) {
+	handler := NewHandler(setupTestStore(), WithSources(setupSources()))
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	handler.Readyz(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	var resp readyzResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := []string{"consumer:partner", "eventAge:partner"}
+	if !reflect.DeepEqual(resp.Failing, expected) {
+		t.Errorf("Expected failing checks %v, got %v", expected, resp.Failing)
+	}
+	if len(resp.Checks) != 5 {
+		t.Errorf("Expected 5 checks, got %d", len(resp.Checks))
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// MinKeyLength is the shortest API key accepted
+const MinKeyLength = 16
+
+// KeyHeader is an alternative to "Authorization: Bearer <key>"
+const KeyHeader = "X-API-Key"
+
+// client is one named holder of an API key; only a digest of the key is
+// kept so comparisons take the same time whatever the key
+type client struct {
+	name   string
+	digest [sha256.Size]byte
+}
+
+// Keys holds the API keys allowed to call protected endpoints
+type Keys struct {
+	clients []client
+}
+
+// LoadKeys reads API keys from the file at path
+func LoadKeys(path string) (*Keys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	return ParseKeys(data)
+}
+
+// ParseKeys reads one "name:key" pair per line. Blank lines and lines
+// starting with # are skipped. Names and keys must be unique and keys at
+// least MinKeyLength characters long; every invalid line is reported.
+func ParseKeys(data []byte) (*Keys, error) {
+	keys := &Keys{}
+	names := make(map[string]bool)
+	digests := make(map[[sha256.Size]byte]bool)
+	var errs []error
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, key, ok := strings.Cut(line, ":")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		switch {
+		case !ok || name == "":
+			errs = append(errs, fmt.Errorf("line %d: expected name:key", n))
+			continue
+		case len(key) < MinKeyLength:
+			errs = append(errs, fmt.Errorf("line %d: key for %q is shorter than %d characters", n, name, MinKeyLength))
+			continue
+		case names[name]:
+			errs = append(errs, fmt.Errorf("line %d: duplicate name %q", n, name))
+			continue
+		}
+
+		digest := sha256.Sum256([]byte(key))
+		if digests[digest] {
+			errs = append(errs, fmt.Errorf("line %d: key for %q is already in use", n, name))
+			continue
+		}
+		names[name] = true
+		digests[digest] = true
+		keys.clients = append(keys.clients, client{name: name, digest: digest})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid API keys:\n%w", err)
+	}
+	if len(keys.clients) == 0 {
+		return nil, errors.New("invalid API keys: no keys found")
+	}
+	return keys, nil
+}
+
+// Len returns the number of keys
+func (k *Keys) Len() int {
+	return len(k.clients)
+}
+
+// Authenticate returns the name of the client whose key the request
+// carries, as "Authorization: Bearer <key>" or in the X-API-Key header
+func (k *Keys) Authenticate(r *http.Request) (string, bool) {
+	key := r.Header.Get(KeyHeader)
+	if auth := r.Header.Get("Authorization"); key == "" && auth != "" {
+		scheme, credentials, _ := strings.Cut(auth, " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			key = strings.TrimSpace(credentials)
+		}
+	}
+	if key == "" {
+		return "", false
+	}
+
+	// Check every key so the time taken doesn't reveal which one matched
+	digest := sha256.Sum256([]byte(key))
+	name, found := "", false
+	for _, c := range k.clients {
+		if subtle.ConstantTimeCompare(digest[:], c.digest[:]) == 1 {
+			name, found = c.name, true
+		}
+	}
+	return name, found
+}
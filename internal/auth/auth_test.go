@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys([]byte(`
+# grading tools
+gradebook: 0123456789abcdef
+offline-exams : fedcba9876543210fedcba
+`))
+	if err != nil {
+		t.Fatalf("ParseKeys failed: %v", err)
+	}
+	if keys.Len() != 2 {
+		t.Errorf("Expected 2 keys, got %d", keys.Len())
+	}
+}
+
+func TestParseKeys_Errors(t *testing.T) {
+	_, err := ParseKeys([]byte(`
+gradebook
+short: abc
+a: 0123456789abcdef
+a: fedcba9876543210
+b: 0123456789abcdef
+`))
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	// Every bad line is reported
+	for _, expected := range []string{"line 2:", "line 3:", "line 5:", "line 6:"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to mention %s, got:\n%v", expected, err)
+		}
+	}
+
+	if _, err := ParseKeys([]byte("# nothing here\n")); err == nil {
+		t.Error("Expected error for a file without keys")
+	}
+}
+
+func TestKeys_Authenticate(t *testing.T) {
+	keys, err := ParseKeys([]byte("gradebook: 0123456789abcdef\nexams: fedcba9876543210\n"))
+	if err != nil {
+		t.Fatalf("ParseKeys failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected string
+	}{
+		{"bearer token", "Authorization", "Bearer fedcba9876543210", "exams"},
+		{"lowercase scheme", "Authorization", "bearer 0123456789abcdef", "gradebook"},
+		{"API key header", KeyHeader, "0123456789abcdef", "gradebook"},
+		{"wrong key", KeyHeader, "0123456789abcdeX", ""},
+		{"basic auth", "Authorization", "Basic 0123456789abcdef", ""},
+		{"no key", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/scores", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+
+		name, ok := keys.Authenticate(req)
+		if name != tt.expected || ok != (tt.expected != "") {
+			t.Errorf("%s: Expected %q, got %q (%v)", tt.name, tt.expected, name, ok)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package config
+
+import (
+	"channel-test/internal/deadletter"
+	"channel-test/internal/logging"
+	"channel-test/internal/store"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Config holds every setting of the scores API. Settings are read from,
+// in increasing order of precedence: defaults, a config file, environment
+// variables and command-line flags.
+type Config struct {
+	Port           string `json:"port"`
+	SSEURL         string `json:"sseUrl"`
+	CheckpointFile string `json:"checkpointFile"`
+	Store          string `json:"store"`
+	StoreDir       string `json:"storeDir"`
+	StoreShards    int    `json:"storeShards"` // lock stripes of the sharded store
+	ScorePolicy    string `json:"scorePolicy"`
+	LogLevel       string `json:"logLevel"`
+
+	DedupWindow Duration `json:"dedupWindow"` // 0 disables deduplication
+	DedupSize   int      `json:"dedupSize"`   // most scores remembered for deduplication
+	// DedupContent also drops a score without an event ID that repeats the
+	// exam, student and score of one received within DedupWindow. That
+	// catches redeliveries from senders that don't set IDs, but also drops
+	// a genuine repeat of the same score, such as a retake, so it is off.
+	DedupContent bool `json:"dedupContent"`
+
+	ReadTimeout     Duration `json:"readTimeout"`
+	WriteTimeout    Duration `json:"writeTimeout"`
+	IdleTimeout     Duration `json:"idleTimeout"`
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+
+	ReconnectInitialDelay Duration `json:"reconnectInitialDelay"`
+	ReconnectMaxDelay     Duration `json:"reconnectMaxDelay"`
+	ReconnectMaxAttempts  int      `json:"reconnectMaxAttempts"` // 0 retries forever
+
+	ReadyMaxDisconnected Duration `json:"readyMaxDisconnected"` // 0 fails on any disconnect
+	ReadyMaxEventAge     Duration `json:"readyMaxEventAge"`     // 0 disables the check
+
+	RecordDir         string   `json:"recordDir"`         // empty disables recording
+	RecordSegmentSize int      `json:"recordSegmentSize"` // megabytes, 0 disables size rotation
+	RecordSegmentAge  Duration `json:"recordSegmentAge"`  // 0 disables age rotation
+
+	DeadLetterSize int `json:"deadLetterSize"` // rejected events kept, 0 disables
+
+	ValidationRules string `json:"validationRules"` // JSON rules file, empty for the defaults
+
+	SourcesFile string `json:"sourcesFile"` // JSON upstream sources file, empty for sseUrl alone
+
+	APIKeysFile    string   `json:"apiKeysFile"`    // name:key lines, empty disables score submission and /admin
+	IdempotencyTTL Duration `json:"idempotencyTtl"` // how long Idempotency-Key responses are kept
+
+	// PrintConfig asks for the resolved config to be printed instead of
+	// starting the server
+	PrintConfig bool `json:"-"`
+}
+
+// Default returns the config used when nothing is overridden
+func Default() *Config {
+	return &Config{
+		Port:           "8080",
+		SSEURL:         "http://live-test-scores.herokuapp.com/scores",
+		CheckpointFile: "data/last-event-id",
+		Store:          "memory",
+		StoreDir:       "data/store",
+		StoreShards:    store.DefaultShards,
+		ScorePolicy:    string(store.PolicyLatest),
+		LogLevel:       "info",
+
+		DedupWindow: Duration(store.DefaultDedupWindow),
+		DedupSize:   store.DefaultDedupSize,
+
+		ReadTimeout:     Duration(15 * time.Second),
+		WriteTimeout:    Duration(15 * time.Second),
+		IdleTimeout:     Duration(60 * time.Second),
+		ShutdownTimeout: Duration(10 * time.Second),
+
+		ReconnectInitialDelay: Duration(time.Second),
+		ReconnectMaxDelay:     Duration(30 * time.Second),
+
+		ReadyMaxDisconnected: Duration(30 * time.Second),
+		ReadyMaxEventAge:     Duration(5 * time.Minute),
+
+		RecordSegmentSize: 64,
+		RecordSegmentAge:  Duration(time.Hour),
+
+		DeadLetterSize: deadletter.DefaultCapacity,
+
+		IdempotencyTTL: Duration(24 * time.Hour),
+	}
+}
+
+// Duration is a time.Duration written as a string such as "15s" in config
+// files and printed configs
+type Duration time.Duration
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Std returns the duration as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *Duration) set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// field is one setting along with the names it is read from
+type field struct {
+	key   string // config file key and printed JSON name
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var fields = []field{
+	{"port", "port", "PORT", "HTTP listen port", stringVar(func(c *Config) *string { return &c.Port })},
+	{"sseUrl", "sse-url", "SSE_URL", "upstream score event stream", stringVar(func(c *Config) *string { return &c.SSEURL })},
+	{"checkpointFile", "checkpoint-file", "CHECKPOINT_FILE", "file holding the last upstream event ID", stringVar(func(c *Config) *string { return &c.CheckpointFile })},
+	{"store", "store", "STORE", "store type: memory, sharded or file", stringVar(func(c *Config) *string { return &c.Store })},
+	{"storeDir", "store-dir", "STORE_DIR", "directory for the file store", stringVar(func(c *Config) *string { return &c.StoreDir })},
+	{"storeShards", "store-shards", "STORE_SHARDS", "shards students are spread over by the sharded store", intVar(func(c *Config) *int { return &c.StoreShards })},
+	{"scorePolicy", "score-policy", "SCORE_POLICY", "rescored exams count: latest, best, first or average", stringVar(func(c *Config) *string { return &c.ScorePolicy })},
+	{"logLevel", "log-level", "LOG_LEVEL", "minimum log level: debug, info, warn or error", stringVar(func(c *Config) *string { return &c.LogLevel })},
+	{"dedupWindow", "dedup-window", "DEDUP_WINDOW", "time a received score is remembered to ignore redeliveries, 0 to disable", durationVar(func(c *Config) *Duration { return &c.DedupWindow })},
+	{"dedupSize", "dedup-size", "DEDUP_SIZE", "most received scores remembered to ignore redeliveries", intVar(func(c *Config) *int { return &c.DedupSize })},
+	{"dedupContent", "dedup-content", "DEDUP_CONTENT", "also ignore scores without an event ID repeating the exam, student and score of a recent one; drops genuine repeats too", boolVar(func(c *Config) *bool { return &c.DedupContent })},
+	{"readTimeout", "read-timeout", "READ_TIMEOUT", "HTTP server read timeout", durationVar(func(c *Config) *Duration { return &c.ReadTimeout })},
+	{"writeTimeout", "write-timeout", "WRITE_TIMEOUT", "HTTP server write timeout", durationVar(func(c *Config) *Duration { return &c.WriteTimeout })},
+	{"idleTimeout", "idle-timeout", "IDLE_TIMEOUT", "HTTP server idle connection timeout", durationVar(func(c *Config) *Duration { return &c.IdleTimeout })},
+	{"shutdownTimeout", "shutdown-timeout", "SHUTDOWN_TIMEOUT", "time allowed for graceful shutdown", durationVar(func(c *Config) *Duration { return &c.ShutdownTimeout })},
+	{"reconnectInitialDelay", "reconnect-initial-delay", "RECONNECT_INITIAL_DELAY", "first upstream reconnect delay", durationVar(func(c *Config) *Duration { return &c.ReconnectInitialDelay })},
+	{"reconnectMaxDelay", "reconnect-max-delay", "RECONNECT_MAX_DELAY", "longest upstream reconnect delay", durationVar(func(c *Config) *Duration { return &c.ReconnectMaxDelay })},
+	{"reconnectMaxAttempts", "reconnect-max-attempts", "RECONNECT_MAX_ATTEMPTS", "upstream reconnect attempts before giving up, 0 for unlimited", intVar(func(c *Config) *int { return &c.ReconnectMaxAttempts })},
+	{"readyMaxDisconnected", "ready-max-disconnected", "READY_MAX_DISCONNECTED", "how long the upstream may be disconnected before /readyz fails", durationVar(func(c *Config) *Duration { return &c.ReadyMaxDisconnected })},
+	{"readyMaxEventAge", "ready-max-event-age", "READY_MAX_EVENT_AGE", "how long without a stored score before /readyz fails, 0 to disable", durationVar(func(c *Config) *Duration { return &c.ReadyMaxEventAge })},
+	{"recordDir", "record-dir", "RECORD_DIR", "directory to record raw upstream events to, empty to disable", stringVar(func(c *Config) *string { return &c.RecordDir })},
+	{"recordSegmentSize", "record-segment-size", "RECORD_SEGMENT_SIZE", "megabytes per recorded events file, 0 for unlimited", intVar(func(c *Config) *int { return &c.RecordSegmentSize })},
+	{"recordSegmentAge", "record-segment-age", "RECORD_SEGMENT_AGE", "time before starting a new recorded events file, 0 for unlimited", durationVar(func(c *Config) *Duration { return &c.RecordSegmentAge })},
+	{"deadLetterSize", "dead-letter-size", "DEAD_LETTER_SIZE", "rejected score events kept for /admin/rejected, 0 to disable", intVar(func(c *Config) *int { return &c.DeadLetterSize })},
+	{"validationRules", "validation-rules", "VALIDATION_RULES", "JSON file of score validation rules, empty for scores in [0,1]", stringVar(func(c *Config) *string { return &c.ValidationRules })},
+	{"sourcesFile", "sources-file", "SOURCES_FILE", "JSON file of named upstream sources, empty to consume sseUrl alone", stringVar(func(c *Config) *string { return &c.SourcesFile })},
+	{"apiKeysFile", "api-keys-file", "API_KEYS_FILE", "file of name:key lines allowed to POST scores and use /admin, empty to disable both", stringVar(func(c *Config) *string { return &c.APIKeysFile })},
+	{"idempotencyTtl", "idempotency-ttl", "IDEMPOTENCY_TTL", "time responses to requests with an Idempotency-Key are kept for retries", durationVar(func(c *Config) *Duration { return &c.IdempotencyTTL })},
+}
+
+func stringVar(p func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*p(c) = value
+		return nil
+	}
+}
+
+func durationVar(p func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		return p(c).set(value)
+	}
+}
+
+func boolVar(p func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*p(c) = b
+		return nil
+	}
+}
+
+func intVar(p func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*p(c) = n
+		return nil
+	}
+}
+
+// Load builds the config from command-line args (without the program
+// name), the environment read through getenv and the config file named by
+// -config or CONFIG_FILE. Every invalid setting is reported in the
+// returned error, not just the first.
+func Load(args []string, getenv func(string) string, usage io.Writer) (*Config, error) {
+	fs := flag.NewFlagSet("scores-api", flag.ContinueOnError)
+	fs.SetOutput(usage)
+
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "JSON or YAML config file (env CONFIG_FILE)")
+	printConfig := fs.Bool("print-config", false, "print the resolved config as JSON and exit")
+
+	flagValues := make(map[string]string)
+	for _, f := range fields {
+		name := f.flag
+		fs.Func(name, fmt.Sprintf("%s (env %s)", f.usage, f.env), func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	c := Default()
+	c.PrintConfig = *printConfig
+
+	var errs []error
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, c.apply(values, "config file")...)
+	}
+
+	envValues := make(map[string]string)
+	for _, f := range fields {
+		if value := getenv(f.env); value != "" {
+			envValues[f.key] = value
+		}
+	}
+	errs = append(errs, c.applyFrom(envValues, "env", func(f field) string { return f.env })...)
+
+	byKey := make(map[string]string)
+	for _, f := range fields {
+		if value, ok := flagValues[f.flag]; ok {
+			byKey[f.key] = value
+		}
+	}
+	errs = append(errs, c.applyFrom(byKey, "flag", func(f field) string { return "-" + f.flag })...)
+
+	errs = append(errs, c.validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return c, nil
+}
+
+// apply sets fields from config file keys, rejecting unknown keys
+func (c *Config) apply(values map[string]string, source string) []error {
+	var errs []error
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("%s: unknown key %q", source, key))
+		}
+	}
+
+	return append(errs, c.applyFrom(values, source, func(f field) string { return f.key })...)
+}
+
+// applyFrom sets each field present in values, which are keyed by field
+// key, naming the field as it appears in source when reporting errors
+func (c *Config) applyFrom(values map[string]string, source string, name func(field) string) []error {
+	var errs []error
+	for _, f := range fields {
+		value, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %v", source, name(f), err))
+		}
+	}
+	return errs
+}
+
+// validate checks settings that parse but are out of range
+func (c *Config) validate() []error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		invalid("port", "must be a number between 1 and 65535, got %q", c.Port)
+	}
+
+	if u, err := url.Parse(c.SSEURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("sseUrl", "must be an http or https URL, got %q", c.SSEURL)
+	}
+
+	switch c.Store {
+	case "memory":
+	case "sharded":
+		if c.StoreShards < 1 {
+			invalid("storeShards", "must be positive, got %d", c.StoreShards)
+		}
+	case "file":
+		if c.StoreDir == "" {
+			invalid("storeDir", "is required for the file store")
+		}
+	default:
+		invalid("store", "must be memory, sharded or file, got %q", c.Store)
+	}
+
+	if _, err := store.ParseScorePolicy(c.ScorePolicy); err != nil {
+		invalid("scorePolicy", "%v", err)
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		invalid("logLevel", "%v", err)
+	}
+
+	for _, d := range []struct {
+		key   string
+		value Duration
+	}{
+		{"readTimeout", c.ReadTimeout},
+		{"writeTimeout", c.WriteTimeout},
+		{"idleTimeout", c.IdleTimeout},
+		{"shutdownTimeout", c.ShutdownTimeout},
+		{"reconnectInitialDelay", c.ReconnectInitialDelay},
+		{"reconnectMaxDelay", c.ReconnectMaxDelay},
+		{"idempotencyTtl", c.IdempotencyTTL},
+	} {
+		if d.value <= 0 {
+			invalid(d.key, "must be positive, got %s", d.value.Std())
+		}
+	}
+
+	if c.ReconnectMaxDelay < c.ReconnectInitialDelay {
+		invalid("reconnectMaxDelay", "must be at least reconnectInitialDelay (%s)", c.ReconnectInitialDelay.Std())
+	}
+	if c.ReconnectMaxAttempts < 0 {
+		invalid("reconnectMaxAttempts", "must not be negative, got %d", c.ReconnectMaxAttempts)
+	}
+	if c.ReadyMaxDisconnected < 0 {
+		invalid("readyMaxDisconnected", "must not be negative, got %s", c.ReadyMaxDisconnected.Std())
+	}
+	if c.ReadyMaxEventAge < 0 {
+		invalid("readyMaxEventAge", "must not be negative, got %s", c.ReadyMaxEventAge.Std())
+	}
+	if c.RecordSegmentSize < 0 {
+		invalid("recordSegmentSize", "must not be negative, got %d", c.RecordSegmentSize)
+	}
+	if c.RecordSegmentAge < 0 {
+		invalid("recordSegmentAge", "must not be negative, got %s", c.RecordSegmentAge.Std())
+	}
+	if c.DedupWindow < 0 {
+		invalid("dedupWindow", "must not be negative, got %s", c.DedupWindow.Std())
+	}
+	if c.DedupSize < 1 {
+		invalid("dedupSize", "must be positive, got %d", c.DedupSize)
+	}
+	if c.DeadLetterSize < 0 {
+		invalid("deadLetterSize", "must not be negative, got %d", c.DeadLetterSize)
+	}
+
+	return errs
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := Load(nil, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if c.Port != "8080" {
+		t.Errorf("Expected port 8080, got %s", c.Port)
+	}
+	if c.ShutdownTimeout.Std() != 10*time.Second {
+		t.Errorf("Expected shutdown timeout 10s, got %s", c.ShutdownTimeout.Std())
+	}
+	if c.DedupContent {
+		t.Errorf("Expected content deduplication to be off")
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+# file sets all three; env overrides two and a flag overrides one
+port: 7000
+storeDir: /from/file
+shutdownTimeout: 5s
+`)
+
+	c, err := Load(
+		[]string{"-config", path, "-port", "9000"},
+		env(map[string]string{"PORT": "8000", "STORE_DIR": "/from/env"}),
+		io.Discard,
+	)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if c.Port != "9000" {
+		t.Errorf("Expected flag port 9000, got %s", c.Port)
+	}
+	if c.StoreDir != "/from/env" {
+		t.Errorf("Expected env store dir, got %s", c.StoreDir)
+	}
+	if c.ShutdownTimeout.Std() != 5*time.Second {
+		t.Errorf("Expected file shutdown timeout 5s, got %s", c.ShutdownTimeout.Std())
+	}
+}
+
+func TestLoad_ConfigFileFromEnv(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"sseUrl": "https://example.com/scores", "reconnectMaxAttempts": 5, "dedupContent": true}`)
+
+	c, err := Load(nil, env(map[string]string{"CONFIG_FILE": path}), io.Discard)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if c.SSEURL != "https://example.com/scores" || c.ReconnectMaxAttempts != 5 || !c.DedupContent {
+		t.Errorf("Expected values from JSON file, got %+v", c)
+	}
+}
+
+func TestLoad_ReportsEveryError(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "colour: blue\n")
+
+	_, err := Load(
+		[]string{"-config", path, "-read-timeout", "soon", "-store", "s3"},
+		env(map[string]string{"RECONNECT_MAX_ATTEMPTS": "many", "PORT": "99999", "DEDUP_CONTENT": "maybe"}),
+		io.Discard,
+	)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	for _, expected := range []string{
+		`unknown key "colour"`,
+		"-read-timeout",
+		"RECONNECT_MAX_ATTEMPTS",
+		"DEDUP_CONTENT",
+		"port:",
+		"store:",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to mention %s, got:\n%v", expected, err)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	values, err := parseYAML([]byte(`---
+plain: value # comment
+double: "has # hash"
+single: 'it''s'
+empty:
+`))
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"plain":  "value",
+		"double": "has # hash",
+		"single": "it's",
+		"empty":  "",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%s: Expected %q, got %q", key, value, values[key])
+		}
+	}
+
+	for _, bad := range []string{"no separator", "a: 1\na: 2", `a: "open`, "nested key: 1"} {
+		if _, err := parseYAML([]byte(bad)); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package consumer
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// uncappedDelay stands in for a MaxDelay of zero. Unlike math.MaxInt64 it
+// is exact as a float64, so a jittered delay below it can't overflow a
+// time.Duration.
+const uncappedDelay = time.Duration(1 << 62)
+
+// ReconnectPolicy decides how long the consumer waits between
+// connection attempts
+type ReconnectPolicy interface {
+	// NextDelay returns the delay before reconnect attempt n, counting
+	// from 1 after each successful connection. retry is the reconnection
+	// time last requested by the server, or zero if it sent none.
+	// It returns false once the policy gives up.
+	NextDelay(attempt int, retry time.Duration) (time.Duration, bool)
+}
+
+// ExponentialBackoff is a ReconnectPolicy that doubles (by Multiplier)
+// the delay ceiling on every attempt and picks a random delay below it
+// ("full jitter"), so a fleet of consumers does not reconnect in lockstep
+type ExponentialBackoff struct {
+	// InitialDelay is the ceiling for the first attempt. A server retry
+	// hint takes its place when present.
+	InitialDelay time.Duration
+	// MaxDelay caps the ceiling; zero means no cap. It is raised to the
+	// server retry hint if the server asks for a longer delay.
+	MaxDelay time.Duration
+	// Multiplier grows the ceiling between attempts
+	Multiplier float64
+	// MaxAttempts is the number of consecutive failed attempts before
+	// giving up; zero retries forever
+	MaxAttempts int
+
+	rand func() float64
+}
+
+// NewExponentialBackoff creates an exponential backoff policy with
+// sensible defaults: 1s initial delay, 30s maximum, doubling, unlimited attempts
+func NewExponentialBackoff() *ExponentialBackoff {
+	return &ExponentialBackoff{
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+	}
+}
+
+// NextDelay implements ReconnectPolicy
+func (b *ExponentialBackoff) NextDelay(attempt int, retry time.Duration) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+
+	base := b.InitialDelay
+	if retry > 0 {
+		base = retry
+	}
+
+	limit := b.MaxDelay
+	if limit <= 0 {
+		limit = uncappedDelay
+	}
+	if retry > limit {
+		limit = retry
+	}
+	limit = min(limit, uncappedDelay)
+
+	ceiling := float64(base) * math.Pow(b.Multiplier, float64(attempt-1))
+	if ceiling > float64(limit) {
+		ceiling = float64(limit)
+	}
+
+	random := b.rand
+	if random == nil {
+		random = rand.Float64
+	}
+
+	return time.Duration(random() * ceiling), true
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package consumer
+
+import (
+	"channel-test/internal/store"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff_NextDelay(t *testing.T) {
+	policy := &ExponentialBackoff{
+		InitialDelay: time.Second,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   2,
+		MaxAttempts:  6,
+		rand:         func() float64 { return 0.5 },
+	}
+
+	tests := []struct {
+		attempt  int
+		retry    time.Duration
+		expected time.Duration
+		ok       bool
+	}{
+		{1, 0, 500 * time.Millisecond, true},
+		{2, 0, time.Second, true},
+		{3, 0, 2 * time.Second, true},
+		{4, 0, 4 * time.Second, true},
+		{5, 0, 5 * time.Second, true},
+		{6, 0, 5 * time.Second, true},
+		{7, 0, 0, false},
+		{1, 3 * time.Second, 1500 * time.Millisecond, true},
+		{2, 3 * time.Second, 3 * time.Second, true},
+		{3, 3 * time.Second, 5 * time.Second, true},
+		{1, 30 * time.Second, 15 * time.Second, true},
+		{3, 30 * time.Second, 15 * time.Second, true},
+	}
+
+	for _, tt := range tests {
+		delay, ok := policy.NextDelay(tt.attempt, tt.retry)
+		if ok != tt.ok || delay != tt.expected {
+			t.Errorf("NextDelay(%d, %v) = (%v, %v), want (%v, %v)",
+				tt.attempt, tt.retry, delay, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestExponentialBackoff_UncappedDoesNotOverflow(t *testing.T) {
+	policy := &ExponentialBackoff{
+		InitialDelay: time.Second,
+		Multiplier:   2,
+		rand:         func() float64 { return 0.999999 },
+	}
+
+	for _, attempt := range []int{1, 30, 62, 63, 64, 100, 1000, 100000} {
+		delay, ok := policy.NextDelay(attempt, 0)
+		if !ok || delay <= 0 || delay > uncappedDelay {
+			t.Errorf("attempt %d: Expected a positive delay up to %v, got %v", attempt, uncappedDelay, delay)
+		}
+	}
+
+	if delay, _ := policy.NextDelay(100, math.MaxInt64); delay <= 0 || delay > uncappedDelay {
+		t.Errorf("Expected a huge retry hint capped at %v, got %v", uncappedDelay, delay)
+	}
+}
+
+func TestExponentialBackoff_FullJitter(t *testing.T) {
+	policy := NewExponentialBackoff()
+
+	for i := 0; i < 100; i++ {
+		delay, ok := policy.NextDelay(3, 0)
+		if !ok {
+			t.Fatal("Expected unlimited attempts")
+		}
+		if delay < 0 || delay >= 4*time.Second {
+			t.Fatalf("Expected delay in [0, 4s), got %v", delay)
+		}
+	}
+}
+
+func TestSSEConsumer_GivesUpAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := &ExponentialBackoff{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 2, MaxAttempts: 3}
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore(), WithReconnectPolicy(policy))
+
+	err := c.Start(context.Background())
+	if !errors.Is(err, ErrReconnectLimit) {
+		t.Fatalf("Expected ErrReconnectLimit, got %v", err)
+	}
+
+	if reconnects := c.Status().Reconnects; reconnects != 4 {
+		t.Errorf("Expected 4 failed connections, got %d", reconnects)
+	}
+}
+
+func TestSSEConsumer_StopsWaitingOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := &ExponentialBackoff{InitialDelay: time.Hour, MaxDelay: time.Hour, Multiplier: 2}
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore(), WithReconnectPolicy(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := c.Start(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Start to return promptly, took %v", elapsed)
+	}
+}
@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultCheckpointInterval is the least time between saves of the last
+// event ID while events keep arriving
+const DefaultCheckpointInterval = time.Second
+
+// Checkpoint persists the last seen event ID so a restarted consumer
+// can resume the stream where it left off
+type Checkpoint interface {
+	// Load returns the saved event ID, or "" if none has been saved
+	Load() (string, error)
+
+	// Save records id as the last seen event ID
+	Save(id string) error
+}
+
+// FileCheckpoint stores the last event ID in a file
+type FileCheckpoint struct {
+	path string
+}
+
+// NewFileCheckpoint creates a checkpoint backed by the file at path
+func NewFileCheckpoint(path string) *FileCheckpoint {
+	return &FileCheckpoint{path: path}
+}
+
+// Load reads the saved event ID from disk
+func (f *FileCheckpoint) Load() (string, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// Save atomically and durably replaces the checkpoint file with id
+func (f *FileCheckpoint) Save(id string) error {
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(id + "\n"); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	// Flush to disk before the rename, so a crash can't leave an empty file
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
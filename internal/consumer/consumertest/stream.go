@@ -0,0 +1,350 @@
+// Package consumertest provides a fake upstream score stream for tests
+// and local runs. It speaks the same text/event-stream dialect as the live
+// upstream and can be made to misbehave: malformed events, connections
+// dropped mid-event and writes trickled out a few bytes at a time.
+package consumertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Distribution draws the scores of generated events
+type Distribution interface {
+	Score(r *rand.Rand) float64
+}
+
+// Uniform draws scores evenly from [Min, Max)
+type Uniform struct {
+	Min, Max float64
+}
+
+// Score implements Distribution
+func (u Uniform) Score(r *rand.Rand) float64 {
+	return u.Min + r.Float64()*(u.Max-u.Min)
+}
+
+// Normal draws scores around Mean, clamped to [0,1] like real exam results
+type Normal struct {
+	Mean, StdDev float64
+}
+
+// Score implements Distribution
+func (n Normal) Score(r *rand.Rand) float64 {
+	return math.Min(1, math.Max(0, n.Mean+r.NormFloat64()*n.StdDev))
+}
+
+// Malformed kinds, in the order they are picked from
+const (
+	MalformedJSON       = "invalid_json"
+	MalformedNoStudent  = "missing_student_id"
+	MalformedOutOfRange = "score_out_of_range"
+	MalformedWrongType  = "wrong_type"
+)
+
+var malformedKinds = []string{MalformedJSON, MalformedNoStudent, MalformedOutOfRange, MalformedWrongType}
+
+// Stats counts what a Stream has served
+type Stats struct {
+	Connections int64 `json:"connections"`
+	EventsSent  int64 `json:"eventsSent"`
+	Malformed   int64 `json:"malformed"`
+	Disconnects int64 `json:"disconnects"` // connections cut mid-event
+}
+
+// Stream is an http.Handler serving score events. Event n, counting from
+// 1, is the same on every connection, so a client resuming with
+// Last-Event-ID sees the stream continue where it left off.
+type Stream struct {
+	rate            float64
+	students        int
+	exams           int
+	distribution    Distribution
+	malformed       float64
+	disconnectAfter int
+	dripSize        int
+	dripDelay       time.Duration
+	retry           time.Duration
+	maxEvents       int64
+	seed            uint64
+
+	connections atomic.Int64
+	eventsSent  atomic.Int64
+	malformedN  atomic.Int64
+	disconnects atomic.Int64
+}
+
+// Option configures a Stream
+type Option func(*Stream)
+
+// WithRate sets the events sent per second on each connection. The
+// default, 0, sends them as fast as the client reads.
+func WithRate(perSecond float64) Option {
+	return func(s *Stream) {
+		s.rate = perSecond
+	}
+}
+
+// WithCardinality sets how many distinct students and exams events are
+// drawn from. The defaults are 50 students and 10 exams.
+func WithCardinality(students, exams int) Option {
+	return func(s *Stream) {
+		s.students = students
+		s.exams = exams
+	}
+}
+
+// WithDistribution sets how scores are drawn. The default is
+// Normal{Mean: 0.75, StdDev: 0.15}.
+func WithDistribution(distribution Distribution) Option {
+	return func(s *Stream) {
+		s.distribution = distribution
+	}
+}
+
+// WithMalformed makes fraction of the events, between 0 and 1, malformed:
+// invalid JSON, without a student, with a score outside [0,1] or with a
+// field of the wrong type
+func WithMalformed(fraction float64) Option {
+	return func(s *Stream) {
+		s.malformed = fraction
+	}
+}
+
+// WithDisconnectAfter cuts every connection after n events, in the middle
+// of writing the next one. The default, 0, never disconnects.
+func WithDisconnectAfter(n int) Option {
+	return func(s *Stream) {
+		s.disconnectAfter = n
+	}
+}
+
+// WithSlowDrip writes events size bytes at a time, flushing and waiting
+// delay between writes, so the client reads events split across reads
+func WithSlowDrip(size int, delay time.Duration) Option {
+	return func(s *Stream) {
+		s.dripSize = size
+		s.dripDelay = delay
+	}
+}
+
+// WithRetry sends a retry: field asking clients to wait d before
+// reconnecting
+func WithRetry(d time.Duration) Option {
+	return func(s *Stream) {
+		s.retry = d
+	}
+}
+
+// WithMaxEvents ends the stream after event n: connections stay open but
+// idle, like an upstream with nothing new to send. The default, 0, never
+// ends.
+func WithMaxEvents(n int64) Option {
+	return func(s *Stream) {
+		s.maxEvents = n
+	}
+}
+
+// WithSeed sets the seed events are generated from. Streams with the same
+// seed and options send the same events.
+func WithSeed(seed uint64) Option {
+	return func(s *Stream) {
+		s.seed = seed
+	}
+}
+
+// NewStream creates a fake score stream
+func NewStream(opts ...Option) *Stream {
+	s := &Stream{
+		students:     50,
+		exams:        10,
+		distribution: Normal{Mean: 0.75, StdDev: 0.15},
+		seed:         1,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// NewServer starts an httptest.Server serving a stream with opts at
+// every path. The caller must Close it.
+func NewServer(opts ...Option) (*httptest.Server, *Stream) {
+	stream := NewStream(opts...)
+	return httptest.NewServer(stream), stream
+}
+
+// Stats returns what the stream has served so far
+func (s *Stream) Stats() Stats {
+	return Stats{
+		Connections: s.connections.Load(),
+		EventsSent:  s.eventsSent.Load(),
+		Malformed:   s.malformedN.Load(),
+		Disconnects: s.disconnects.Load(),
+	}
+}
+
+// Event returns the data of event n and, if it is malformed, which way
+func (s *Stream) Event(n int64) (data string, malformed string) {
+	r := rand.New(rand.NewPCG(s.seed, uint64(n)))
+
+	student := s.studentID(r.IntN(max(s.students, 1)))
+	exam := 1 + r.IntN(max(s.exams, 1))
+	score := s.distribution.Score(r)
+
+	if s.malformed > 0 && r.Float64() < s.malformed {
+		malformed = malformedKinds[r.IntN(len(malformedKinds))]
+		switch malformed {
+		case MalformedJSON:
+			return fmt.Sprintf(`{"exam":%d,"studentId":"%s","score":`, exam, student), malformed
+		case MalformedNoStudent:
+			return fmt.Sprintf(`{"exam":%d,"score":%s}`, exam, formatScore(score)), malformed
+		case MalformedOutOfRange:
+			return fmt.Sprintf(`{"exam":%d,"studentId":"%s","score":%s}`, exam, student, formatScore(1+score*99)), malformed
+		case MalformedWrongType:
+			return fmt.Sprintf(`{"exam":"%d","studentId":"%s","score":%s}`, exam, student, formatScore(score)), malformed
+		}
+	}
+
+	encoded, _ := json.Marshal(struct {
+		Exam      int     `json:"exam"`
+		StudentID string  `json:"studentId"`
+		Score     float64 `json:"score"`
+	}{exam, student, score})
+	return string(encoded), ""
+}
+
+// ServeHTTP implements http.Handler
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.connections.Add(1)
+
+	// Resume after the last event the client saw
+	var next int64 = 1
+	if id, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil && id > 0 {
+		next = id + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	if s.retry > 0 {
+		fmt.Fprintf(w, "retry: %d\n\n", s.retry.Milliseconds())
+	}
+	rc.Flush()
+
+	var interval time.Duration
+	if s.rate > 0 {
+		interval = time.Duration(float64(time.Second) / s.rate)
+	}
+
+	ctx := r.Context()
+	for sent := 0; ; sent++ {
+		if s.maxEvents > 0 && next > s.maxEvents {
+			<-ctx.Done()
+			return
+		}
+
+		data, malformed := s.Event(next)
+		event := encodeEvent(next, data)
+
+		if s.disconnectAfter > 0 && sent == s.disconnectAfter {
+			s.disconnects.Add(1)
+			s.write(w, rc, event[:len(event)/2])
+			return
+		}
+
+		if !s.write(w, rc, event) {
+			return
+		}
+		s.eventsSent.Add(1)
+		if malformed != "" {
+			s.malformedN.Add(1)
+		}
+		next++
+
+		if interval > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}
+}
+
+// write sends p, in drip-sized pieces if set, reporting whether the client
+// is still there
+func (s *Stream) write(w http.ResponseWriter, rc *http.ResponseController, p []byte) bool {
+	size := len(p)
+	if s.dripSize > 0 {
+		size = s.dripSize
+	}
+
+	for len(p) > 0 {
+		n := min(size, len(p))
+		if _, err := w.Write(p[:n]); err != nil {
+			return false
+		}
+		if err := rc.Flush(); err != nil {
+			return false
+		}
+		p = p[n:]
+
+		if s.dripDelay > 0 && len(p) > 0 {
+			time.Sleep(s.dripDelay)
+		}
+	}
+	return true
+}
+
+// studentID names student i like the live upstream: First.Last, with a
+// number once the name combinations run out. Last names are interleaved so
+// a few students don't all share one.
+func (s *Stream) studentID(i int) string {
+	combinations := len(firstNames) * len(lastNames)
+	first, round := i%len(firstNames), (i/len(firstNames))%len(lastNames)
+	name := firstNames[first] + "." + lastNames[(first+round*5)%len(lastNames)]
+	if i >= combinations {
+		name += strconv.Itoa(i / combinations)
+	}
+	return name
+}
+
+// encodeEvent writes a score event in text/event-stream form
+func encodeEvent(id int64, data string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "id: %d\nevent: score\n", id)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// formatScore writes a score the way encoding/json would
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+var firstNames = []string{
+	"Alice", "Bob", "Carmen", "Dmitri", "Elena", "Farid", "Grace", "Hiro",
+	"Ines", "Jamal", "Kasey", "Lena", "Marco", "Noor", "Oscar", "Priya",
+}
+
+var lastNames = []string{
+	"Smith", "Jones", "Garcia", "Ivanova", "Okafor", "Nguyen", "Haddad", "Sato",
+	"Muller", "Rossi", "Kowalski", "Silva",
+}
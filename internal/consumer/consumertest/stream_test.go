@@ -0,0 +1,155 @@
+package consumertest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvents reads the id and data of every event in an SSE body
+func readEvents(t *testing.T, body io.Reader) (ids, data []string) {
+	t.Helper()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	return ids, data
+}
+
+func TestStream_EventsAreDeterministic(t *testing.T) {
+	a := NewStream(WithSeed(7), WithCardinality(3, 2), WithMalformed(0.5))
+	b := NewStream(WithSeed(7), WithCardinality(3, 2), WithMalformed(0.5))
+
+	students := make(map[string]bool)
+	malformed := 0
+	for n := int64(1); n <= 200; n++ {
+		data, kind := a.Event(n)
+		if again, againKind := b.Event(n); again != data || againKind != kind {
+			t.Fatalf("Expected event %d to match across streams, got %s and %s", n, data, again)
+		}
+		if kind != "" {
+			malformed++
+			continue
+		}
+
+		var event struct {
+			Exam      int     `json:"exam"`
+			StudentID string  `json:"studentId"`
+			Score     float64 `json:"score"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("Expected valid JSON for event %d, got %s: %v", n, data, err)
+		}
+		if event.Exam < 1 || event.Exam > 2 || event.Score < 0 || event.Score > 1 {
+			t.Errorf("Expected exam in [1,2] and score in [0,1], got %+v", event)
+		}
+		students[event.StudentID] = true
+	}
+
+	if len(students) != 3 {
+		t.Errorf("Expected 3 distinct students, got %d", len(students))
+	}
+	if malformed < 60 || malformed > 140 {
+		t.Errorf("Expected about half of 200 events malformed, got %d", malformed)
+	}
+}
+
+func TestStream_ResumesFromLastEventID(t *testing.T) {
+	server, stream := NewServer(WithMaxEvents(5), WithRetry(250*time.Millisecond))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Last-Event-ID", "2")
+	client := &http.Client{Timeout: 200 * time.Millisecond}
+
+	// The stream idles after the last event, so the read ends on timeout
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	ids, data := readEvents(t, resp.Body)
+	resp.Body.Close()
+
+	if strings.Join(ids, ",") != "3,4,5" {
+		t.Errorf("Expected events 3,4,5, got %v", ids)
+	}
+	if expected, _ := stream.Event(3); len(data) == 0 || data[0] != expected {
+		t.Errorf("Expected first event data %s, got %v", expected, data)
+	}
+	if stats := stream.Stats(); stats.Connections != 1 || stats.EventsSent != 3 {
+		t.Errorf("Expected 1 connection and 3 events sent, got %+v", stats)
+	}
+}
+
+func TestStream_DisconnectsMidEvent(t *testing.T) {
+	server, stream := NewServer(WithDisconnectAfter(2), WithSlowDrip(5, 0))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// Two whole events, then part of the third
+	if n := strings.Count(string(body), "\n\n"); n != 2 {
+		t.Errorf("Expected 2 complete events, got %d in %q", n, body)
+	}
+	if !strings.HasPrefix(string(body[strings.LastIndex(string(body), "\n\n")+2:]), "id: 3") {
+		t.Errorf("Expected a partial event 3 at the end, got %q", body)
+	}
+	if stats := stream.Stats(); stats.EventsSent != 2 || stats.Disconnects != 1 {
+		t.Errorf("Expected 2 events sent and 1 disconnect, got %+v", stats)
+	}
+}
+
+func TestStream_Rate(t *testing.T) {
+	server, _ := NewServer(WithRate(100), WithMaxEvents(10))
+	defer server.Close()
+
+	client := &http.Client{Timeout: 300 * time.Millisecond}
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	// Ten events at 100 per second take at least 90ms
+	scanner := bufio.NewScanner(resp.Body)
+	for events := 0; events < 10 && scanner.Scan(); {
+		if strings.HasPrefix(scanner.Text(), "id: ") {
+			events++
+		}
+	}
+	elapsed := time.Since(start)
+	resp.Body.Close()
+
+	if elapsed < 90*time.Millisecond {
+		t.Errorf("Expected 10 events to take at least 90ms, took %s", elapsed)
+	}
+}
+
+func TestStream_StudentIDsAreUnique(t *testing.T) {
+	s := NewStream()
+
+	seen := make(map[string]int)
+	for i := 0; i < 3*len(firstNames)*len(lastNames); i++ {
+		id := s.studentID(i)
+		if previous, ok := seen[id]; ok {
+			t.Fatalf("Expected unique student IDs, got %s for %d and %d", id, previous, i)
+		}
+		seen[id] = i
+	}
+}
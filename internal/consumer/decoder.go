@@ -0,0 +1,202 @@
+package consumer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultEventType is the type given to events that carry no event field
+const defaultEventType = "message"
+
+// scoreEventType is the type of events carrying a score
+const scoreEventType = "score"
+
+// utf8BOM is the byte order mark that may prefix an event stream
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Event represents a single dispatched Server-Sent Event
+type Event struct {
+	// ID is the last event ID in effect when the event was dispatched
+	ID string
+	// HasID reports whether the event set ID with its own id field, rather
+	// than carrying it over from an earlier event or connection
+	HasID bool
+	// Type is the event type, "message" when the stream did not set one
+	Type string
+	// Data is the event payload with multiple data lines joined by "\n"
+	Data string
+}
+
+// ownID returns the ID the event set with its own id field, or ""
+func (e Event) ownID() string {
+	if e.HasID {
+		return e.ID
+	}
+	return ""
+}
+
+// Decoder reads Server-Sent Events from a stream following the
+// text/event-stream interpretation rules of the HTML Living Standard
+type Decoder struct {
+	r *bufio.Reader
+
+	line    []byte
+	started bool // first line has been read and any BOM stripped
+	skipLF  bool // previous line ended with CR, so a leading LF is part of it
+
+	idBuffer    string
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewDecoder creates a new decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Decode reads the stream until the next event is dispatched.
+// It returns io.EOF once the stream ends; a partially received
+// event at the end of the stream is discarded.
+func (d *Decoder) Decode() (Event, error) {
+	var data strings.Builder
+	var eventType string
+	hasData, hasID := false, false
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		// Empty line dispatches the buffered event
+		if len(line) == 0 {
+			d.lastEventID = d.idBuffer
+			if !hasData {
+				eventType = ""
+				hasID = false
+				continue
+			}
+
+			if eventType == "" {
+				eventType = defaultEventType
+			}
+			payload := strings.TrimSuffix(data.String(), "\n")
+			return Event{
+				ID:    d.lastEventID,
+				HasID: hasID,
+				Type:  eventType,
+				Data:  payload,
+			}, nil
+		}
+
+		// Lines starting with a colon are comments
+		if line[0] == ':' {
+			continue
+		}
+
+		// A line without a colon is a field name with an empty value
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+
+		switch string(field) {
+		case "event":
+			eventType = string(value)
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.idBuffer = string(value)
+				hasID = true
+			}
+		case "retry":
+			if ms, ok := parseRetry(value); ok {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// LastEventID returns the ID of the most recently dispatched event
+func (d *Decoder) LastEventID() string {
+	return d.lastEventID
+}
+
+// SetLastEventID seeds the last event ID carried over from a previous
+// connection, so events without an id field keep reporting it
+func (d *Decoder) SetLastEventID(id string) {
+	d.idBuffer = id
+	d.lastEventID = id
+}
+
+// Retry returns the reconnection time most recently requested by the
+// stream, or zero if the stream has not sent a retry field
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+// readLine returns the next line without its terminator.
+// Lines may end in CRLF, LF or a lone CR.
+func (d *Decoder) readLine() ([]byte, error) {
+	d.line = d.line[:0]
+
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if d.skipLF {
+			d.skipLF = false
+			if b == '\n' {
+				continue
+			}
+		}
+
+		switch b {
+		case '\r':
+			d.skipLF = true
+			return d.trimBOM(), nil
+		case '\n':
+			return d.trimBOM(), nil
+		}
+
+		d.line = append(d.line, b)
+	}
+}
+
+// trimBOM strips a byte order mark from the first line of the stream
+func (d *Decoder) trimBOM() []byte {
+	if d.started {
+		return d.line
+	}
+	d.started = true
+	return bytes.TrimPrefix(d.line, utf8BOM)
+}
+
+// parseRetry parses a retry field value, which must be ASCII digits only
+func parseRetry(value []byte) (int64, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+	for _, b := range value {
+		if b < '0' || b > '9' {
+			return 0, false
+		}
+	}
+	ms, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return ms, true
+}
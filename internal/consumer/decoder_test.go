@@ -0,0 +1,214 @@
+package consumer
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeAll(t *testing.T, input string) ([]Event, *Decoder) {
+	t.Helper()
+
+	decoder := NewDecoder(strings.NewReader(input))
+	var events []Event
+	for {
+		event, err := decoder.Decode()
+		if err == io.EOF {
+			return events, decoder
+		}
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		events []Event
+	}{
+		{
+			name:   "single score event",
+			input:  "event: score\ndata: {\"exam\":1}\n\n",
+			events: []Event{{Type: "score", Data: `{"exam":1}`}},
+		},
+		{
+			name:   "default event type",
+			input:  "data: hello\n\n",
+			events: []Event{{Type: "message", Data: "hello"}},
+		},
+		{
+			name:   "multi-line data is joined with LF",
+			input:  "event: score\ndata: {\"exam\":1,\ndata: \"score\":0.5}\n\n",
+			events: []Event{{Type: "score", Data: "{\"exam\":1,\n\"score\":0.5}"}},
+		},
+		{
+			name:   "only one leading space is removed",
+			input:  "data:  two spaces \ndata:none\n\n",
+			events: []Event{{Type: "message", Data: " two spaces \nnone"}},
+		},
+		{
+			name:   "event type is not trimmed",
+			input:  "event:score \ndata: x\n\n",
+			events: []Event{{Type: "score ", Data: "x"}},
+		},
+		{
+			name:   "comments are ignored",
+			input:  ": keep-alive\nevent: score\n:another comment\ndata: x\n\n",
+			events: []Event{{Type: "score", Data: "x"}},
+		},
+		{
+			name:   "CRLF line endings",
+			input:  "event: score\r\ndata: x\r\n\r\n",
+			events: []Event{{Type: "score", Data: "x"}},
+		},
+		{
+			name:   "CR line endings",
+			input:  "event: score\rdata: x\r\rdata: y\r\r",
+			events: []Event{{Type: "score", Data: "x"}, {Type: "message", Data: "y"}},
+		},
+		{
+			name:   "mixed line endings",
+			input:  "data: a\r\ndata: b\rdata: c\n\r\n",
+			events: []Event{{Type: "message", Data: "a\nb\nc"}},
+		},
+		{
+			name:   "leading BOM is stripped",
+			input:  "\xEF\xBB\xBFevent: score\ndata: x\n\n",
+			events: []Event{{Type: "score", Data: "x"}},
+		},
+		{
+			name:   "BOM after the first line is not stripped",
+			input:  "data: a\n\n\xEF\xBB\xBFdata: b\n\n",
+			events: []Event{{Type: "message", Data: "a"}},
+		},
+		{
+			name:   "field without colon has empty value",
+			input:  "data\ndata\n\n",
+			events: []Event{{Type: "message", Data: "\n"}},
+		},
+		{
+			name:   "single empty data field dispatches empty event",
+			input:  "event: score\ndata\n\n",
+			events: []Event{{Type: "score", Data: ""}},
+		},
+		{
+			name:   "event without data is not dispatched",
+			input:  "event: score\n\ndata: x\n\n",
+			events: []Event{{Type: "message", Data: "x"}},
+		},
+		{
+			name:   "unknown fields are ignored",
+			input:  "foo: bar\ndata: x\n\n",
+			events: []Event{{Type: "message", Data: "x"}},
+		},
+		{
+			name:   "id is carried to later events",
+			input:  "id: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\n",
+			events: []Event{{ID: "1", HasID: true, Type: "message", Data: "a"}, {ID: "1", Type: "message", Data: "b"}, {ID: "", HasID: true, Type: "message", Data: "c"}},
+		},
+		{
+			name:   "id containing NULL is ignored",
+			input:  "id: 1\ndata: a\n\nid: 2\x003\ndata: b\n\n",
+			events: []Event{{ID: "1", HasID: true, Type: "message", Data: "a"}, {ID: "1", Type: "message", Data: "b"}},
+		},
+		{
+			name:   "id of an undispatched event is carried without HasID",
+			input:  "id: 1\n\ndata: a\n\n",
+			events: []Event{{ID: "1", Type: "message", Data: "a"}},
+		},
+		{
+			name:   "incomplete event at EOF is discarded",
+			input:  "data: a\n\ndata: b\n",
+			events: []Event{{Type: "message", Data: "a"}},
+		},
+		{
+			name:   "unterminated line at EOF is discarded",
+			input:  "data: a\n\ndata: b",
+			events: []Event{{Type: "message", Data: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, _ := decodeAll(t, tt.input)
+
+			if len(events) != len(tt.events) {
+				t.Fatalf("Expected %d events, got %d: %+v", len(tt.events), len(events), events)
+			}
+			for i := range events {
+				if events[i] != tt.events[i] {
+					t.Errorf("Event %d: expected %+v, got %+v", i, tt.events[i], events[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_LastEventID(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"no id", "data: a\n\n", ""},
+		{"id on dispatched event", "id: 7\ndata: a\n\n", "7"},
+		{"id on event without data", "data: a\n\nid: 8\n\n", "8"},
+		{"id on incomplete event", "id: 7\ndata: a\n\nid: 9\ndata: b\n", "7"},
+		{"id reset", "id: 7\ndata: a\n\nid:\n\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, decoder := decodeAll(t, tt.input)
+			if decoder.LastEventID() != tt.expected {
+				t.Errorf("Expected last event ID %q, got %q", tt.expected, decoder.LastEventID())
+			}
+		})
+	}
+}
+
+func TestDecoder_Retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+	}{
+		{"no retry", "data: a\n\n", 0},
+		{"valid retry", "retry: 2500\n\n", 2500 * time.Millisecond},
+		{"retry without event", "retry: 1000\n", time.Second},
+		{"non-digit retry ignored", "retry: 1000\n\nretry: 10s\n\n", time.Second},
+		{"negative retry ignored", "retry: -5\n\n", 0},
+		{"empty retry ignored", "retry\n\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, decoder := decodeAll(t, tt.input)
+			if decoder.Retry() != tt.expected {
+				t.Errorf("Expected retry %v, got %v", tt.expected, decoder.Retry())
+			}
+		})
+	}
+}
+
+func TestEventTypeLabel(t *testing.T) {
+	tests := []struct {
+		eventType string
+		expected  string
+	}{
+		{"score", "score"},
+		{"message", "message"},
+		{"heartbeat", "other"},
+		{"score-7f3a9c", "other"},
+	}
+
+	for _, tt := range tests {
+		if got := eventTypeLabel(tt.eventType); got != tt.expected {
+			t.Errorf("%s: Expected %s, got %s", tt.eventType, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Group runs several SSE consumers, one per upstream source, side by side
+type Group struct {
+	consumers []*SSEConsumer
+}
+
+// NewGroup creates a group of consumers
+func NewGroup(consumers ...*SSEConsumer) *Group {
+	return &Group{consumers: consumers}
+}
+
+// Consumers returns the consumers in the group, in the order given
+func (g *Group) Consumers() []*SSEConsumer {
+	return g.consumers
+}
+
+// Start runs every consumer in its own goroutine and returns once all of
+// them have stopped. A consumer giving up doesn't stop the others; the
+// errors of those that failed are returned together.
+func (g *Group) Start(ctx context.Context) error {
+	errs := make([]error, len(g.consumers))
+
+	var wg sync.WaitGroup
+	for i, c := range g.consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				errs[i] = fmt.Errorf("source %s: %w", c.source, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Sources returns the status of every consumer, in the order given
+func (g *Group) Sources() []Status {
+	statuses := make([]Status, len(g.consumers))
+	for i, c := range g.consumers {
+		statuses[i] = c.Status()
+	}
+	return statuses
+}
@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"channel-test/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_RunsEverySource(t *testing.T) {
+	// Each server sends one event per connection; a failing server
+	// refuses every connection after the first
+	newServer := func(student string, failing bool) *httptest.Server {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) > 1 && failing {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(w, "id: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":%q,\"score\":0.5}\n\n", student)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	// The first source gives up after one failed reconnect; the second
+	// keeps reconnecting until the group is cancelled
+	s := store.NewMemoryStore(store.WithDedup(time.Minute, 100))
+	once := &ExponentialBackoff{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 2, MaxAttempts: 1}
+	forever := &ExponentialBackoff{InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 2}
+	group := NewGroup(
+		NewSSEConsumer(newServer("alice", true).URL, s, WithSource("primary"), WithReconnectPolicy(once)),
+		NewSSEConsumer(newServer("bob", false).URL, s, WithSource("partner"), WithReconnectPolicy(forever)),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- group.Start(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Stats().Students < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected group to stop after cancel")
+	}
+
+	// Only the source that gave up is reported
+	if !errors.Is(err, ErrReconnectLimit) || !strings.Contains(err.Error(), "source primary") || strings.Contains(err.Error(), "partner") {
+		t.Errorf("Expected reconnect limit error for source primary only, got %v", err)
+	}
+
+	statuses := group.Sources()
+	if len(statuses) != 2 || statuses[0].Source != "primary" || statuses[1].Source != "partner" {
+		t.Fatalf("Expected statuses for primary and partner, got %+v", statuses)
+	}
+	for _, status := range statuses {
+		if status.ScoresAccepted != 1 || status.Reconnects == 0 || status.LastError == "" {
+			t.Errorf("%s: Expected 1 accepted score and a reconnect with its error, got %+v", status.Source, status)
+		}
+	}
+}
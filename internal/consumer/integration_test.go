@@ -0,0 +1,138 @@
+package consumer
+
+import (
+	"channel-test/internal/consumer/consumertest"
+	"channel-test/internal/deadletter"
+	"channel-test/internal/store"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fastBackoff reconnects almost at once so tests aren't slowed by backoff
+func fastBackoff() *ExponentialBackoff {
+	return &ExponentialBackoff{InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 2}
+}
+
+// runUntil starts c and stops it once its last event ID reaches lastID,
+// failing the test if that takes too long
+func runUntil(t *testing.T, c *SSEConsumer, lastID int64) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for c.LastEventID() != strconv.FormatInt(lastID, 10) {
+		if time.Now().After(deadline) {
+			cancel()
+			<-done
+			t.Fatalf("Expected to reach event %d, stopped at %q", lastID, c.LastEventID())
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected consumer to stop on cancel, got %v", err)
+	}
+}
+
+func TestSSEConsumer_FakeStream_ReconnectsMidEvent(t *testing.T) {
+	server, stream := consumertest.NewServer(
+		consumertest.WithDisconnectAfter(7),
+		consumertest.WithMaxEvents(50),
+		consumertest.WithRetry(time.Millisecond),
+	)
+	defer server.Close()
+
+	s := store.NewMemoryStore()
+	c := NewSSEConsumer(server.URL, s, WithReconnectPolicy(fastBackoff()))
+	runUntil(t, c, 50)
+
+	// Every event arrives exactly once despite the cut-off events
+	status := c.Status()
+	if status.ScoresAccepted != 50 || status.ScoresRejected != 0 {
+		t.Errorf("Expected 50 accepted and no rejected scores, got %+v", status)
+	}
+	if got := s.Stats().Scores; got != 50 {
+		t.Errorf("Expected 50 stored scores, got %d", got)
+	}
+
+	stats := stream.Stats()
+	if stats.Disconnects < 7 || status.Reconnects < stats.Disconnects {
+		t.Errorf("Expected at least 7 disconnects, each followed by a reconnect, got %+v and %d reconnects", stats, status.Reconnects)
+	}
+}
+
+func TestSSEConsumer_FakeStream_RejectsMalformedEvents(t *testing.T) {
+	server, stream := consumertest.NewServer(
+		consumertest.WithMalformed(0.3),
+		consumertest.WithMaxEvents(200),
+		consumertest.WithSeed(42),
+	)
+	defer server.Close()
+
+	queue := deadletter.NewQueue(200)
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore(), WithDeadLetterQueue(queue), WithReconnectPolicy(fastBackoff()))
+	runUntil(t, c, 200)
+
+	stats := stream.Stats()
+	if stats.Malformed == 0 {
+		t.Fatal("Expected the stream to send malformed events")
+	}
+
+	status := c.Status()
+	if status.ScoresRejected != stats.Malformed || status.ScoresAccepted != 200-stats.Malformed {
+		t.Errorf("Expected %d rejected and %d accepted, got %+v", stats.Malformed, 200-stats.Malformed, status)
+	}
+
+	// Each rejection is dead-lettered with the reason the stream broke it by
+	counts := queue.Counts()
+	for n := int64(1); n <= 200; n++ {
+		_, kind := stream.Event(n)
+		if kind == consumertest.MalformedWrongType {
+			kind = ReasonInvalidJSON
+		}
+		if kind != "" {
+			counts[kind]--
+		}
+	}
+	for reason, n := range counts {
+		if n != 0 {
+			t.Errorf("%s: Expected dead-letter count to match the stream, off by %d", reason, n)
+		}
+	}
+}
+
+func TestSSEConsumer_FakeStream_SlowDrip(t *testing.T) {
+	server, stream := consumertest.NewServer(
+		consumertest.WithSlowDrip(3, 100*time.Microsecond),
+		consumertest.WithDisconnectAfter(4),
+		consumertest.WithMaxEvents(12),
+		consumertest.WithRetry(time.Millisecond),
+	)
+	defer server.Close()
+
+	s := store.NewMemoryStore()
+	c := NewSSEConsumer(server.URL, s, WithReconnectPolicy(fastBackoff()))
+	runUntil(t, c, 12)
+
+	if status := c.Status(); status.ScoresAccepted != 12 {
+		t.Errorf("Expected 12 accepted scores, got %+v", status)
+	}
+
+	// Events split across many reads are stored as sent
+	data, _ := stream.Event(12)
+	event, reject := ParseScoreEvent(data, nil)
+	if reject != nil {
+		t.Fatalf("Expected event 12 to be valid, got %v", reject)
+	}
+	history, err := s.GetScoreHistory(event.StudentID, event.Exam)
+	if err != nil || len(history) == 0 || history[len(history)-1].Score != event.Score {
+		t.Errorf("Expected score %v for %s on exam %d, got %+v, %v", event.Score, event.StudentID, event.Exam, history, err)
+	}
+}
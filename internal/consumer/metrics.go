@@ -0,0 +1,55 @@
+package consumer
+
+import (
+	"channel-test/internal/metrics"
+	"channel-test/internal/validation"
+	"fmt"
+)
+
+// Reasons a score event is rejected, used as the metrics reason label.
+// Events breaking a validation rule are rejected with the reason of the
+// first violation, such as validation.ReasonExamOutOfRange.
+const (
+	ReasonInvalidJSON      = "invalid_json"
+	ReasonMissingStudentID = "missing_student_id"
+	ReasonScoreOutOfRange  = validation.ReasonScoreOutOfRange
+	ReasonStoreError       = "store_error"
+)
+
+// RejectError describes why a score event was rejected
+type RejectError struct {
+	Reason  string
+	Message string
+
+	// Violations lists every validation rule the event broke
+	Violations []validation.Violation
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Message)
+}
+
+var (
+	connectedGauge = metrics.Default.NewGaugeVec("scores_sse_connected",
+		"Whether the SSE consumer is connected to the upstream stream (1) or not (0), by source.", "source")
+	reconnectsTotal = metrics.Default.NewCounterVec("scores_sse_reconnects_total",
+		"Connections to the upstream stream that failed or dropped and were retried, by source.", "source")
+	eventsReceived = metrics.Default.NewCounterVec("scores_sse_events_received_total",
+		"Events decoded from the upstream stream, by source and event type (score, message or other).", "source", "type")
+	eventsParsed = metrics.Default.NewCounterVec("scores_events_parsed_total",
+		"Score events that were decoded and passed validation, by source.", "source")
+	eventsRejected = metrics.Default.NewCounterVec("scores_events_rejected_total",
+		"Score events that were dropped, by source and reason.", "source", "reason")
+	violationsTotal = metrics.Default.NewCounterVec("scores_events_violations_total",
+		"Validation rules broken by upstream score events, by source and rule.", "source", "rule")
+)
+
+// eventTypeLabel maps an upstream event type to the types the consumer
+// knows, or "other", so the upstream cannot create a series per type
+func eventTypeLabel(eventType string) string {
+	switch eventType {
+	case scoreEventType, defaultEventType:
+		return eventType
+	}
+	return "other"
+}
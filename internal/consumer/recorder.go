@@ -0,0 +1,253 @@
+package consumer
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Recorder receives every event read from the stream, before it is
+// parsed, so the exact upstream data can be inspected or replayed
+type Recorder interface {
+	Record(event RecordedEvent) error
+}
+
+// RecordedEvent is an event as received from the stream. ID is set only
+// when the event carried its own id field. Its JSON form is the envelope
+// read by scores-cli replay.
+type RecordedEvent struct {
+	ID           string    `json:"id"`
+	Event        string    `json:"event"`
+	Data         string    `json:"data"`
+	ReceivedAt   time.Time `json:"receivedAt"`
+	ConnectionID string    `json:"connectionId"`
+	Source       string    `json:"source,omitempty"`
+}
+
+const (
+	// DefaultSegmentSize is the size at which a FileRecorder starts a new segment
+	DefaultSegmentSize = 64 << 20
+	// DefaultSegmentAge is how long a FileRecorder writes to one segment
+	DefaultSegmentAge = time.Hour
+
+	segmentPrefix     = "events-"
+	segmentExt        = ".ndjson"
+	segmentTimeLayout = "20060102T150405.000000000Z"
+)
+
+// FileRecorder writes recorded events as NDJSON to segment files in a
+// directory. A segment is closed once it reaches its size or age limit and
+// then gzipped in the background; segments left uncompressed by an earlier
+// process are gzipped when the recorder is created.
+type FileRecorder struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	logger  *slog.Logger
+	now     func() time.Time
+
+	mu       sync.Mutex
+	segment  *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+
+	compressing sync.WaitGroup
+}
+
+// RecorderOption configures a FileRecorder
+type RecorderOption func(*FileRecorder)
+
+// WithSegmentSize sets the size in bytes at which a new segment is started.
+// The default is DefaultSegmentSize; zero disables size-based rotation.
+func WithSegmentSize(bytes int64) RecorderOption {
+	return func(r *FileRecorder) {
+		r.maxSize = bytes
+	}
+}
+
+// WithSegmentAge sets how long a segment is written to before a new one is
+// started. The default is DefaultSegmentAge; zero disables age-based rotation.
+func WithSegmentAge(age time.Duration) RecorderOption {
+	return func(r *FileRecorder) {
+		r.maxAge = age
+	}
+}
+
+// WithRecorderLogger sets the logger used to report compression failures.
+// The default is slog.Default().
+func WithRecorderLogger(logger *slog.Logger) RecorderOption {
+	return func(r *FileRecorder) {
+		r.logger = logger
+	}
+}
+
+// NewFileRecorder creates a recorder writing segments to dir, creating the
+// directory if needed
+func NewFileRecorder(dir string, opts ...RecorderOption) (*FileRecorder, error) {
+	r := &FileRecorder{
+		dir:     dir,
+		maxSize: DefaultSegmentSize,
+		maxAge:  DefaultSegmentAge,
+		logger:  slog.Default(),
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recorder directory: %w", err)
+	}
+
+	// Compress segments a previous process did not get to close
+	leftover, err := filepath.Glob(filepath.Join(dir, segmentPrefix+"*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range leftover {
+		r.compress(path)
+	}
+
+	return r, nil
+}
+
+// Record appends event to the current segment, rotating it first if it
+// has reached its size or age limit
+func (r *FileRecorder) Record(event RecordedEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errors.New("recorder is closed")
+	}
+
+	now := r.now()
+	if r.segment != nil && r.full(now) {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	if r.segment == nil {
+		if err := r.open(now); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.segment.Write(line)
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// Close closes the current segment and waits for every segment to be
+// compressed
+func (r *FileRecorder) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	var err error
+	if r.segment != nil {
+		err = r.rotate()
+	}
+	r.mu.Unlock()
+
+	r.compressing.Wait()
+	return err
+}
+
+// full reports whether the current segment has reached a rotation limit
+func (r *FileRecorder) full(now time.Time) bool {
+	if r.maxSize > 0 && r.size >= r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && now.Sub(r.openedAt) >= r.maxAge
+}
+
+// open starts a new segment named after the time it was opened
+func (r *FileRecorder) open(now time.Time) error {
+	name := segmentPrefix + now.UTC().Format(segmentTimeLayout) + segmentExt
+	f, err := os.OpenFile(filepath.Join(r.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+
+	r.segment = f
+	r.size = 0
+	r.openedAt = now
+	return nil
+}
+
+// rotate closes the current segment and compresses it in the background
+func (r *FileRecorder) rotate() error {
+	path := r.segment.Name()
+	err := r.segment.Close()
+	r.segment = nil
+	if err != nil {
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+
+	r.compress(path)
+	return nil
+}
+
+// compress gzips the closed segment at path in the background, replacing
+// it with path + ".gz"
+func (r *FileRecorder) compress(path string) {
+	r.compressing.Add(1)
+	go func() {
+		defer r.compressing.Done()
+		if err := gzipFile(path); err != nil {
+			r.logger.Error("Failed to compress recorded events", "file", path, "error", err)
+		}
+	}()
+}
+
+// gzipFile writes path + ".gz" through a temporary file and removes path
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".gz.tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	zw.Name = filepath.Base(path)
+	if _, err := io.Copy(zw, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
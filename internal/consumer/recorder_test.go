@@ -0,0 +1,184 @@
+package consumer
+
+import (
+	"bufio"
+	"channel-test/internal/store"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readSegments returns the events in every gzipped segment in dir, in order
+func readSegments(t *testing.T, dir string) [][]RecordedEvent {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	sort.Strings(paths)
+
+	var segments [][]RecordedEvent
+	for _, path := range paths {
+		if !strings.HasSuffix(path, segmentExt+".gz") {
+			t.Fatalf("Expected only gzipped segments, found %s", filepath.Base(path))
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("gzip.NewReader failed: %v", err)
+		}
+
+		var events []RecordedEvent
+		scanner := bufio.NewScanner(zr)
+		for scanner.Scan() {
+			var event RecordedEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Fatalf("Expected JSON line, got %q", scanner.Text())
+			}
+			events = append(events, event)
+		}
+		f.Close()
+		segments = append(segments, events)
+	}
+	return segments
+}
+
+func TestFileRecorder_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewFileRecorder(dir, WithSegmentSize(200), WithSegmentAge(0))
+	if err != nil {
+		t.Fatalf("NewFileRecorder failed: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		event := RecordedEvent{ID: fmt.Sprint(i), Event: "score", Data: `{"exam":1,"studentId":"alice","score":0.5}`, ConnectionID: "c1"}
+		if err := recorder.Record(event); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Each line is over 100 bytes, so segments hold two events
+	segments := readSegments(t, dir)
+	var sizes []int
+	var ids []string
+	for _, segment := range segments {
+		sizes = append(sizes, len(segment))
+		for _, event := range segment {
+			ids = append(ids, event.ID)
+		}
+	}
+	if fmt.Sprint(sizes) != "[2 2 1]" {
+		t.Errorf("Expected segments of [2 2 1] events, got %v", sizes)
+	}
+	if strings.Join(ids, ",") != "1,2,3,4,5" {
+		t.Errorf("Expected events 1-5 in order, got %v", ids)
+	}
+}
+
+func TestFileRecorder_RotatesByAge(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewFileRecorder(dir, WithSegmentSize(0), WithSegmentAge(time.Minute))
+	if err != nil {
+		t.Fatalf("NewFileRecorder failed: %v", err)
+	}
+
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	for _, offset := range []time.Duration{0, 30 * time.Second, 61 * time.Second, 90 * time.Second} {
+		now = time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC).Add(offset)
+		if err := recorder.Record(RecordedEvent{Event: "score", ReceivedAt: now}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	segments := readSegments(t, dir)
+	if len(segments) != 2 || len(segments[0]) != 2 || len(segments[1]) != 2 {
+		t.Errorf("Expected 2 segments of 2 events, got %v", segments)
+	}
+	if err := recorder.Record(RecordedEvent{}); err == nil {
+		t.Error("Expected error recording after Close")
+	}
+}
+
+func TestFileRecorder_CompressesLeftoverSegments(t *testing.T) {
+	dir := t.TempDir()
+	leftover := filepath.Join(dir, segmentPrefix+"20240102T150000.000000000Z"+segmentExt)
+	if err := os.WriteFile(leftover, []byte(`{"id":"1","event":"score","data":"x"}`+"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	recorder, err := NewFileRecorder(dir)
+	if err != nil {
+		t.Fatalf("NewFileRecorder failed: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	segments := readSegments(t, dir)
+	if len(segments) != 1 || len(segments[0]) != 1 || segments[0][0].ID != "1" {
+		t.Errorf("Expected the leftover segment compressed, got %v", segments)
+	}
+}
+
+// memoryRecorder keeps recorded events in memory
+type memoryRecorder struct {
+	mu     sync.Mutex
+	events []RecordedEvent
+}
+
+func (m *memoryRecorder) Record(event RecordedEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+func TestSSEConsumer_RecordsRawEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":1,\ndata: \"studentId\":\"alice\",\"score\":2}\n\nevent: ping\ndata: ok\n\n")
+	}))
+	defer server.Close()
+
+	recorder := &memoryRecorder{}
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore(), WithRecorder(recorder))
+	c.connect(context.Background(), "conn-1", c.logger)
+
+	if len(recorder.events) != 2 {
+		t.Fatalf("Expected 2 recorded events, got %d", len(recorder.events))
+	}
+
+	// Events are recorded as received, including ones rejected later
+	first := recorder.events[0]
+	if first.ID != "1" || first.Event != "score" || first.Data != "{\"exam\":1,\n\"studentId\":\"alice\",\"score\":2}" {
+		t.Errorf("Unexpected recorded event: %+v", first)
+	}
+	if first.ConnectionID != "conn-1" || first.ReceivedAt.IsZero() {
+		t.Errorf("Expected connection ID and receive time, got %+v", first)
+	}
+	if recorder.events[1].Event != "ping" {
+		t.Errorf("Expected ping event recorded, got %+v", recorder.events[1])
+	}
+}
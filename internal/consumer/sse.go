@@ -1,66 +1,225 @@
 package consumer
 
 import (
-	"bufio"
+	"channel-test/internal/deadletter"
+	"channel-test/internal/logging"
 	"channel-test/internal/store"
+	"channel-test/internal/validation"
 	"channel-test/pkg/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 )
 
 // SSEConsumer consumes Server-Sent Events from the test scores endpoint
 type SSEConsumer struct {
-	url    string
-	store  store.Store
-	client *http.Client
+	url         string
+	source      string
+	store       store.Store
+	client      *http.Client
+	header      http.Header
+	checkpoint  Checkpoint
+	saveEvery   time.Duration // least time between checkpoint saves
+	savedID     string        // event ID last saved through the checkpoint
+	savedAt     time.Time
+	recorder    Recorder
+	deadLetters *deadletter.Queue
+	validator   validation.Validator
+	policy      ReconnectPolicy
+	retry       time.Duration // reconnection time requested by the server
+	logger      *slog.Logger
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// defaultSource is the source name recorded with scores from the stream
+const defaultSource = "sse"
+
+// ErrReconnectLimit is returned by Start when the reconnect policy gives up
+var ErrReconnectLimit = errors.New("reconnect attempts exhausted")
+
+// Option configures an SSEConsumer
+type Option func(*SSEConsumer)
+
+// WithCheckpoint persists the last seen event ID so the stream can be
+// resumed with Last-Event-ID after a process restart
+func WithCheckpoint(checkpoint Checkpoint) Option {
+	return func(c *SSEConsumer) {
+		c.checkpoint = checkpoint
+	}
+}
+
+// WithCheckpointInterval sets the least time between saves of the last
+// event ID while events keep arriving; it is always saved when a
+// connection ends. Zero saves after every event. The default is
+// DefaultCheckpointInterval.
+func WithCheckpointInterval(interval time.Duration) Option {
+	return func(c *SSEConsumer) {
+		c.saveEvery = interval
+	}
+}
+
+// WithRecorder passes every event read from the stream, before it is
+// parsed, to recorder
+func WithRecorder(recorder Recorder) Option {
+	return func(c *SSEConsumer) {
+		c.recorder = recorder
+	}
+}
+
+// WithDeadLetterQueue keeps rejected score events, with the reason and raw
+// payload, in queue
+func WithDeadLetterQueue(queue *deadletter.Queue) Option {
+	return func(c *SSEConsumer) {
+		c.deadLetters = queue
+	}
+}
+
+// WithValidator sets the rules score events must pass before they are
+// stored. The default is validation.Default().
+func WithValidator(validator validation.Validator) Option {
+	return func(c *SSEConsumer) {
+		c.validator = validator
+	}
+}
+
+// WithSource sets the source name recorded with every stored score.
+// The default is "sse".
+func WithSource(name string) Option {
+	return func(c *SSEConsumer) {
+		c.source = name
+	}
+}
+
+// WithHeaders adds header to every request made to the stream, for
+// example to authenticate with the upstream
+func WithHeaders(header http.Header) Option {
+	return func(c *SSEConsumer) {
+		c.header = header.Clone()
+	}
+}
+
+// WithReconnectPolicy sets the policy used to space out reconnect
+// attempts. The default is NewExponentialBackoff().
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(c *SSEConsumer) {
+		c.policy = policy
+	}
+}
+
+// WithLogger sets the logger for connection and event logs. The default
+// is slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *SSEConsumer) {
+		c.logger = logger
+	}
 }
 
 // NewSSEConsumer creates a new SSE consumer
-func NewSSEConsumer(url string, store store.Store) *SSEConsumer {
-	return &SSEConsumer{
-		url:   url,
-		store: store,
+func NewSSEConsumer(url string, store store.Store, opts ...Option) *SSEConsumer {
+	c := &SSEConsumer{
+		url:    url,
+		source: defaultSource,
+		store:  store,
 		client: &http.Client{
 			Timeout: 0, // No timeout for SSE connections
 		},
+		policy:    NewExponentialBackoff(),
+		saveEvery: DefaultCheckpointInterval,
+		validator: validation.Default(),
+		logger:    slog.Default(),
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.status = Status{Source: c.source, URL: url}
+
+	return c
 }
 
-// Start begins consuming events from the SSE endpoint
+// Start begins consuming events from the SSE endpoint.
+// It reconnects according to the consumer's ReconnectPolicy and returns
+// when ctx is done or the policy gives up.
 func (c *SSEConsumer) Start(ctx context.Context) error {
+	now := time.Now()
+	c.mu.Lock()
+	c.status.StartedAt = &now
+	c.mu.Unlock()
+
+	if c.checkpoint != nil {
+		id, err := c.checkpoint.Load()
+		if err != nil {
+			c.logger.Error("Failed to load last event ID", "error", err)
+		} else if id != "" {
+			c.logger.Info("Resuming stream", logging.EventIDKey, id)
+			c.mu.Lock()
+			c.status.LastEventID = id
+			c.mu.Unlock()
+			c.savedID = id
+		}
+	}
+
+	attempt := 0
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("SSE consumer shutting down")
+		// Every connection attempt gets an ID carried by all of its logs
+		connectionID := logging.NewID()
+		logger := c.logger.With(logging.ConnectionIDKey, connectionID, "attempt", attempt+1)
+
+		err := c.connect(ctx, connectionID, logger)
+		if ctx.Err() != nil {
+			c.setDisconnected(nil)
+			logger.Info("SSE consumer shutting down")
 			return ctx.Err()
-		default:
-			if err := c.connect(ctx); err != nil {
-				if ctx.Err() != nil {
-					return ctx.Err()
-				}
-				log.Printf("Connection error: %v. Reconnecting in 5 seconds...", err)
-				time.Sleep(5 * time.Second)
-			}
+		}
+
+		// A successful connection starts the backoff over
+		if c.Status().Connected {
+			attempt = 0
+		}
+		c.setDisconnected(err)
+
+		attempt++
+		delay, ok := c.policy.NextDelay(attempt, c.retry)
+		if !ok {
+			logger.Error("Giving up reconnecting", "error", err)
+			return fmt.Errorf("%w after %d attempts: %v", ErrReconnectLimit, attempt-1, err)
+		}
+
+		logger.Warn("Connection error, reconnecting",
+			"error", err, "delay", delay.Round(time.Millisecond).String())
+		if err := sleepContext(ctx, delay); err != nil {
+			logger.Info("SSE consumer shutting down")
+			return err
 		}
 	}
 }
 
-func (c *SSEConsumer) connect(ctx context.Context) error {
+func (c *SSEConsumer) connect(ctx context.Context, connectionID string, logger *slog.Logger) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Connection", "keep-alive")
+	lastEventID := c.LastEventID()
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
 
+	logger.Debug("Connecting to SSE endpoint", "url", c.url, "lastEventId", lastEventID)
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
@@ -71,15 +230,18 @@ func (c *SSEConsumer) connect(ctx context.Context) error {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	log.Println("Connected to SSE endpoint")
+	logger.Info("Connected to SSE endpoint", "url", c.url, "lastEventId", lastEventID)
+	c.setConnected(connectionID)
 
-	return c.readEvents(ctx, resp.Body)
+	return c.readEvents(ctx, resp.Body, connectionID, logger)
 }
 
-func (c *SSEConsumer) readEvents(ctx context.Context, body io.ReadCloser) error {
-	reader := bufio.NewReader(body)
-	var eventType string
-	var data string
+func (c *SSEConsumer) readEvents(ctx context.Context, body io.Reader, connectionID string, logger *slog.Logger) error {
+	decoder := NewDecoder(body)
+	decoder.SetLastEventID(c.LastEventID())
+
+	// Whatever the last handled event was, resume after it next time
+	defer c.saveCheckpoint(true, logger)
 
 	for {
 		select {
@@ -88,58 +250,129 @@ func (c *SSEConsumer) readEvents(ctx context.Context, body io.ReadCloser) error
 		default:
 		}
 
-		line, err := reader.ReadString('\n')
+		event, err := decoder.Decode()
+		if retry := decoder.Retry(); retry > 0 {
+			c.retry = retry
+		}
 		if err != nil {
+			// Events with an ID but no data still move the stream on
+			c.recordEventID(decoder.LastEventID(), logger)
 			if err == io.EOF {
 				return fmt.Errorf("connection closed")
 			}
 			return fmt.Errorf("error reading stream: %w", err)
 		}
 
-		line = strings.TrimSpace(line)
-
-		// Empty line indicates end of event
-		if line == "" {
-			if eventType == "score" && data != "" {
-				c.processScoreEvent(data)
+		c.recordEvent(event.Type)
+		eventLogger := logger.With(logging.EventIDKey, event.ID)
+		if c.recorder != nil {
+			recorded := RecordedEvent{
+				ID:           event.ownID(),
+				Event:        event.Type,
+				Data:         event.Data,
+				ReceivedAt:   time.Now().UTC(),
+				ConnectionID: connectionID,
+				Source:       c.source,
+			}
+			if err := c.recorder.Record(recorded); err != nil {
+				eventLogger.Error("Failed to record event", "error", err)
 			}
-			eventType = ""
-			data = ""
-			continue
+		}
+		if event.Type == scoreEventType {
+			c.processScoreEvent(event, connectionID, eventLogger)
+		} else {
+			eventLogger.Debug("Ignored event", "type", event.Type)
 		}
 
-		// Parse SSE field
-		if strings.HasPrefix(line, "event:") {
-			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-		} else if strings.HasPrefix(line, "data:") {
-			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		// Only once the event is handled, so a crash before then
+		// receives it again on resume
+		c.recordEventID(event.ID, logger)
+	}
+}
+
+func (c *SSEConsumer) processScoreEvent(raw Event, connectionID string, logger *slog.Logger) {
+	event, err := ParseScoreEvent(raw.Data, c.validator)
+	if err != nil {
+		eventsRejected.With(c.source, err.Reason).Inc()
+		c.recordRejected()
+		attrs := []any{"reason", err.Reason, "error", err.Message}
+		if len(err.Violations) > 0 {
+			attrs = append(attrs, "violations", err.Violations)
 		}
+		for _, v := range err.Violations {
+			violationsTotal.With(c.source, v.Rule).Inc()
+		}
+		logger.Warn("Rejected score event", attrs...)
+		c.deadLetter(raw, connectionID, err)
+		return
+	}
+	eventsParsed.With(c.source).Inc()
+
+	// An ID carried over from an earlier event would make every later
+	// event without one a duplicate of it
+	event.Source = c.source
+	if event.ID == "" && raw.HasID {
+		event.ID = raw.ID
+	}
+
+	stored, storeErr := c.store.AddScore(event)
+	if storeErr != nil {
+		eventsRejected.With(c.source, ReasonStoreError).Inc()
+		c.recordRejected()
+		logger.Error("Failed to store score", "error", storeErr)
+		c.deadLetter(raw, connectionID, &RejectError{Reason: ReasonStoreError, Message: storeErr.Error()})
+		return
+	}
+	if !stored {
+		c.recordDuplicate()
+		logger.Debug("Ignored duplicate score", "studentId", event.StudentID, "exam", event.Exam, "score", event.Score)
+		return
+	}
+	c.recordAccepted()
+
+	logger.Debug("Stored score", "studentId", event.StudentID, "exam", event.Exam, "score", event.Score)
+}
+
+// deadLetter keeps a rejected event in the dead-letter queue, if any
+func (c *SSEConsumer) deadLetter(raw Event, connectionID string, reject *RejectError) {
+	if c.deadLetters == nil {
+		return
 	}
+	c.deadLetters.Add(deadletter.Entry{
+		Reason:       reject.Reason,
+		Message:      reject.Message,
+		Data:         raw.Data,
+		EventID:      raw.ID,
+		ConnectionID: connectionID,
+		Source:       c.source,
+	})
 }
 
-func (c *SSEConsumer) processScoreEvent(data string) {
+// ParseScoreEvent decodes the data of a score event and checks it with
+// validator, or validation.Default() if validator is nil. It is the
+// validation applied to every score the service ingests, whether from the
+// stream, a replay or the HTTP API.
+func ParseScoreEvent(data string, validator validation.Validator) (models.ScoreEvent, *RejectError) {
 	var event models.ScoreEvent
 	if err := json.Unmarshal([]byte(data), &event); err != nil {
-		log.Printf("Failed to parse score event: %v", err)
-		return
+		return event, &RejectError{Reason: ReasonInvalidJSON, Message: err.Error()}
 	}
 
 	// Validate the event
 	if event.StudentID == "" {
-		log.Printf("Invalid event: missing student ID")
-		return
+		return event, &RejectError{Reason: ReasonMissingStudentID, Message: "missing student ID"}
 	}
 
-	if event.Score < 0 || event.Score > 1 {
-		log.Printf("Invalid event: score out of range [0,1]: %f", event.Score)
-		return
+	if validator == nil {
+		validator = validation.Default()
 	}
-
-	if err := c.store.AddScore(event); err != nil {
-		log.Printf("Failed to store score: %v", err)
-		return
+	if violations := validator.Validate(event); len(violations) > 0 {
+		return event, &RejectError{
+			Reason:     violations[0].Reason,
+			Message:    validation.Summary(violations),
+			Violations: violations,
+		}
 	}
 
-	log.Printf("Stored score: student=%s, exam=%d, score=%.3f",
-		event.StudentID, event.Exam, event.Score)
+	return event, nil
 }
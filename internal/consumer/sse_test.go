@@ -0,0 +1,380 @@
+package consumer
+
+import (
+	"bytes"
+	"channel-test/internal/deadletter"
+	"channel-test/internal/logging"
+	"channel-test/internal/store"
+	"channel-test/internal/validation"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newScoreServer returns a server that sends one score event per request
+// with an incrementing id, recording each request's Last-Event-ID header
+func newScoreServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var headers []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Get("Last-Event-ID"))
+		n := len(headers)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "id: %d\nevent: score\ndata: {\"exam\":%d,\"studentId\":\"alice\",\"score\":0.5}\n\n", n, n)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), headers...)
+	}
+}
+
+func TestSSEConsumer_SendsLastEventIDOnReconnect(t *testing.T) {
+	server, headers := newScoreServer(t)
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore())
+
+	for i := 0; i < 2; i++ {
+		if err := c.connect(context.Background(), "test", c.logger); err == nil {
+			t.Fatal("Expected connection closed error")
+		}
+	}
+
+	got := headers()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(got))
+	}
+	if got[0] != "" {
+		t.Errorf("Expected no Last-Event-ID on first connect, got %q", got[0])
+	}
+	if got[1] != "1" {
+		t.Errorf("Expected Last-Event-ID 1 on reconnect, got %q", got[1])
+	}
+	if c.LastEventID() != "2" {
+		t.Errorf("Expected last event ID 2, got %q", c.LastEventID())
+	}
+	if c.Status().LastAcceptedAt == nil {
+		t.Error("Expected last accepted time to be set")
+	}
+}
+
+func TestSSEConsumer_ResumesFromCheckpoint(t *testing.T) {
+	checkpoint := NewFileCheckpoint(filepath.Join(t.TempDir(), "state", "last-event-id"))
+	if err := checkpoint.Save("41"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Last-Event-ID")
+		fmt.Fprint(w, "id: 42\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore(), WithCheckpoint(checkpoint))
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(ctx)
+	}()
+
+	if header := <-received; header != "41" {
+		t.Errorf("Expected Last-Event-ID 41, got %q", header)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.LastEventID() != "42" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	id, err := checkpoint.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if id != "42" {
+		t.Errorf("Expected checkpoint 42, got %q", id)
+	}
+}
+
+func TestFileCheckpoint_LoadMissing(t *testing.T) {
+	checkpoint := NewFileCheckpoint(filepath.Join(t.TempDir(), "missing"))
+
+	id, err := checkpoint.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if id != "" {
+		t.Errorf("Expected empty ID, got %q", id)
+	}
+}
+
+func TestSSEConsumer_LogsConnectionAndEventIDs(t *testing.T) {
+	// One invalid event, then failures until the consumer gives up
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "id: 7\nevent: score\ndata: {\"exam\":1,\"score\":0.5}\n\n")
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	policy := &ExponentialBackoff{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 2, MaxAttempts: 1}
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore(),
+		WithReconnectPolicy(policy),
+		WithLogger(logging.New(&buf, slog.LevelInfo)),
+	)
+	c.Start(context.Background())
+
+	var rejected []map[string]interface{}
+	connections := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected JSON log line, got %q", line)
+		}
+		id, _ := entry[logging.ConnectionIDKey].(string)
+		if id == "" {
+			t.Errorf("Expected connection ID on %q", line)
+		}
+		connections[id] = true
+		if entry["msg"] == "Rejected score event" {
+			rejected = append(rejected, entry)
+		}
+	}
+
+	// The first connection, and the failed reconnect after it closed
+	if len(connections) != 2 {
+		t.Errorf("Expected 2 connection IDs, got %v", connections)
+	}
+	if len(rejected) != 1 {
+		t.Fatalf("Expected 1 rejected event, got %d", len(rejected))
+	}
+	if rejected[0][logging.EventIDKey] != "7" || rejected[0]["reason"] != ReasonMissingStudentID {
+		t.Errorf("Expected event 7 rejected for missing student ID, got %v", rejected[0])
+	}
+}
+
+func TestSSEConsumer_DeadLettersRejectedEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+		fmt.Fprint(w, "id: 2\nevent: score\ndata: {\"exam\":1,\"score\":0.5}\n\n")
+		fmt.Fprint(w, "id: 3\nevent: score\ndata: {\"exam\":1,\"studentId\":\"bob\",\"score\":95}\n\n")
+		fmt.Fprint(w, "id: 4\nevent: score\ndata: {oops\n\n")
+	}))
+	defer server.Close()
+
+	queue := deadletter.NewQueue(10)
+	c := NewSSEConsumer(server.URL, store.NewMemoryStore(), WithDeadLetterQueue(queue))
+	c.connect(context.Background(), "conn-1", c.logger)
+
+	entries, _ := queue.List("", 0, 10)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 dead-lettered events, got %d", len(entries))
+	}
+
+	expected := []struct {
+		eventID string
+		reason  string
+		data    string
+	}{
+		{"4", ReasonInvalidJSON, "{oops"},
+		{"3", ReasonScoreOutOfRange, `{"exam":1,"studentId":"bob","score":95}`},
+		{"2", ReasonMissingStudentID, `{"exam":1,"score":0.5}`},
+	}
+	for i, e := range expected {
+		entry := entries[i]
+		if entry.EventID != e.eventID || entry.Reason != e.reason || entry.Data != e.data {
+			t.Errorf("Expected event %s rejected for %s with data %s, got %+v", e.eventID, e.reason, e.data, entry)
+		}
+		if entry.ConnectionID != "conn-1" || entry.Source != "sse" {
+			t.Errorf("Expected connection conn-1 and source sse, got %+v", entry)
+		}
+	}
+}
+
+func TestSSEConsumer_AppliesValidator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":7,\"studentId\":\"alice\",\"score\":87}\n\n")
+		fmt.Fprint(w, "id: 2\nevent: score\ndata: {\"exam\":3,\"studentId\":\"bob\",\"score\":87}\n\n")
+	}))
+	defer server.Close()
+
+	validator := validation.Chain{validation.ScoreScale{
+		Default: validation.UnitScale,
+		Exams:   map[int]validation.Scale{7: {Min: 0, Max: 100}},
+	}}
+	queue := deadletter.NewQueue(10)
+	s := store.NewMemoryStore()
+	c := NewSSEConsumer(server.URL, s, WithValidator(validator), WithDeadLetterQueue(queue))
+	c.connect(context.Background(), "conn-1", c.logger)
+
+	if history, err := s.GetScoreHistory("alice", 7); err != nil || len(history) != 1 || history[0].Score != 87 {
+		t.Errorf("Expected exam 7 score 87 to be stored, got %+v, %v", history, err)
+	}
+
+	entries, _ := queue.List("", 0, 10)
+	if len(entries) != 1 || entries[0].EventID != "2" || entries[0].Reason != ReasonScoreOutOfRange {
+		t.Errorf("Expected event 2 rejected for %s, got %+v", ReasonScoreOutOfRange, entries)
+	}
+}
+
+func TestSSEConsumer_IgnoresRedeliveredEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+		fmt.Fprint(w, "id: 2\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+	}))
+	defer server.Close()
+
+	s := store.NewMemoryStore(store.WithDedup(time.Minute, 100))
+	c := NewSSEConsumer(server.URL, s)
+	c.connect(context.Background(), "conn-1", c.logger)
+
+	if stats := s.Stats(); stats.Scores != 2 || stats.Duplicates != 1 {
+		t.Errorf("Expected 2 scores and 1 duplicate, got %+v", stats)
+	}
+
+	history, _ := s.GetScoreHistory("alice", 1)
+	if len(history) != 2 || history[0].EventID != "1" || history[1].EventID != "2" {
+		t.Errorf("Expected scores from events 1 and 2, got %+v", history)
+	}
+}
+
+// Events without an id field inherit the last ID, which must not make
+// them duplicates of the event that set it
+func TestSSEConsumer_DedupsOnlyOwnEventIDs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+		fmt.Fprint(w, "event: score\ndata: {\"exam\":1,\"studentId\":\"bob\",\"score\":0.6}\n\n")
+		fmt.Fprint(w, "event: score\ndata: {\"exam\":1,\"studentId\":\"carol\",\"score\":0.7}\n\n")
+	}))
+	defer server.Close()
+
+	s := store.NewMemoryStore(store.WithDedup(time.Minute, 100))
+	c := NewSSEConsumer(server.URL, s)
+	c.connect(context.Background(), "conn-1", c.logger)
+
+	if stats := s.Stats(); stats.Scores != 3 || stats.Duplicates != 0 {
+		t.Errorf("Expected 3 scores and no duplicates, got %+v", stats)
+	}
+	if history, _ := s.GetScoreHistory("bob", 1); len(history) != 1 || history[0].EventID != "" {
+		t.Errorf("Expected bob's score without an event ID, got %+v", history)
+	}
+
+	// Nor an ID resumed from a checkpoint
+	resumed := NewSSEConsumer(server.URL, store.NewMemoryStore(store.WithDedup(time.Minute, 100)))
+	resumed.status.LastEventID = "1"
+	resumed.connect(context.Background(), "conn-2", resumed.logger)
+	if stats := resumed.store.Stats(); stats.Scores != 3 || stats.Duplicates != 0 {
+		t.Errorf("Expected 3 scores and no duplicates after resuming, got %+v", stats)
+	}
+}
+
+// storeCheckpoint records each saved ID along with how many scores the
+// store held when it was saved
+type storeCheckpoint struct {
+	store store.Store
+	saves []string
+}
+
+func (c *storeCheckpoint) Load() (string, error) { return "", nil }
+
+func (c *storeCheckpoint) Save(id string) error {
+	c.saves = append(c.saves, fmt.Sprintf("%s@%d", id, c.store.Stats().Scores))
+	return nil
+}
+
+func TestSSEConsumer_CheckpointsAfterStoring(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "id: %d\nevent: score\ndata: {\"exam\":%d,\"studentId\":\"alice\",\"score\":0.5}\n\n", i, i)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		interval time.Duration
+		expected []string
+	}{
+		{0, []string{"1@1", "2@2", "3@3"}},
+
+		// Throttled saves still save the last ID when the connection ends
+		{time.Hour, []string{"1@1", "3@3"}},
+	}
+
+	for _, tt := range tests {
+		s := store.NewMemoryStore()
+		checkpoint := &storeCheckpoint{store: s}
+		c := NewSSEConsumer(server.URL, s, WithCheckpoint(checkpoint), WithCheckpointInterval(tt.interval))
+		c.connect(context.Background(), "conn-1", c.logger)
+
+		if !reflect.DeepEqual(checkpoint.saves, tt.expected) {
+			t.Errorf("%v: Expected saves %v, got %v", tt.interval, tt.expected, checkpoint.saves)
+		}
+	}
+}
+
+func TestSSEConsumer_SourceStatusAndHeaders(t *testing.T) {
+	var authorization atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+		fmt.Fprint(w, "id: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\n")
+		fmt.Fprint(w, "id: 2\nevent: score\ndata: {\"exam\":1,\"score\":0.5}\n\n")
+		fmt.Fprint(w, "id: 3\nevent: score\ndata: {\"exam\":2,\"studentId\":\"bob\",\"score\":0.7}\n\n")
+	}))
+	defer server.Close()
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer s3cret")
+	s := store.NewMemoryStore(store.WithDedup(time.Minute, 100))
+	c := NewSSEConsumer(server.URL, s, WithSource("partner"), WithHeaders(header))
+	header.Set("Authorization", "changed")
+	c.connect(context.Background(), "conn-1", c.logger)
+
+	if got := authorization.Load(); got != "Bearer s3cret" {
+		t.Errorf("Expected Authorization header Bearer s3cret, got %v", got)
+	}
+
+	status := c.Status()
+	if status.Source != "partner" {
+		t.Errorf("Expected source partner, got %q", status.Source)
+	}
+	if status.EventsRead != 4 || status.ScoresAccepted != 2 || status.ScoresRejected != 1 || status.Duplicates != 1 {
+		t.Errorf("Expected 4 events read, 2 accepted, 1 rejected and 1 duplicate, got %+v", status)
+	}
+
+	history, _ := s.GetScoreHistory("bob", 2)
+	if len(history) != 1 || history[0].Source != "partner" {
+		t.Errorf("Expected bob's score tagged with source partner, got %+v", history)
+	}
+}
@@ -0,0 +1,149 @@
+package consumer
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Status describes the current state of an SSE consumer
+type Status struct {
+	Source         string     `json:"source"`
+	URL            string     `json:"url"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	Connected      bool       `json:"connected"`
+	ConnectionID   string     `json:"connectionId,omitempty"` // ID of the current connection in logs
+	ConnectedAt    *time.Time `json:"connectedAt,omitempty"`
+	DisconnectedAt *time.Time `json:"disconnectedAt,omitempty"` // start of the current outage
+	LastEventID    string     `json:"lastEventId"`
+	LastEventAt    *time.Time `json:"lastEventAt,omitempty"`
+	LastAcceptedAt *time.Time `json:"lastAcceptedAt,omitempty"` // last score stored
+	EventsRead     int64      `json:"eventsRead"`
+	ScoresAccepted int64      `json:"scoresAccepted"`
+	ScoresRejected int64      `json:"scoresRejected"`
+	Duplicates     int64      `json:"duplicates"`
+	Reconnects     int64      `json:"reconnects"`
+	LastError      string     `json:"lastError,omitempty"`
+	LastErrorAt    *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// Status returns a snapshot of the consumer's connection state
+func (c *SSEConsumer) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.status
+}
+
+// LastEventID returns the ID of the last event received from the stream
+func (c *SSEConsumer) LastEventID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.status.LastEventID
+}
+
+func (c *SSEConsumer) setConnected(connectionID string) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.Connected = true
+	c.status.ConnectionID = connectionID
+	c.status.ConnectedAt = &now
+	c.status.DisconnectedAt = nil
+	connectedGauge.With(c.source).Set(1)
+}
+
+// setDisconnected marks the consumer as disconnected. A non-nil err
+// is recorded as the cause and counts as a reconnect.
+func (c *SSEConsumer) setDisconnected(err error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Failed reconnects extend the outage that began when the
+	// connection was lost
+	if c.status.Connected || c.status.DisconnectedAt == nil {
+		c.status.DisconnectedAt = &now
+	}
+	c.status.Connected = false
+	c.status.ConnectionID = ""
+	c.status.ConnectedAt = nil
+	connectedGauge.With(c.source).Set(0)
+	if err != nil {
+		reconnectsTotal.With(c.source).Inc()
+		c.status.Reconnects++
+		c.status.LastError = err.Error()
+		c.status.LastErrorAt = &now
+	}
+}
+
+func (c *SSEConsumer) recordEvent(eventType string) {
+	now := time.Now()
+	eventsReceived.With(c.source, eventTypeLabel(eventType)).Inc()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.EventsRead++
+	c.status.LastEventAt = &now
+}
+
+// recordAccepted notes that a score from the stream was stored
+func (c *SSEConsumer) recordAccepted() {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.ScoresAccepted++
+	c.status.LastAcceptedAt = &now
+}
+
+// recordRejected notes that a score event from the stream was dropped
+func (c *SSEConsumer) recordRejected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.ScoresRejected++
+}
+
+// recordDuplicate notes that a score from the stream was already stored
+func (c *SSEConsumer) recordDuplicate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.Duplicates++
+}
+
+// recordEventID stores id as the last handled event ID, persisting it
+// through the checkpoint at most once per checkpoint interval
+func (c *SSEConsumer) recordEventID(id string, logger *slog.Logger) {
+	c.mu.Lock()
+	c.status.LastEventID = id
+	c.mu.Unlock()
+
+	c.saveCheckpoint(false, logger)
+}
+
+// saveCheckpoint saves the last handled event ID if it changed since the
+// last save and, unless force is set, the checkpoint interval has passed.
+// It is only called from the goroutine reading events.
+func (c *SSEConsumer) saveCheckpoint(force bool, logger *slog.Logger) {
+	if c.checkpoint == nil {
+		return
+	}
+	id := c.LastEventID()
+	if id == c.savedID || (!force && time.Since(c.savedAt) < c.saveEvery) {
+		return
+	}
+
+	if err := c.checkpoint.Save(id); err != nil {
+		logger.Error("Failed to save last event ID", "error", err)
+		return
+	}
+	c.savedID = id
+	c.savedAt = time.Now()
+}
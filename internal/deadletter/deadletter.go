@@ -0,0 +1,151 @@
+// Package deadletter keeps score events that were rejected during ingest,
+// with the reason and raw payload, so data-quality problems can be
+// investigated and corrected events resubmitted.
+package deadletter
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is how many rejected events a Queue keeps by default
+const DefaultCapacity = 1000
+
+// Entry is one rejected event
+type Entry struct {
+	ID           uint64    `json:"id"`
+	Reason       string    `json:"reason"`
+	Message      string    `json:"message"`
+	Data         string    `json:"data"` // raw payload as received
+	EventID      string    `json:"eventId,omitempty"`
+	ConnectionID string    `json:"connectionId,omitempty"`
+	Source       string    `json:"source"`
+	RejectedAt   time.Time `json:"rejectedAt"`
+}
+
+// Queue is a bounded store of rejected events. Once full, adding an event
+// drops the oldest one.
+type Queue struct {
+	mu       sync.RWMutex
+	capacity int
+	nextID   uint64
+	entries  []Entry // ordered by ID
+	dropped  uint64
+}
+
+// NewQueue creates a queue holding up to capacity entries
+func NewQueue(capacity int) *Queue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Queue{
+		capacity: capacity,
+		nextID:   1,
+	}
+}
+
+// Add stores entry, assigning its ID and, if unset, its rejection time
+func (q *Queue) Add(entry Entry) Entry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry.ID = q.nextID
+	q.nextID++
+	if entry.RejectedAt.IsZero() {
+		entry.RejectedAt = time.Now()
+	}
+
+	if len(q.entries) == q.capacity {
+		copy(q.entries, q.entries[1:])
+		q.entries = q.entries[:len(q.entries)-1]
+		q.dropped++
+	}
+	q.entries = append(q.entries, entry)
+	return entry
+}
+
+// List returns up to limit entries with an ID below before (0 for no
+// bound), newest first. A non-empty reason keeps only entries rejected for
+// that reason. more reports whether older matching entries remain.
+func (q *Queue) List(reason string, before uint64, limit int) (entries []Entry, more bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	end := len(q.entries)
+	if before > 0 {
+		end = q.search(before)
+	}
+
+	entries = make([]Entry, 0)
+	for i := end - 1; i >= 0; i-- {
+		if reason != "" && q.entries[i].Reason != reason {
+			continue
+		}
+		if len(entries) == limit {
+			return entries, true
+		}
+		entries = append(entries, q.entries[i])
+	}
+	return entries, false
+}
+
+// Get returns the entry with id
+func (q *Queue) Get(id uint64) (Entry, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.search(id)
+	if i == len(q.entries) || q.entries[i].ID != id {
+		return Entry{}, false
+	}
+	return q.entries[i], true
+}
+
+// Remove deletes the entry with id, reporting whether it was present
+func (q *Queue) Remove(id uint64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.search(id)
+	if i == len(q.entries) || q.entries[i].ID != id {
+		return false
+	}
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	return true
+}
+
+// Counts returns how many held entries were rejected for each reason
+func (q *Queue) Counts() map[string]int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, entry := range q.entries {
+		counts[entry.Reason]++
+	}
+	return counts
+}
+
+// Len returns the number of entries held
+func (q *Queue) Len() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return len(q.entries)
+}
+
+// Dropped returns how many entries were dropped to make room for newer ones
+func (q *Queue) Dropped() uint64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.dropped
+}
+
+// search returns the index of the first entry with an ID of at least id
+func (q *Queue) search(id uint64) int {
+	return sort.Search(len(q.entries), func(i int) bool {
+		return q.entries[i].ID >= id
+	})
+}
@@ -0,0 +1,107 @@
+package deadletter
+
+import (
+	"testing"
+)
+
+func ids(entries []Entry) []uint64 {
+	var out []uint64
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func equalIDs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueue_DropsOldest(t *testing.T) {
+	q := NewQueue(3)
+	for i := 0; i < 5; i++ {
+		q.Add(Entry{Reason: "invalid_json"})
+	}
+
+	if q.Len() != 3 {
+		t.Errorf("Expected 3 entries, got %d", q.Len())
+	}
+	if q.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped, got %d", q.Dropped())
+	}
+
+	entries, more := q.List("", 0, 10)
+	if !equalIDs(ids(entries), []uint64{5, 4, 3}) || more {
+		t.Errorf("Expected entries [5 4 3], got %v (more %v)", ids(entries), more)
+	}
+	if entries[0].RejectedAt.IsZero() {
+		t.Error("Expected rejection time to be set")
+	}
+}
+
+func TestQueue_List(t *testing.T) {
+	q := NewQueue(10)
+	reasons := []string{"invalid_json", "score_out_of_range", "invalid_json", "missing_student_id", "invalid_json"}
+	for _, reason := range reasons {
+		q.Add(Entry{Reason: reason})
+	}
+
+	tests := []struct {
+		name       string
+		reason     string
+		before     uint64
+		limit      int
+		expected   []uint64
+		expectMore bool
+	}{
+		{"all", "", 0, 10, []uint64{5, 4, 3, 2, 1}, false},
+		{"limited", "", 0, 2, []uint64{5, 4}, true},
+		{"next page", "", 4, 2, []uint64{3, 2}, true},
+		{"by reason", "invalid_json", 0, 10, []uint64{5, 3, 1}, false},
+		{"by reason paged", "invalid_json", 5, 1, []uint64{3}, true},
+		{"unknown reason", "other", 0, 10, nil, false},
+	}
+
+	for _, tt := range tests {
+		entries, more := q.List(tt.reason, tt.before, tt.limit)
+		if !equalIDs(ids(entries), tt.expected) || more != tt.expectMore {
+			t.Errorf("%s: Expected %v (more %v), got %v (more %v)", tt.name, tt.expected, tt.expectMore, ids(entries), more)
+		}
+	}
+
+	counts := q.Counts()
+	if counts["invalid_json"] != 3 || counts["score_out_of_range"] != 1 || counts["missing_student_id"] != 1 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+func TestQueue_GetRemove(t *testing.T) {
+	q := NewQueue(10)
+	q.Add(Entry{Reason: "invalid_json", Data: "a"})
+	q.Add(Entry{Reason: "invalid_json", Data: "b"})
+
+	entry, ok := q.Get(2)
+	if !ok || entry.Data != "b" {
+		t.Errorf("Expected entry 2 with data b, got %+v", entry)
+	}
+
+	if !q.Remove(2) {
+		t.Error("Expected entry 2 to be removed")
+	}
+	if q.Remove(2) {
+		t.Error("Expected second removal to fail")
+	}
+	if _, ok := q.Get(2); ok {
+		t.Error("Expected entry 2 to be gone")
+	}
+	if _, ok := q.Get(1); !ok {
+		t.Error("Expected entry 1 to remain")
+	}
+}
@@ -0,0 +1,418 @@
+// Package loadgen drives concurrent ingest and query traffic against a
+// running instance and reports throughput and latency percentiles for each
+// kind of request.
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations a Mix can weight
+const (
+	OpIngest          = "ingest"          // POST /scores
+	OpBatch           = "batch"           // POST /scores/batch
+	OpStudents        = "students"        // GET /students?limit=50
+	OpStudent         = "student"         // GET /students/{id}
+	OpHistory         = "history"         // GET /students/{id}/exams/{number}/history
+	OpExams           = "exams"           // GET /exams
+	OpExam            = "exam"            // GET /exams/{number}
+	OpExamStats       = "examStats"       // GET /exams/{number}/stats
+	OpExamLeaderboard = "examLeaderboard" // GET /exams/{number}/leaderboard?top=10
+	OpLeaderboard     = "leaderboard"     // GET /leaderboard?top=10
+)
+
+// Operations lists every operation, in the order reports show them
+var Operations = []string{
+	OpIngest, OpBatch, OpStudents, OpStudent, OpHistory,
+	OpExams, OpExam, OpExamStats, OpExamLeaderboard, OpLeaderboard,
+}
+
+// DefaultMix is a read-heavy mix with a steady trickle of writes
+const DefaultMix = "ingest=2,batch=1,students=1,student=4,history=1,exams=1,exam=2,examStats=1,examLeaderboard=1,leaderboard=1"
+
+// Mix weights how often each operation is picked
+type Mix map[string]int
+
+// ParseMix reads a mix written as comma-separated op=weight pairs, such as
+// "ingest=1,student=4"
+func ParseMix(s string) (Mix, error) {
+	mix := make(Mix)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		op, weight, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected op=weight, got %q", pair)
+		}
+		if !isOperation(op) {
+			return nil, fmt.Errorf("unknown operation %q, expected one of %s", op, strings.Join(Operations, ", "))
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("weight of %s must be a non-negative integer, got %q", op, weight)
+		}
+		if _, ok := mix[op]; ok {
+			return nil, fmt.Errorf("duplicate operation %q", op)
+		}
+		mix[op] = n
+	}
+
+	if mix.total() == 0 {
+		return nil, fmt.Errorf("mix has no operations with a positive weight")
+	}
+	return mix, nil
+}
+
+// total returns the sum of the weights
+func (m Mix) total() int {
+	total := 0
+	for _, weight := range m {
+		total += weight
+	}
+	return total
+}
+
+// pick returns an operation at random in proportion to its weight
+func (m Mix) pick(r *rand.Rand) string {
+	n := r.IntN(m.total())
+	for _, op := range Operations {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return ""
+}
+
+func isOperation(op string) bool {
+	for _, known := range Operations {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Generator sends a mix of requests to one instance from several workers
+type Generator struct {
+	baseURL     string
+	apiKey      string
+	client      *http.Client
+	concurrency int
+	rate        float64
+	mix         Mix
+	batchSize   int
+	students    int
+	exams       int
+	seed        uint64
+}
+
+// Option configures a Generator
+type Option func(*Generator)
+
+// WithAPIKey authenticates ingest requests with key
+func WithAPIKey(key string) Option {
+	return func(g *Generator) {
+		g.apiKey = key
+	}
+}
+
+// WithClient sets the HTTP client requests are sent with. The default
+// keeps enough idle connections open for every worker.
+func WithClient(client *http.Client) Option {
+	return func(g *Generator) {
+		g.client = client
+	}
+}
+
+// WithConcurrency sets the number of workers sending requests. The
+// default is 16.
+func WithConcurrency(n int) Option {
+	return func(g *Generator) {
+		g.concurrency = n
+	}
+}
+
+// WithRate caps the requests sent per second across all workers. The
+// default, 0, sends them as fast as the instance answers.
+func WithRate(perSecond float64) Option {
+	return func(g *Generator) {
+		g.rate = perSecond
+	}
+}
+
+// WithMix sets how often each operation is sent. The default is
+// DefaultMix.
+func WithMix(mix Mix) Option {
+	return func(g *Generator) {
+		g.mix = mix
+	}
+}
+
+// WithBatchSize sets the scores in each batch request. The default is 100.
+func WithBatchSize(n int) Option {
+	return func(g *Generator) {
+		g.batchSize = n
+	}
+}
+
+// WithCardinality sets how many distinct students and exams scores are
+// written and queried for. The defaults are 1000 students and 20 exams.
+func WithCardinality(students, exams int) Option {
+	return func(g *Generator) {
+		g.students = students
+		g.exams = exams
+	}
+}
+
+// WithSeed sets the seed requests are generated from
+func WithSeed(seed uint64) Option {
+	return func(g *Generator) {
+		g.seed = seed
+	}
+}
+
+// NewGenerator creates a generator for the instance at baseURL, such as
+// http://localhost:8080
+func NewGenerator(baseURL string, opts ...Option) *Generator {
+	mix, _ := ParseMix(DefaultMix)
+	g := &Generator{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		concurrency: 16,
+		mix:         mix,
+		batchSize:   100,
+		students:    1000,
+		exams:       20,
+		seed:        1,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if g.client == nil {
+		g.client = &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{MaxIdleConnsPerHost: g.concurrency},
+		}
+	}
+	return g
+}
+
+// Prefill posts n scores in batches, covering every student and exam in
+// turn, so that queries find data from the first request
+func (g *Generator) Prefill(ctx context.Context, n int) error {
+	for sent := 0; sent < n; sent += g.batchSize {
+		events := make([]scoreEvent, 0, min(g.batchSize, n-sent))
+		for i := sent; i < n && len(events) < g.batchSize; i++ {
+			events = append(events, scoreEvent{
+				Exam:      1 + (i/g.students)%g.exams,
+				StudentID: studentID(i % g.students),
+				Score:     float64(i%101) / 100,
+			})
+		}
+
+		req, err := g.ingestRequest(ctx, "/scores/batch", events)
+		if err != nil {
+			return err
+		}
+		resp, err := g.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to prefill scores: %w", err)
+		}
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code %d prefilling scores: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+		}
+	}
+	return nil
+}
+
+// sample is the outcome of one request
+type sample struct {
+	latency time.Duration
+	status  int
+	err     error
+}
+
+// Run sends requests until duration has passed or ctx is done and reports
+// how they went. Failed requests are counted in the report rather than
+// stopping the run.
+func (g *Generator) Run(ctx context.Context, duration time.Duration) Report {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var tokens <-chan time.Time
+	if g.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / g.rate))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	start := time.Now()
+	results := make([]map[string][]sample, g.concurrency)
+	var wg sync.WaitGroup
+	for worker := range g.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[worker] = g.work(ctx, uint64(worker), tokens)
+		}()
+	}
+	wg.Wait()
+
+	merged := make(map[string][]sample)
+	for _, samples := range results {
+		for op, s := range samples {
+			merged[op] = append(merged[op], s...)
+		}
+	}
+	return newReport(merged, time.Since(start))
+}
+
+// work sends requests from one worker until ctx is done. Each worker keeps
+// its own samples so recording them needs no locking.
+func (g *Generator) work(ctx context.Context, worker uint64, tokens <-chan time.Time) map[string][]sample {
+	r := rand.New(rand.NewPCG(g.seed, worker))
+	samples := make(map[string][]sample)
+
+	for {
+		if tokens != nil {
+			select {
+			case <-ctx.Done():
+				return samples
+			case <-tokens:
+			}
+		}
+		if ctx.Err() != nil {
+			return samples
+		}
+
+		op := g.mix.pick(r)
+		s := g.send(ctx, r, op)
+
+		// Requests cut short by the end of the run say nothing about the
+		// instance, so leave them out
+		if s.err != nil && ctx.Err() != nil {
+			return samples
+		}
+		samples[op] = append(samples[op], s)
+	}
+}
+
+// send makes one request for op and times it, including reading the body
+func (g *Generator) send(ctx context.Context, r *rand.Rand, op string) sample {
+	req, err := g.request(ctx, r, op)
+	if err != nil {
+		return sample{err: err}
+	}
+
+	start := time.Now()
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return sample{latency: time.Since(start), err: err}
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return sample{latency: time.Since(start), status: resp.StatusCode, err: err}
+}
+
+// request builds a request for op against a random student and exam
+func (g *Generator) request(ctx context.Context, r *rand.Rand, op string) (*http.Request, error) {
+	student := studentID(r.IntN(g.students))
+	exam := 1 + r.IntN(g.exams)
+
+	var path string
+	switch op {
+	case OpIngest:
+		return g.ingestRequest(ctx, "/scores", g.event(r))
+	case OpBatch:
+		events := make([]scoreEvent, g.batchSize)
+		for i := range events {
+			events[i] = g.event(r)
+		}
+		return g.ingestRequest(ctx, "/scores/batch", events)
+	case OpStudents:
+		path = "/students?limit=50"
+	case OpStudent:
+		path = "/students/" + student
+	case OpHistory:
+		path = fmt.Sprintf("/students/%s/exams/%d/history", student, exam)
+	case OpExams:
+		path = "/exams"
+	case OpExam:
+		path = fmt.Sprintf("/exams/%d", exam)
+	case OpExamStats:
+		path = fmt.Sprintf("/exams/%d/stats", exam)
+	case OpExamLeaderboard:
+		path = fmt.Sprintf("/exams/%d/leaderboard?top=10", exam)
+	case OpLeaderboard:
+		path = "/leaderboard?top=10"
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op)
+	}
+
+	return http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+path, nil)
+}
+
+// scoreEvent is the body of a posted score
+type scoreEvent struct {
+	Exam      int     `json:"exam"`
+	StudentID string  `json:"studentId"`
+	Score     float64 `json:"score"`
+}
+
+// event returns a score for a random student and exam
+func (g *Generator) event(r *rand.Rand) scoreEvent {
+	return scoreEvent{
+		Exam:      1 + r.IntN(g.exams),
+		StudentID: studentID(r.IntN(g.students)),
+		Score:     float64(r.IntN(101)) / 100,
+	}
+}
+
+// ingestRequest builds an authenticated POST of one score, or of several
+// as NDJSON
+func (g *Generator) ingestRequest(ctx context.Context, path string, body any) (*http.Request, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	contentType := "application/json"
+	if events, ok := body.([]scoreEvent); ok {
+		contentType = "application/x-ndjson"
+		for _, event := range events {
+			encoder.Encode(event)
+		}
+	} else {
+		encoder.Encode(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+path, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if g.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	}
+	return req, nil
+}
+
+// studentID names generated student i so load test data is easy to tell
+// apart from real students
+func studentID(i int) string {
+	return fmt.Sprintf("Load.Student%05d", i)
+}
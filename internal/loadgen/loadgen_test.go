@@ -0,0 +1,192 @@
+package loadgen
+
+import (
+	"bytes"
+	"channel-test/internal/api"
+	"channel-test/internal/auth"
+	"channel-test/internal/store"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "0123456789abcdef"
+
+// newInstance serves the real API over a memory store, accepting testKey
+func newInstance(t *testing.T) (*httptest.Server, *store.MemoryStore) {
+	t.Helper()
+
+	keys, err := auth.ParseKeys([]byte("loadtest:" + testKey))
+	if err != nil {
+		t.Fatalf("Failed to parse keys: %v", err)
+	}
+	s := store.NewMemoryStore()
+	server := httptest.NewServer(api.NewRouter(api.NewHandler(s, api.WithAPIKeys(keys))))
+	t.Cleanup(server.Close)
+	return server, s
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix(" ingest=1, student=4 ,exam=0")
+	if err != nil {
+		t.Fatalf("Failed to parse mix: %v", err)
+	}
+	if mix[OpIngest] != 1 || mix[OpStudent] != 4 || mix.total() != 5 {
+		t.Errorf("Expected ingest=1 and student=4, got %v", mix)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"missing weight", "ingest", "expected op=weight"},
+		{"unknown operation", "delete=1", "unknown operation"},
+		{"negative weight", "ingest=-1", "non-negative integer"},
+		{"duplicate", "exam=1,exam=2", "duplicate operation"},
+		{"all zero", "exam=0", "no operations"},
+		{"empty", "", "no operations"},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseMix(tt.input); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	if _, err := ParseMix(DefaultMix); err != nil {
+		t.Errorf("Expected DefaultMix to parse, got %v", err)
+	}
+}
+
+func TestGenerator_Run(t *testing.T) {
+	server, s := newInstance(t)
+
+	weights := make([]string, len(Operations))
+	for i, op := range Operations {
+		weights[i] = op + "=1"
+	}
+	mix, _ := ParseMix(strings.Join(weights, ","))
+
+	g := NewGenerator(server.URL,
+		WithAPIKey(testKey),
+		WithMix(mix),
+		WithConcurrency(4),
+		WithBatchSize(10),
+		WithCardinality(20, 3),
+	)
+	if err := g.Prefill(context.Background(), 60); err != nil {
+		t.Fatalf("Prefill failed: %v", err)
+	}
+	if stats := s.Stats(); stats.Scores != 60 {
+		t.Errorf("Expected 60 prefilled scores, got %d", stats.Scores)
+	}
+
+	report := g.Run(context.Background(), 300*time.Millisecond)
+
+	if report.Requests == 0 || report.Errors != 0 {
+		t.Fatalf("Expected requests without errors, got %+v", report)
+	}
+	for _, op := range Operations {
+		opReport, ok := report.Operations[op]
+		if !ok || opReport.Requests == 0 {
+			t.Errorf("%s: Expected requests, got %+v", op, opReport)
+			continue
+		}
+		// Every student and exam was prefilled, so every request succeeds
+		for status := range opReport.Statuses {
+			if status != http.StatusOK && status != http.StatusCreated {
+				t.Errorf("%s: Expected 200 or 201, got %v", op, opReport.Statuses)
+			}
+		}
+		if l := opReport.Latency; l.P50 > l.P90 || l.P90 > l.P99 || l.P99 > l.Max {
+			t.Errorf("%s: Expected ordered percentiles, got %+v", op, l)
+		}
+	}
+	if report.Throughput <= 0 || report.Seconds < 0.3 {
+		t.Errorf("Expected positive throughput over at least 300ms, got %+v", report)
+	}
+}
+
+func TestGenerator_Rate(t *testing.T) {
+	server, _ := newInstance(t)
+
+	mix, _ := ParseMix("exams=1")
+	g := NewGenerator(server.URL, WithMix(mix), WithConcurrency(8), WithRate(50))
+	report := g.Run(context.Background(), 300*time.Millisecond)
+
+	// 50 per second for 300ms is about 15 requests, however many workers
+	if report.Requests < 5 || report.Requests > 20 {
+		t.Errorf("Expected about 15 requests, got %d", report.Requests)
+	}
+}
+
+func TestGenerator_CountsErrors(t *testing.T) {
+	// Without an API key every write is refused
+	server, _ := newInstance(t)
+
+	mix, _ := ParseMix("ingest=1,student=1")
+	g := NewGenerator(server.URL, WithMix(mix), WithConcurrency(2))
+	report := g.Run(context.Background(), 100*time.Millisecond)
+
+	ingest := report.Operations[OpIngest]
+	if ingest.Requests == 0 || ingest.Errors != ingest.Requests {
+		t.Errorf("Expected every ingest to fail, got %+v", ingest)
+	}
+	if ingest.Statuses[http.StatusUnauthorized] != ingest.Requests || ingest.LastError != "status 401" {
+		t.Errorf("Expected 401 for every ingest, got %+v", ingest)
+	}
+
+	// Students that don't exist yet are not errors
+	student := report.Operations[OpStudent]
+	if student.Requests == 0 || student.Errors != 0 || student.Statuses[http.StatusNotFound] != student.Requests {
+		t.Errorf("Expected 404 without errors for every student, got %+v", student)
+	}
+	if report.Errors != ingest.Errors {
+		t.Errorf("Expected %d errors in total, got %d", ingest.Errors, report.Errors)
+	}
+
+	if err := g.Prefill(context.Background(), 10); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected prefill to fail with 401, got %v", err)
+	}
+}
+
+func TestSummarise(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	l := summarise(latencies)
+	if l.P50 != 50 || l.P90 != 90 || l.P99 != 99 || l.Max != 100 || l.Mean != 50.5 {
+		t.Errorf("Expected p50 50, p90 90, p99 99, max 100 and mean 50.5, got %+v", l)
+	}
+	if l := summarise(nil); l != (Latency{}) {
+		t.Errorf("Expected zero latency for no requests, got %+v", l)
+	}
+}
+
+func TestReport_WriteText(t *testing.T) {
+	report := newReport(map[string][]sample{
+		OpExam:   {{latency: time.Millisecond, status: 200}, {latency: 3 * time.Millisecond, status: 500}},
+		OpIngest: {{latency: 2 * time.Millisecond, status: 201}},
+	}, time.Second)
+
+	var buf bytes.Buffer
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, expected := range []string{"3 requests in 1s: 3.0 req/s, 1 errors", "ingest", "exam", "total", "exam: last error: status 500"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+	if strings.Index(out, "ingest") > strings.Index(out, "exam ") {
+		t.Errorf("Expected operations in the order of Operations, got:\n%s", out)
+	}
+}
@@ -0,0 +1,148 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Latency summarises request latencies in milliseconds
+type Latency struct {
+	P50  float64 `json:"p50Ms"`
+	P90  float64 `json:"p90Ms"`
+	P99  float64 `json:"p99Ms"`
+	Max  float64 `json:"maxMs"`
+	Mean float64 `json:"meanMs"`
+}
+
+// OperationReport is how the requests for one operation went
+type OperationReport struct {
+	Requests   int64         `json:"requests"`
+	Errors     int64         `json:"errors"`
+	Throughput float64       `json:"throughput"` // requests per second
+	Statuses   map[int]int64 `json:"statuses"`
+	Latency    Latency       `json:"latency"`
+	LastError  string        `json:"lastError,omitempty"`
+}
+
+// Report is how a run went. An error is a request that failed to get a
+// response or got a 4xx or 5xx status other than 404, which queries for
+// students not written yet can expect.
+type Report struct {
+	Duration   time.Duration              `json:"-"`
+	Seconds    float64                    `json:"durationSeconds"`
+	Requests   int64                      `json:"requests"`
+	Errors     int64                      `json:"errors"`
+	Throughput float64                    `json:"throughput"` // requests per second
+	Latency    Latency                    `json:"latency"`
+	Operations map[string]OperationReport `json:"operations"`
+}
+
+// newReport summarises the samples of each operation over elapsed
+func newReport(samples map[string][]sample, elapsed time.Duration) Report {
+	report := Report{
+		Duration:   elapsed,
+		Seconds:    elapsed.Seconds(),
+		Operations: make(map[string]OperationReport),
+	}
+
+	var all []time.Duration
+	for op, opSamples := range samples {
+		opReport := OperationReport{Statuses: make(map[int]int64)}
+		latencies := make([]time.Duration, 0, len(opSamples))
+		for _, s := range opSamples {
+			opReport.Requests++
+			if s.status != 0 {
+				opReport.Statuses[s.status]++
+			}
+			switch {
+			case s.err != nil:
+				opReport.Errors++
+				opReport.LastError = s.err.Error()
+			case s.status >= 400 && s.status != http.StatusNotFound:
+				opReport.Errors++
+				opReport.LastError = fmt.Sprintf("status %d", s.status)
+			}
+			if s.status != 0 {
+				latencies = append(latencies, s.latency)
+			}
+		}
+
+		opReport.Throughput = float64(opReport.Requests) / elapsed.Seconds()
+		opReport.Latency = summarise(latencies)
+		report.Operations[op] = opReport
+		report.Requests += opReport.Requests
+		report.Errors += opReport.Errors
+		all = append(all, latencies...)
+	}
+
+	report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	report.Latency = summarise(all)
+	return report
+}
+
+// summarise sorts latencies and takes their percentiles
+func summarise(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	return Latency{
+		P50:  milliseconds(percentile(latencies, 0.50)),
+		P90:  milliseconds(percentile(latencies, 0.90)),
+		P99:  milliseconds(percentile(latencies, 0.99)),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+		Mean: milliseconds(total / time.Duration(len(latencies))),
+	}
+}
+
+// percentile returns the nearest-rank p percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(float64(len(sorted))*p)) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// WriteText writes the report as a table with a row per operation
+func (r Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%d requests in %s: %.1f req/s, %d errors\n\n",
+		r.Requests, r.Duration.Round(time.Millisecond), r.Throughput, r.Errors)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\terrors\treq/s\tp50 ms\tp90 ms\tp99 ms\tmax ms\tmean ms\t")
+	row := func(name string, requests, errors int64, throughput float64, l Latency) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			name, requests, errors, throughput, l.P50, l.P90, l.P99, l.Max, l.Mean)
+	}
+	for _, op := range Operations {
+		if opReport, ok := r.Operations[op]; ok {
+			row(op, opReport.Requests, opReport.Errors, opReport.Throughput, opReport.Latency)
+		}
+	}
+	row("total", r.Requests, r.Errors, r.Throughput, r.Latency)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, op := range Operations {
+		if opReport, ok := r.Operations[op]; ok && opReport.LastError != "" {
+			fmt.Fprintf(w, "\n%s: last error: %s", op, opReport.LastError)
+		}
+	}
+	if r.Errors > 0 {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
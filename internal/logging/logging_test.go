@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+
+	for _, tt := range tests {
+		level, err := ParseLevel(tt.name)
+		if err != nil || level != tt.expected {
+			t.Errorf("%s: Expected %v, got %v (%v)", tt.name, tt.expected, level, err)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{"abc-123", true},
+		{NewID(), true},
+		{"", false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"naïve", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidID(tt.id); got != tt.expected {
+			t.Errorf("%q: Expected %v, got %v", tt.id, tt.expected, got)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("Expected default logger without one in context")
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	if FromContext(WithContext(context.Background(), logger)) != logger {
+		t.Error("Expected logger from context")
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("http_requests_total", "Total HTTP requests.", "route", "status")
+	requests.With("/students", "200").Inc()
+	requests.With("/students", "200").Add(2)
+	requests.With("/exams/{number}", "404").Inc()
+
+	connected := r.NewGauge("sse_connected", "Whether the consumer is connected.")
+	connected.Set(1)
+
+	r.NewGaugeFunc("last_event_age_seconds", "Seconds since the last event.", func() float64 { return math.NaN() })
+
+	latency := r.NewHistogramVec("request_duration_seconds", "Request latency.", []float64{0.5, 0.1, 1}, "route")
+	latency.With("/students").Observe(0.05)
+	latency.With("/students").Observe(0.1)
+	latency.With("/students").Observe(0.7)
+	latency.With("/students").Observe(3)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+
+	expected := `# HELP http_requests_total Total HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{route="/exams/{number}",status="404"} 1
+http_requests_total{route="/students",status="200"} 3
+# HELP last_event_age_seconds Seconds since the last event.
+# TYPE last_event_age_seconds gauge
+last_event_age_seconds NaN
+# HELP request_duration_seconds Request latency.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{route="/students",le="0.1"} 2
+request_duration_seconds_bucket{route="/students",le="0.5"} 2
+request_duration_seconds_bucket{route="/students",le="1"} 3
+request_duration_seconds_bucket{route="/students",le="+Inf"} 4
+request_duration_seconds_sum{route="/students"} 3.85
+request_duration_seconds_count{route="/students"} 4
+# HELP sse_connected Whether the consumer is connected.
+# TYPE sse_connected gauge
+sse_connected 1
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", b.String(), expected)
+	}
+}
+
+func TestRegistry_Escaping(t *testing.T) {
+	r := NewRegistry()
+
+	r.NewCounterVec("events_total", "Events with \\ and\nnewlines.", "reason").With("bad \"quote\"\n").Inc()
+
+	var b strings.Builder
+	r.WriteTo(&b)
+
+	if !strings.Contains(b.String(), `# HELP events_total Events with \\ and\nnewlines.`) {
+		t.Errorf("Expected escaped help text, got:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), `events_total{reason="bad \"quote\"\n"} 1`) {
+		t.Errorf("Expected escaped label value, got:\n%s", b.String())
+	}
+}
+
+func TestRegistry_DuplicateRegistration(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("dup_total", "First.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on duplicate registration")
+		}
+	}()
+	r.NewGauge("dup_total", "Second.")
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("up_total", "Up.").Inc()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected exposition content type, got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), "up_total 1\n") {
+		t.Errorf("Expected up_total sample, got:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,149 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"channel-test/internal/consumer"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// scoreEventType is the SSE event type carrying scores
+const scoreEventType = "score"
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Event is one recorded event read from a capture
+type Event struct {
+	ID   string
+	Type string
+	Data string
+
+	// ReceivedAt is when the event was recorded, or zero when the
+	// capture has no timestamps
+	ReceivedAt time.Time
+}
+
+// Reader reads recorded events from a raw text/event-stream capture or
+// from newline-delimited JSON.
+//
+// Each NDJSON line is either a recorded envelope such as
+//
+//	{"id":"42","event":"score","data":"{...}","receivedAt":"2024-01-02T15:04:05Z"}
+//
+// whose data may be a JSON string or object, or a bare score event such as
+// {"exam":1,"studentId":"alice","score":0.9}. Lines that are not JSON are
+// returned as score data so validation can reject them.
+type Reader struct {
+	read func() (Event, error)
+}
+
+// NewReader detects the format of r from its first non-blank byte: NDJSON
+// starts with '{', anything else is read as an event stream. Gzipped input,
+// such as segments written by consumer.FileRecorder, is decompressed first.
+func NewReader(r io.Reader) *Reader {
+	buffered := bufio.NewReader(r)
+
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(buffered)
+		if err != nil {
+			return &Reader{read: func() (Event, error) {
+				return Event{}, err
+			}}
+		}
+		buffered = bufio.NewReader(zr)
+	}
+
+	if isNDJSON(buffered) {
+		return &Reader{read: ndjsonReader(buffered)}
+	}
+
+	decoder := consumer.NewDecoder(buffered)
+	return &Reader{read: func() (Event, error) {
+		event, err := decoder.Decode()
+		if err != nil {
+			return Event{}, err
+		}
+		// Only an event's own id identifies it; one carried over from an
+		// earlier event would make them duplicates
+		id := ""
+		if event.HasID {
+			id = event.ID
+		}
+		return Event{ID: id, Type: event.Type, Data: event.Data}, nil
+	}}
+}
+
+// Read returns the next event, or io.EOF when the capture ends
+func (r *Reader) Read() (Event, error) {
+	return r.read()
+}
+
+// isNDJSON peeks past leading whitespace for the start of a JSON object
+func isNDJSON(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		peeked, err := r.Peek(n)
+		if len(peeked) < n {
+			return false
+		}
+		switch b := peeked[n-1]; b {
+		case ' ', '\t', '\r', '\n':
+			if err != nil {
+				return false
+			}
+			continue
+		default:
+			return b == '{'
+		}
+	}
+}
+
+// envelope is a recorded event in NDJSON form
+type envelope struct {
+	ID         string          `json:"id"`
+	Event      string          `json:"event"`
+	Data       json.RawMessage `json:"data"`
+	ReceivedAt time.Time       `json:"receivedAt"`
+}
+
+func ndjsonReader(r *bufio.Reader) func() (Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	return func() (Event, error) {
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			return parseLine(line), nil
+		}
+		if err := scanner.Err(); err != nil {
+			return Event{}, err
+		}
+		return Event{}, io.EOF
+	}
+}
+
+// parseLine reads one NDJSON line as an envelope or a bare score event
+func parseLine(line []byte) Event {
+	var env envelope
+	if json.Unmarshal(line, &env) != nil || env.Data == nil {
+		return Event{Type: scoreEventType, Data: string(line)}
+	}
+
+	event := Event{ID: env.ID, Type: env.Event, Data: string(env.Data), ReceivedAt: env.ReceivedAt}
+	if event.Type == "" {
+		event.Type = scoreEventType
+	}
+
+	// Data recorded as a string holds the raw SSE payload
+	var data string
+	if json.Unmarshal(env.Data, &data) == nil {
+		event.Data = data
+	}
+	return event
+}
@@ -0,0 +1,83 @@
+package replay
+
+import (
+	"bytes"
+	"channel-test/internal/consumer"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, input string) []Event {
+	t.Helper()
+
+	reader := NewReader(strings.NewReader(input))
+	var events []Event
+	for {
+		event, err := reader.Read()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestReader_EventStream(t *testing.T) {
+	input := ": capture\nid: 1\nevent: score\ndata: {\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}\n\nevent: ping\ndata: x\n\n"
+
+	got := readAll(t, input)
+	expected := []Event{
+		{ID: "1", Type: "score", Data: `{"exam":1,"studentId":"alice","score":0.5}`},
+		{Type: "ping", Data: "x"}, // no id field of its own
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestReader_NDJSON(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	input := strings.Join([]string{
+		``,
+		`  {"id":"7","event":"score","data":"{\"exam\":1,\"studentId\":\"alice\",\"score\":0.5}","receivedAt":"2024-01-02T15:04:05Z"}`,
+		`{"id":"8","data":{"exam":2,"studentId":"bob","score":0.7}}`,
+		`{"exam":3,"studentId":"carol","score":0.9}`,
+		`not json`,
+	}, "\n")
+
+	got := readAll(t, input)
+	expected := []Event{
+		{ID: "7", Type: "score", Data: `{"exam":1,"studentId":"alice","score":0.5}`, ReceivedAt: at},
+		{ID: "8", Type: "score", Data: `{"exam":2,"studentId":"bob","score":0.7}`},
+		{Type: "score", Data: `{"exam":3,"studentId":"carol","score":0.9}`},
+		{Type: "score", Data: "not json"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestReader_GzippedRecording(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	recorded := consumer.RecordedEvent{ID: "9", Event: "score", Data: `{"exam":1,"studentId":"alice","score":0.5}`, ReceivedAt: at, ConnectionID: "c1"}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(recorded); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	zw.Close()
+
+	got := readAll(t, buf.String())
+	expected := []Event{{ID: "9", Type: "score", Data: recorded.Data, ReceivedAt: at}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
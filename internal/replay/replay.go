@@ -0,0 +1,284 @@
+// Package replay feeds recorded score events back through the service's
+// validation into a store or a running instance, to backfill scores
+// missed while the stream or the service was down.
+package replay
+
+import (
+	"channel-test/internal/consumer"
+	"channel-test/internal/validation"
+	"channel-test/pkg/models"
+	"context"
+	"io"
+	"time"
+)
+
+// defaultBatchSize is how many valid events are written to a sink at once
+const defaultBatchSize = 500
+
+// defaultSource is the source name recorded with replayed scores
+const defaultSource = "replay"
+
+// Sink receives validated score events
+type Sink interface {
+	// Write stores a batch of events. A returned error means the sink
+	// could not take the batch at all and the replay should stop.
+	Write(events []models.ScoreEvent) (Result, error)
+}
+
+// Result is the outcome of writing one batch to a Sink
+type Result struct {
+	Accepted   int
+	Duplicates int            // events the target had already received
+	Rejected   map[string]int // rejected events by reason
+}
+
+// Stats summarises a replay
+type Stats struct {
+	Events     int            `json:"events"`     // events read from the captures
+	Ignored    int            `json:"ignored"`    // events that are not scores
+	Accepted   int            `json:"accepted"`   // scores stored, or that would be in a dry run
+	Duplicates int            `json:"duplicates"` // scores the target ignored as already received
+	Rejected   map[string]int `json:"rejected"`   // scores rejected by reason
+	Students   int            `json:"students"`   // distinct students among valid scores
+	Exams      int            `json:"exams"`      // distinct exams among valid scores
+
+	// FirstReceivedAt and LastReceivedAt span the recorded timestamps
+	FirstReceivedAt *time.Time `json:"firstReceivedAt,omitempty"`
+	LastReceivedAt  *time.Time `json:"lastReceivedAt,omitempty"`
+}
+
+// Replayer validates recorded events and writes the valid scores to a Sink
+// at a controlled rate
+type Replayer struct {
+	sink      Sink
+	batchSize int
+	rate      float64 // events per second, 0 for unlimited
+	speed     float64 // original timing speed-up, 0 to ignore timestamps
+	source    string
+	validator validation.Validator
+
+	sleep func(ctx context.Context, d time.Duration) error
+	now   func() time.Time
+
+	pending  []models.ScoreEvent
+	stats    Stats
+	students map[string]bool
+	exams    map[int]bool
+
+	// Pacing anchors
+	started   time.Time
+	sent      int
+	firstSeen time.Time
+}
+
+// Option configures a Replayer
+type Option func(*Replayer)
+
+// WithBatchSize sets how many valid events are written to the sink at once.
+// The default is 500.
+func WithBatchSize(n int) Option {
+	return func(r *Replayer) {
+		r.batchSize = n
+	}
+}
+
+// WithRate limits the replay to perSecond events. Zero, the default,
+// replays as fast as the sink accepts them.
+func WithRate(perSecond float64) Option {
+	return func(r *Replayer) {
+		r.rate = perSecond
+	}
+}
+
+// WithOriginalTiming spaces events as they were recorded, sped up by
+// speed; 1 replays in real time. Events without timestamps are not delayed.
+func WithOriginalTiming(speed float64) Option {
+	return func(r *Replayer) {
+		r.speed = speed
+	}
+}
+
+// WithSource sets the source name recorded with replayed scores. The
+// default is "replay".
+func WithSource(name string) Option {
+	return func(r *Replayer) {
+		r.source = name
+	}
+}
+
+// WithValidator sets the rules replayed scores must pass. The default is
+// validation.Default().
+func WithValidator(validator validation.Validator) Option {
+	return func(r *Replayer) {
+		r.validator = validator
+	}
+}
+
+// NewReplayer creates a replayer writing to sink. A nil sink makes a dry
+// run that only validates events and reports what would be stored.
+func NewReplayer(sink Sink, opts ...Option) *Replayer {
+	r := &Replayer{
+		sink:      sink,
+		batchSize: defaultBatchSize,
+		source:    defaultSource,
+		sleep:     sleepContext,
+		now:       time.Now,
+		stats:     Stats{Rejected: make(map[string]int)},
+		students:  make(map[string]bool),
+		exams:     make(map[int]bool),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.batchSize < 1 {
+		r.batchSize = 1
+	}
+
+	return r
+}
+
+// Replay reads every event from reader. It may be called once per capture;
+// statistics and timing carry over between calls.
+func (r *Replayer) Replay(ctx context.Context, reader *Reader) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			r.flush()
+			return err
+		}
+
+		event, err := reader.Read()
+		if err == io.EOF {
+			return r.flush()
+		}
+		if err != nil {
+			r.flush()
+			return err
+		}
+
+		if err := r.pace(ctx, event.ReceivedAt); err != nil {
+			r.flush()
+			return err
+		}
+		if err := r.process(event); err != nil {
+			return err
+		}
+	}
+}
+
+// Stats returns the statistics of everything replayed so far
+func (r *Replayer) Stats() Stats {
+	stats := r.stats
+	stats.Rejected = make(map[string]int, len(r.stats.Rejected))
+	for reason, n := range r.stats.Rejected {
+		stats.Rejected[reason] = n
+	}
+	return stats
+}
+
+// process validates one event and queues it for the sink
+func (r *Replayer) process(event Event) error {
+	r.stats.Events++
+	r.sent++
+	if !event.ReceivedAt.IsZero() {
+		at := event.ReceivedAt
+		if r.stats.FirstReceivedAt == nil || at.Before(*r.stats.FirstReceivedAt) {
+			r.stats.FirstReceivedAt = &at
+		}
+		if r.stats.LastReceivedAt == nil || at.After(*r.stats.LastReceivedAt) {
+			r.stats.LastReceivedAt = &at
+		}
+	}
+
+	if event.Type != scoreEventType {
+		r.stats.Ignored++
+		return nil
+	}
+
+	score, rejectErr := consumer.ParseScoreEvent(event.Data, r.validator)
+	if rejectErr != nil {
+		r.stats.Rejected[rejectErr.Reason]++
+		return nil
+	}
+	score.Source = r.source
+	score.ReceivedAt = event.ReceivedAt
+	if score.ID == "" {
+		score.ID = event.ID
+	}
+	r.students[score.StudentID] = true
+	r.exams[score.Exam] = true
+	r.stats.Students = len(r.students)
+	r.stats.Exams = len(r.exams)
+
+	if r.sink == nil {
+		r.stats.Accepted++
+		return nil
+	}
+
+	r.pending = append(r.pending, score)
+	if len(r.pending) >= r.batchSize {
+		return r.flush()
+	}
+	return nil
+}
+
+// flush writes pending events to the sink
+func (r *Replayer) flush() error {
+	if len(r.pending) == 0 || r.sink == nil {
+		return nil
+	}
+
+	result, err := r.sink.Write(r.pending)
+	r.pending = r.pending[:0]
+	r.stats.Accepted += result.Accepted
+	r.stats.Duplicates += result.Duplicates
+	for reason, n := range result.Rejected {
+		r.stats.Rejected[reason] += n
+	}
+	return err
+}
+
+// pace waits until the next event is due under the rate limit and original
+// timing, flushing queued events first so the target sees them on time
+func (r *Replayer) pace(ctx context.Context, receivedAt time.Time) error {
+	now := r.now()
+	if r.started.IsZero() {
+		r.started = now
+	}
+
+	var due time.Time
+	if r.rate > 0 {
+		due = r.started.Add(time.Duration(float64(r.sent) / r.rate * float64(time.Second)))
+	}
+	if r.speed > 0 && !receivedAt.IsZero() {
+		if r.firstSeen.IsZero() {
+			r.firstSeen = receivedAt
+		}
+		recorded := r.started.Add(time.Duration(float64(receivedAt.Sub(r.firstSeen)) / r.speed))
+		if recorded.After(due) {
+			due = recorded
+		}
+	}
+
+	wait := due.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	if err := r.flush(); err != nil {
+		return err
+	}
+	return r.sleep(ctx, wait)
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package replay
+
+import (
+	"channel-test/internal/consumer"
+	"channel-test/internal/store"
+	"channel-test/pkg/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const capture = `{"id":"1","data":{"exam":1,"studentId":"alice","score":0.5},"receivedAt":"2024-01-02T15:00:00Z"}
+{"id":"2","data":{"exam":1,"studentId":"bob","score":1.5},"receivedAt":"2024-01-02T15:00:01Z"}
+{"id":"3","event":"heartbeat","data":"","receivedAt":"2024-01-02T15:00:02Z"}
+{"id":"4","data":{"exam":2,"studentId":"alice","score":0.9},"receivedAt":"2024-01-02T15:00:10Z"}
+`
+
+func TestReplayer_DryRun(t *testing.T) {
+	r := NewReplayer(nil)
+	if err := r.Replay(context.Background(), NewReader(strings.NewReader(capture))); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	stats := r.Stats()
+	if stats.Events != 4 || stats.Ignored != 1 || stats.Accepted != 2 {
+		t.Errorf("Expected 4 events, 1 ignored and 2 accepted, got %+v", stats)
+	}
+	if stats.Rejected[consumer.ReasonScoreOutOfRange] != 1 {
+		t.Errorf("Expected 1 score out of range, got %v", stats.Rejected)
+	}
+	if stats.Students != 1 || stats.Exams != 2 {
+		t.Errorf("Expected 1 student and 2 exams, got %d and %d", stats.Students, stats.Exams)
+	}
+	if stats.LastReceivedAt.Sub(*stats.FirstReceivedAt) != 10*time.Second {
+		t.Errorf("Expected a 10s span, got %s to %s", stats.FirstReceivedAt, stats.LastReceivedAt)
+	}
+}
+
+func TestReplayer_StoreSink(t *testing.T) {
+	s := store.NewMemoryStore()
+	r := NewReplayer(NewStoreSink(s), WithBatchSize(1))
+	if err := r.Replay(context.Background(), NewReader(strings.NewReader(capture))); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if got := r.Stats().Accepted; got != 2 {
+		t.Errorf("Expected 2 accepted, got %d", got)
+	}
+
+	history, err := s.GetScoreHistory("alice", 1)
+	if err != nil {
+		t.Fatalf("GetScoreHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Source != "replay" {
+		t.Fatalf("Expected one replayed score, got %+v", history)
+	}
+	if expected := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC); !history[0].ReceivedAt.Equal(expected) {
+		t.Errorf("Expected the recorded receivedAt %s, got %s", expected, history[0].ReceivedAt)
+	}
+}
+
+func TestReplayer_HTTPSink(t *testing.T) {
+	var batches [][]models.ScoreEvent
+	var receivedAt []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scores/batch" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer 0123456789abcdef" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var batch []models.ScoreEvent
+		decoder := json.NewDecoder(r.Body)
+		for {
+			var line batchLine
+			if err := decoder.Decode(&line); err == io.EOF {
+				break
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			batch = append(batch, line.ScoreEvent)
+			if line.ReceivedAt != nil {
+				receivedAt = append(receivedAt, *line.ReceivedAt)
+			}
+		}
+		batches = append(batches, batch)
+
+		// Reject every score for exam 2 as if the store failed
+		accepted, results := 0, []string{}
+		for i, event := range batch {
+			if event.Exam == 2 {
+				results = append(results, fmt.Sprintf(`{"index":%d,"status":"rejected","reason":%q}`, i, consumer.ReasonStoreError))
+				continue
+			}
+			results = append(results, fmt.Sprintf(`{"index":%d,"status":"accepted"}`, i))
+			accepted++
+		}
+		fmt.Fprintf(w, `{"accepted":%d,"results":[%s]}`, accepted, strings.Join(results, ","))
+	}))
+	defer server.Close()
+
+	r := NewReplayer(NewHTTPSink(server.URL+"/", "0123456789abcdef", nil))
+	if err := r.Replay(context.Background(), NewReader(strings.NewReader(capture))); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("Expected one batch of 2 scores, got %v", batches)
+	}
+	if len(receivedAt) != 2 || !receivedAt[1].Equal(time.Date(2024, 1, 2, 15, 0, 10, 0, time.UTC)) {
+		t.Errorf("Expected the recorded receive times to be posted, got %v", receivedAt)
+	}
+	stats := r.Stats()
+	if stats.Accepted != 1 || stats.Rejected[consumer.ReasonStoreError] != 1 {
+		t.Errorf("Expected 1 accepted and 1 store error, got %+v", stats)
+	}
+}
+
+func TestReplayer_HTTPSinkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	r := NewReplayer(NewHTTPSink(server.URL, "", nil))
+	err := r.Replay(context.Background(), NewReader(strings.NewReader(capture)))
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected status 503 error, got %v", err)
+	}
+}
+
+func TestReplayer_Pacing(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected []time.Duration
+	}{
+		{"as fast as possible", nil, nil},
+		{"rate", []Option{WithRate(2)}, []time.Duration{500 * time.Millisecond, time.Second, 1500 * time.Millisecond}},
+		{"original timing", []Option{WithOriginalTiming(1)}, []time.Duration{time.Second, 2 * time.Second, 10 * time.Second}},
+		{"original timing sped up", []Option{WithOriginalTiming(10)}, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, time.Second}},
+		{"rate caps original timing", []Option{WithOriginalTiming(10), WithRate(2)}, []time.Duration{500 * time.Millisecond, time.Second, 1500 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		// A fake clock that advances by each sleep, recording the offsets
+		start := time.Now()
+		now := start
+		var slept []time.Duration
+
+		r := NewReplayer(nil, tt.opts...)
+		r.now = func() time.Time { return now }
+		r.sleep = func(ctx context.Context, d time.Duration) error {
+			now = now.Add(d)
+			slept = append(slept, now.Sub(start))
+			return nil
+		}
+
+		if err := r.Replay(context.Background(), NewReader(strings.NewReader(capture))); err != nil {
+			t.Fatalf("%s: Replay failed: %v", tt.name, err)
+		}
+		if fmt.Sprint(slept) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: Expected events at %v, got %v", tt.name, tt.expected, slept)
+		}
+	}
+}
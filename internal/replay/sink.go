@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"bytes"
+	"channel-test/internal/consumer"
+	"channel-test/internal/store"
+	"channel-test/pkg/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StoreSink writes scores directly to a store, such as a FileStore opened
+// on the data directory of a stopped instance
+type StoreSink struct {
+	store store.Store
+}
+
+// NewStoreSink creates a sink adding scores to s
+func NewStoreSink(s store.Store) *StoreSink {
+	return &StoreSink{store: s}
+}
+
+// Write adds each event to the store, counting failures as store errors
+func (s *StoreSink) Write(events []models.ScoreEvent) (Result, error) {
+	result := Result{Rejected: make(map[string]int)}
+	for _, event := range events {
+		stored, err := s.store.AddScore(event)
+		if err != nil {
+			result.Rejected[consumer.ReasonStoreError]++
+			continue
+		}
+		if !stored {
+			result.Duplicates++
+			continue
+		}
+		result.Accepted++
+	}
+	return result, nil
+}
+
+// batchLine is one NDJSON line posted to /scores/batch, carrying the
+// recorded receive time that models.ScoreEvent leaves out of its JSON
+type batchLine struct {
+	models.ScoreEvent
+	ReceivedAt *time.Time `json:"receivedAt,omitempty"`
+}
+
+// HTTPSink posts scores to a running instance's POST /scores/batch endpoint
+type HTTPSink struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPSink creates a sink posting to the instance at baseURL, such as
+// http://localhost:8080, authenticating with apiKey
+func NewHTTPSink(baseURL, apiKey string, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPSink{
+		url:    strings.TrimSuffix(baseURL, "/") + "/scores/batch",
+		apiKey: apiKey,
+		client: client,
+	}
+}
+
+// batchResponse is the body returned by POST /scores/batch
+type batchResponse struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+	Results    []struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	} `json:"results"`
+}
+
+// Write posts the events as one NDJSON batch, with their recorded receive
+// times. Scores the instance rejects
+// are counted by reason; a failed request or non-200 response is an error.
+func (s *HTTPSink) Write(events []models.ScoreEvent) (Result, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, event := range events {
+		line := batchLine{ScoreEvent: event}
+		if !event.ReceivedAt.IsZero() {
+			line.ReceivedAt = &event.ReceivedAt
+		}
+		if err := encoder.Encode(line); err != nil {
+			return Result{}, fmt.Errorf("failed to encode score: %w", err)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to post batch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Result{}, fmt.Errorf("unexpected status code %d from %s: %s", resp.StatusCode, s.url, strings.TrimSpace(string(message)))
+	}
+
+	var batch batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return Result{}, fmt.Errorf("failed to decode batch response: %w", err)
+	}
+
+	result := Result{Accepted: batch.Accepted, Duplicates: batch.Duplicates, Rejected: make(map[string]int)}
+	for _, item := range batch.Results {
+		if item.Status == "rejected" {
+			result.Rejected[item.Reason]++
+		}
+	}
+	return result, nil
+}
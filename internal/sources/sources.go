@@ -0,0 +1,232 @@
+package sources
+
+import (
+	"bytes"
+	"channel-test/internal/consumer"
+	"channel-test/internal/store"
+	"channel-test/internal/validation"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+)
+
+// namePattern is what a source name may contain; names appear in metrics
+// labels, logs and checkpoint file names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Config lists the upstream score streams to consume. It is read from a
+// JSON file such as
+//
+//	{
+//	  "sources": [
+//	    {"name": "primary", "url": "https://scores.example.com/scores"},
+//	    {
+//	      "name": "partner",
+//	      "url": "https://partner.example.com/events",
+//	      "headers": {"Authorization": "Bearer s3cret"},
+//	      "checkpointFile": "data/partner-last-event-id",
+//	      "reconnect": {"initialDelay": "5s", "maxDelay": "2m", "maxAttempts": 20},
+//	      "validationRules": "config/partner-rules.json"
+//	    }
+//	  ]
+//	}
+//
+// Only name and url are required; the other settings default to the
+// service-wide ones given to Build.
+type Config struct {
+	Sources []Source `json:"sources"`
+}
+
+// Source is one upstream score stream
+type Source struct {
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	Headers         map[string]string `json:"headers"`
+	CheckpointFile  string            `json:"checkpointFile"`
+	Reconnect       *Reconnect        `json:"reconnect"`
+	ValidationRules string            `json:"validationRules"` // JSON rules file
+}
+
+// Reconnect overrides the reconnect policy of a source. Omitted fields
+// keep the service-wide setting.
+type Reconnect struct {
+	InitialDelay string `json:"initialDelay"`
+	MaxDelay     string `json:"maxDelay"`
+	MaxAttempts  *int   `json:"maxAttempts"` // 0 retries forever
+}
+
+// Defaults are the service-wide settings used where a source doesn't set
+// its own
+type Defaults struct {
+	// CheckpointFile is suffixed with "." and the source name
+	CheckpointFile string
+	Backoff        *consumer.ExponentialBackoff // nil for consumer.NewExponentialBackoff()
+	Validator      validation.Validator         // nil for validation.Default()
+	Logger         *slog.Logger
+}
+
+// LoadConfig reads sources from the JSON file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sources: %w", err)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig reads sources from JSON, rejecting unknown fields. Every
+// invalid source is reported, not just the first.
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid sources: %w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid sources:\n%w", err)
+	}
+	return &cfg, nil
+}
+
+// validate checks every source, returning all problems joined
+func (c *Config) validate() error {
+	if len(c.Sources) == 0 {
+		return errors.New("no sources configured")
+	}
+
+	var errs []error
+	names := make(map[string]bool)
+	for i, s := range c.Sources {
+		invalid := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("sources[%d] %s: %s", i, s.Name, fmt.Sprintf(format, args...)))
+		}
+
+		switch {
+		case !namePattern.MatchString(s.Name):
+			invalid("name must match %s", namePattern)
+		case names[s.Name]:
+			invalid("duplicate name")
+		}
+		names[s.Name] = true
+
+		if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("url must be an http or https URL, got %q", s.URL)
+		}
+
+		if s.Reconnect != nil {
+			if _, err := s.Reconnect.apply(consumer.ExponentialBackoff{}); err != nil {
+				invalid("reconnect: %v", err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// apply returns base with the overrides set in r
+func (r *Reconnect) apply(base consumer.ExponentialBackoff) (consumer.ExponentialBackoff, error) {
+	parse := func(key, value string, d *time.Duration) error {
+		if value == "" {
+			return nil
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("%s must be a positive duration, got %q", key, value)
+		}
+		*d = parsed
+		return nil
+	}
+
+	if err := parse("initialDelay", r.InitialDelay, &base.InitialDelay); err != nil {
+		return base, err
+	}
+	if err := parse("maxDelay", r.MaxDelay, &base.MaxDelay); err != nil {
+		return base, err
+	}
+	if r.MaxAttempts != nil {
+		if *r.MaxAttempts < 0 {
+			return base, fmt.Errorf("maxAttempts must not be negative, got %d", *r.MaxAttempts)
+		}
+		base.MaxAttempts = *r.MaxAttempts
+	}
+	if base.MaxDelay != 0 && base.MaxDelay < base.InitialDelay {
+		return base, fmt.Errorf("maxDelay must be at least initialDelay (%s)", base.InitialDelay)
+	}
+	return base, nil
+}
+
+// Build creates a consumer for every source, storing scores in s. Sources
+// with their own validation rules check them against s's history. opts
+// are shared by every consumer, such as a recorder or dead-letter queue.
+func (c *Config) Build(s store.Store, defaults Defaults, opts ...consumer.Option) ([]*consumer.SSEConsumer, error) {
+	logger := defaults.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	validator := defaults.Validator
+	if validator == nil {
+		validator = validation.Default()
+	}
+	defaultBackoff := defaults.Backoff
+	if defaultBackoff == nil {
+		defaultBackoff = consumer.NewExponentialBackoff()
+	}
+
+	var consumers []*consumer.SSEConsumer
+	var errs []error
+	for _, source := range c.Sources {
+		backoff := *defaultBackoff
+		if source.Reconnect != nil {
+			var err error
+			if backoff, err = source.Reconnect.apply(backoff); err != nil {
+				errs = append(errs, fmt.Errorf("source %s: reconnect: %w", source.Name, err))
+				continue
+			}
+		}
+
+		sourceValidator := validator
+		if source.ValidationRules != "" {
+			rules, err := validation.LoadConfig(source.ValidationRules)
+			if err == nil {
+				sourceValidator, err = rules.Build(s)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("source %s: %w", source.Name, err))
+				continue
+			}
+		}
+
+		checkpointFile := source.CheckpointFile
+		if checkpointFile == "" {
+			checkpointFile = defaults.CheckpointFile + "." + source.Name
+		}
+
+		header := make(http.Header)
+		for name, value := range source.Headers {
+			header.Set(name, value)
+		}
+
+		sourceOpts := append([]consumer.Option{}, opts...)
+		sourceOpts = append(sourceOpts,
+			consumer.WithSource(source.Name),
+			consumer.WithHeaders(header),
+			consumer.WithCheckpoint(consumer.NewFileCheckpoint(checkpointFile)),
+			consumer.WithReconnectPolicy(&backoff),
+			consumer.WithValidator(sourceValidator),
+			consumer.WithLogger(logger.With("source", source.Name)),
+		)
+		consumers = append(consumers, consumer.NewSSEConsumer(source.URL, s, sourceOpts...))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return consumers, nil
+}
@@ -0,0 +1,100 @@
+package sources
+
+import (
+	"channel-test/internal/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{
+		"sources": [
+			{"name": "primary", "url": "https://scores.example.com/scores"},
+			{
+				"name": "partner",
+				"url": "http://partner.example.com/events",
+				"headers": {"Authorization": "Bearer s3cret"},
+				"reconnect": {"initialDelay": "5s", "maxDelay": "2m", "maxAttempts": 3}
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	if len(cfg.Sources) != 2 {
+		t.Fatalf("Expected 2 sources, got %d", len(cfg.Sources))
+	}
+	partner := cfg.Sources[1]
+	if partner.Headers["Authorization"] != "Bearer s3cret" || partner.Reconnect == nil || *partner.Reconnect.MaxAttempts != 3 {
+		t.Errorf("Expected partner headers and reconnect settings, got %+v", partner)
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	_, err := ParseConfig([]byte(`{
+		"sources": [
+			{"name": "primary", "url": "https://scores.example.com/scores"},
+			{"name": "primary", "url": "https://other.example.com/scores"},
+			{"name": "has space", "url": "https://scores.example.com/scores"},
+			{"name": "ftp", "url": "ftp://scores.example.com/scores"},
+			{"name": "slow", "url": "https://scores.example.com/scores", "reconnect": {"initialDelay": "1m", "maxDelay": "1s"}},
+			{"name": "negative", "url": "https://scores.example.com/scores", "reconnect": {"maxAttempts": -1}}
+		]
+	}`))
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	// Every invalid source is reported
+	for _, expected := range []string{
+		"sources[1] primary: duplicate name",
+		"sources[2] has space: name must match",
+		"sources[3] ftp: url must be an http or https URL",
+		"sources[4] slow: reconnect: maxDelay must be at least initialDelay",
+		"sources[5] negative: reconnect: maxAttempts must not be negative",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to mention %q, got:\n%v", expected, err)
+		}
+	}
+
+	for _, data := range []string{`{"sources": []}`, `{"sources": [{"name": "a", "url": "http://a", "extra": 1}]}`} {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("Expected error for %s", data)
+		}
+	}
+}
+
+func TestConfig_Build(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rules, []byte(`{"scoreScale": {"min": 0, "max": 100}}`), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg := &Config{Sources: []Source{
+		{Name: "primary", URL: "http://primary.example.com"},
+		{Name: "partner", URL: "http://partner.example.com", CheckpointFile: filepath.Join(dir, "partner-id"), ValidationRules: rules},
+	}}
+	consumers, err := cfg.Build(store.NewMemoryStore(), Defaults{CheckpointFile: filepath.Join(dir, "last-event-id")})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if len(consumers) != 2 {
+		t.Fatalf("Expected 2 consumers, got %d", len(consumers))
+	}
+	for i, name := range []string{"primary", "partner"} {
+		if status := consumers[i].Status(); status.Source != name || status.URL != cfg.Sources[i].URL {
+			t.Errorf("Expected consumer for %s, got %+v", name, status)
+		}
+	}
+
+	cfg.Sources[1].ValidationRules = filepath.Join(dir, "missing.json")
+	if _, err := cfg.Build(store.NewMemoryStore(), Defaults{}); err == nil || !strings.Contains(err.Error(), "source partner") {
+		t.Errorf("Expected error naming source partner, got %v", err)
+	}
+}
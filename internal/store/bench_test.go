@@ -0,0 +1,357 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchSizes are the numbers of scores the benchmarks run against. The
+// largest is skipped with -short.
+var benchSizes = []int{1_000, 100_000, 1_000_000}
+
+// benchExams is the number of exams scores are spread over
+const benchExams = 20
+
+var (
+	benchMu     sync.Mutex
+	benchStores = make(map[int]*MemoryStore)
+)
+
+// benchEvent returns the nth generated score. Each student takes every
+// exam once, so size scores cover size/benchExams students.
+func benchEvent(n, size int) models.ScoreEvent {
+	students := max(size/benchExams, 1)
+	return models.ScoreEvent{
+		Exam:      1 + (n/students)%benchExams,
+		StudentID: fmt.Sprintf("student-%07d", n%students),
+		Score:     float64((n*7919)%1000) / 1000,
+	}
+}
+
+// fillStore adds size generated scores to s
+func fillStore(b *testing.B, s Store, size int) {
+	b.Helper()
+	for n := 0; n < size; n++ {
+		if _, err := s.AddScore(benchEvent(n, size)); err != nil {
+			b.Fatalf("AddScore failed: %v", err)
+		}
+	}
+}
+
+// benchStore returns a memory store holding size scores, shared by the
+// read-only benchmarks
+func benchStore(b *testing.B, size int) *MemoryStore {
+	b.Helper()
+	benchMu.Lock()
+	defer benchMu.Unlock()
+
+	if s, ok := benchStores[size]; ok {
+		return s
+	}
+	s := NewMemoryStore()
+	fillStore(b, s, size)
+	benchStores[size] = s
+	return s
+}
+
+// runReads runs fn against a shared store of each size, timing only fn
+func runReads(b *testing.B, fn func(b *testing.B, s *MemoryStore, size int)) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("scores=%d", size), func(b *testing.B) {
+			if testing.Short() && size >= 1_000_000 {
+				b.Skip("skipping 1M scores in short mode")
+			}
+			s := benchStore(b, size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			fn(b, s, size)
+		})
+	}
+}
+
+// runWrites times adding b.N scores to a store from newStore holding each
+// size of scores. The store is filled once per size, so the runs that size
+// b.N keep adding to it rather than refilling it.
+func runWrites(b *testing.B, newStore func(size int) (Store, error)) {
+	for _, size := range benchSizes {
+		var s Store
+		next := size
+
+		b.Run(fmt.Sprintf("scores=%d", size), func(b *testing.B) {
+			if testing.Short() && size >= 1_000_000 {
+				b.Skip("skipping 1M scores in short mode")
+			}
+			if s == nil {
+				var err error
+				if s, err = newStore(size); err != nil {
+					b.Fatalf("Failed to create store: %v", err)
+				}
+				fillStore(b, s, size)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := s.AddScore(benchEvent(next, size)); err != nil {
+					b.Fatalf("AddScore failed: %v", err)
+				}
+				next++
+			}
+		})
+
+		if closer, ok := s.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+func BenchmarkMemoryStore_AddScore(b *testing.B) {
+	runWrites(b, func(size int) (Store, error) {
+		return NewMemoryStore(), nil
+	})
+}
+
+func BenchmarkMemoryStore_AddScoreDedup(b *testing.B) {
+	runWrites(b, func(size int) (Store, error) {
+		return NewMemoryStore(WithDedup(DefaultDedupWindow, DefaultDedupSize)), nil
+	})
+}
+
+func BenchmarkMemoryStore_OnScore(b *testing.B) {
+	runWrites(b, func(size int) (Store, error) {
+		s := NewMemoryStore()
+		var published int
+		s.OnScore(func(record models.ScoreRecord) { published++ })
+		return s, nil
+	})
+}
+
+func BenchmarkFileStore_AddScore(b *testing.B) {
+	dir := b.TempDir()
+	runWrites(b, func(size int) (Store, error) {
+		return NewFileStore(filepath.Join(dir, strconv.Itoa(size)), WithCompactEvery(0))
+	})
+}
+
+func BenchmarkMemoryStore_GetAllStudents(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			s.GetAllStudents()
+		}
+	})
+}
+
+func BenchmarkMemoryStore_GetStudent(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetStudent(benchEvent(i, size).StudentID); err != nil {
+				b.Fatalf("GetStudent failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_GetAllExams(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			s.GetAllExams()
+		}
+	})
+}
+
+func BenchmarkMemoryStore_GetExam(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetExam(1 + i%benchExams); err != nil {
+				b.Fatalf("GetExam failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_GetExamStats(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetExamStats(1+i%benchExams, ExamStatsQuery{}); err != nil {
+				b.Fatalf("GetExamStats failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_GetExamLeaderboard(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetExamLeaderboard(1+i%benchExams, 10); err != nil {
+				b.Fatalf("GetExamLeaderboard failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_GetLeaderboard(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			s.GetLeaderboard(10, 0)
+		}
+	})
+}
+
+func BenchmarkMemoryStore_QueryStudents(b *testing.B) {
+	minAverage := 0.5
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			_, err := s.QueryStudents(StudentQuery{MinAverage: &minAverage, SortBy: SortStudentAverage, Descending: true, Limit: 50})
+			if err != nil {
+				b.Fatalf("QueryStudents failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_QueryExams(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.QueryExams(ExamQuery{SortBy: SortExamAverage, Limit: 10}); err != nil {
+				b.Fatalf("QueryExams failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_GetScoreHistory(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			event := benchEvent(i, size)
+			if _, err := s.GetScoreHistory(event.StudentID, event.Exam); err != nil {
+				b.Fatalf("GetScoreHistory failed: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_Stats(b *testing.B) {
+	runReads(b, func(b *testing.B, s *MemoryStore, size int) {
+		for i := 0; i < b.N; i++ {
+			s.Stats()
+		}
+	})
+}
+
+// contendedSize is the number of scores the concurrency benchmarks start
+// with
+const contendedSize = 100_000
+
+// contendedStores are the stores compared under concurrent load
+var contendedStores = []struct {
+	name     string
+	newStore func() Store
+}{
+	{"memory", func() Store { return NewMemoryStore() }},
+	{"sharded", func() Store { return NewShardedStore(DefaultShards) }},
+}
+
+func BenchmarkStore_AddScoreParallel(b *testing.B) {
+	for _, store := range contendedStores {
+		b.Run("store="+store.name, func(b *testing.B) {
+			s := store.newStore()
+			fillStore(b, s, contendedSize)
+			var next atomic.Int64
+			next.Store(contendedSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.AddScore(benchEvent(int(next.Add(1)), contendedSize))
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkStore_ReadUnderWriteLoad times reads while one writer per CPU
+// adds scores as fast as it can, reporting the p99 read latency and the
+// writes that got through alongside the mean
+func BenchmarkStore_ReadUnderWriteLoad(b *testing.B) {
+	reads := []struct {
+		name string
+		read func(s Store, i int)
+	}{
+		{"GetStudent", func(s Store, i int) { s.GetStudent(benchEvent(i, contendedSize).StudentID) }},
+		{"GetScoreHistory", func(s Store, i int) {
+			event := benchEvent(i, contendedSize)
+			s.GetScoreHistory(event.StudentID, event.Exam)
+		}},
+		{"GetExam", func(s Store, i int) { s.GetExam(1 + i%benchExams) }},
+		{"GetExamStats", func(s Store, i int) { s.GetExamStats(1+i%benchExams, ExamStatsQuery{}) }},
+		{"GetExamLeaderboard", func(s Store, i int) { s.GetExamLeaderboard(1+i%benchExams, 10) }},
+		{"GetLeaderboard", func(s Store, i int) { s.GetLeaderboard(10, 0) }},
+		{"QueryStudents", func(s Store, i int) { s.QueryStudents(StudentQuery{Limit: 10}) }},
+		{"Stats", func(s Store, i int) { s.Stats() }},
+	}
+
+	for _, store := range contendedStores {
+		s := store.newStore()
+		filled := false
+
+		for _, read := range reads {
+			b.Run(fmt.Sprintf("store=%s/read=%s", store.name, read.name), func(b *testing.B) {
+				if !filled {
+					fillStore(b, s, contendedSize)
+					filled = true
+				}
+
+				var writes atomic.Int64
+				stop := make(chan struct{})
+				var wg sync.WaitGroup
+				for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for n := w; ; n += runtime.GOMAXPROCS(0) {
+							select {
+							case <-stop:
+								return
+							default:
+							}
+							s.AddScore(benchEvent(n, contendedSize))
+							writes.Add(1)
+						}
+					}()
+				}
+
+				// Let every writer get going before timing reads
+				for writes.Load() < int64(10*runtime.GOMAXPROCS(0)) {
+					time.Sleep(100 * time.Microsecond)
+				}
+
+				latencies := make([]time.Duration, b.N)
+				b.ResetTimer()
+				start := time.Now()
+				for i := 0; i < b.N; i++ {
+					began := time.Now()
+					read.read(s, i)
+					latencies[i] = time.Since(began)
+				}
+				elapsed := time.Since(start)
+				b.StopTimer()
+				close(stop)
+				wg.Wait()
+
+				slices.Sort(latencies)
+				b.ReportMetric(float64(latencies[(len(latencies)*99)/100]), "p99-ns")
+				b.ReportMetric(float64(writes.Load())/elapsed.Seconds(), "writes/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultDedupWindow is how long a score is remembered for deduplication
+	DefaultDedupWindow = 10 * time.Minute
+
+	// DefaultDedupSize is the most scores remembered for deduplication
+	DefaultDedupSize = 100000
+)
+
+// dedupKey identifies a score for deduplication: its source and event ID,
+// as IDs are only unique within one source, or its exam, student and score
+// when the sender gave no ID
+func dedupKey(record models.ScoreRecord) string {
+	if record.EventID != "" {
+		return "id:" + record.Source + "/" + record.EventID
+	}
+	return "score:" + strconv.Itoa(record.Exam) + "/" + record.StudentID + "/" + strconv.FormatFloat(record.Score, 'g', -1, 64)
+}
+
+// dedupEntry is a remembered key and when its score was received
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+// dedupIndex remembers the keys of recently received scores, forgetting
+// them once they are older than window or more than size are held. Scores
+// without an event ID are only remembered when content is set.
+type dedupIndex struct {
+	window  time.Duration
+	size    int
+	content bool
+	seen    map[string]time.Time
+	order   []dedupEntry // oldest first; may hold stale entries for re-seen keys
+}
+
+func newDedupIndex(window time.Duration, size int, content bool) *dedupIndex {
+	return &dedupIndex{
+		window:  window,
+		size:    size,
+		content: content,
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// key returns the key record is deduplicated by, or false if it isn't
+func (d *dedupIndex) key(record models.ScoreRecord) (string, bool) {
+	if record.EventID == "" && !d.content {
+		return "", false
+	}
+	return dedupKey(record), true
+}
+
+// contains reports whether key was received within the window before now
+func (d *dedupIndex) contains(key string, now time.Time) bool {
+	at, ok := d.seen[key]
+	return ok && now.Sub(at) <= d.window
+}
+
+// remember records the record's key as received at its time
+func (d *dedupIndex) remember(record models.ScoreRecord) {
+	key, ok := d.key(record)
+	if !ok {
+		return
+	}
+	d.seen[key] = record.ReceivedAt
+	d.order = append(d.order, dedupEntry{key: key, at: record.ReceivedAt})
+	d.evict(record.ReceivedAt)
+}
+
+// evict forgets keys that fell out of the window or over the size limit
+func (d *dedupIndex) evict(now time.Time) {
+	drop := 0
+	for drop < len(d.order) {
+		entry := d.order[drop]
+		if len(d.seen) <= d.size && now.Sub(entry.at) <= d.window {
+			break
+		}
+		// A later entry for the same key keeps it remembered
+		if d.seen[entry.key].Equal(entry.at) {
+			delete(d.seen, entry.key)
+		}
+		drop++
+	}
+
+	if drop > 0 {
+		d.order = d.order[drop:]
+	}
+	// Reclaim the dropped prefix once it is most of the backing array
+	if cap(d.order) > 2*len(d.order)+64 {
+		d.order = append([]dedupEntry(nil), d.order...)
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Dedup(t *testing.T) {
+	store := NewMemoryStore(WithDedup(time.Minute, 100))
+
+	var published int
+	store.OnScore(func(record models.ScoreRecord) {
+		published++
+	})
+
+	tests := []struct {
+		name     string
+		event    models.ScoreEvent
+		expected bool
+	}{
+		{"first event", models.ScoreEvent{ID: "1", Exam: 1, StudentID: "alice", Score: 0.8}, true},
+		{"redelivered ID", models.ScoreEvent{ID: "1", Exam: 1, StudentID: "alice", Score: 0.8}, false},
+		{"new ID with same score", models.ScoreEvent{ID: "2", Exam: 1, StudentID: "alice", Score: 0.8}, true},
+		{"no ID", models.ScoreEvent{Exam: 2, StudentID: "alice", Score: 0.5}, true},
+		{"same content without ID", models.ScoreEvent{Exam: 2, StudentID: "alice", Score: 0.5}, true},
+		{"different score without ID", models.ScoreEvent{Exam: 2, StudentID: "alice", Score: 0.6}, true},
+		{"same ID from another source", models.ScoreEvent{ID: "1", Exam: 3, StudentID: "alice", Score: 0.8, Source: "partner"}, true},
+	}
+
+	for _, tt := range tests {
+		stored, err := store.AddScore(tt.event)
+		if err != nil {
+			t.Fatalf("%s: AddScore failed: %v", tt.name, err)
+		}
+		if stored != tt.expected {
+			t.Errorf("%s: Expected stored %v, got %v", tt.name, tt.expected, stored)
+		}
+	}
+
+	stats := store.Stats()
+	if stats.Scores != 6 || stats.Duplicates != 1 {
+		t.Errorf("Expected 6 scores and 1 duplicate, got %+v", stats)
+	}
+	if published != 6 {
+		t.Errorf("Expected hooks to run for 6 stored scores, got %d", published)
+	}
+
+	history, _ := store.GetScoreHistory("alice", 1)
+	if len(history) != 2 || history[0].EventID != "1" || history[1].EventID != "2" {
+		t.Errorf("Expected event IDs 1 and 2 in history, got %+v", history)
+	}
+}
+
+func TestMemoryStore_ContentDedup(t *testing.T) {
+	store := NewMemoryStore(WithDedup(time.Minute, 100), WithContentDedup())
+
+	tests := []struct {
+		name     string
+		event    models.ScoreEvent
+		expected bool
+	}{
+		{"no ID", models.ScoreEvent{Exam: 2, StudentID: "alice", Score: 0.5}, true},
+		{"same content without ID", models.ScoreEvent{Exam: 2, StudentID: "alice", Score: 0.5}, false},
+		{"different score without ID", models.ScoreEvent{Exam: 2, StudentID: "alice", Score: 0.6}, true},
+		{"same content with an ID", models.ScoreEvent{ID: "1", Exam: 2, StudentID: "alice", Score: 0.5}, true},
+	}
+
+	for _, tt := range tests {
+		stored, _ := store.AddScore(tt.event)
+		if stored != tt.expected {
+			t.Errorf("%s: Expected stored %v, got %v", tt.name, tt.expected, stored)
+		}
+	}
+}
+
+func TestMemoryStore_DedupOffByDefault(t *testing.T) {
+	store := NewMemoryStore()
+
+	for i := 0; i < 2; i++ {
+		stored, _ := store.AddScore(models.ScoreEvent{ID: "1", Exam: 1, StudentID: "alice", Score: 0.8})
+		if !stored {
+			t.Errorf("Expected every score to be stored without deduplication")
+		}
+	}
+	if stats := store.Stats(); stats.Scores != 2 || stats.Duplicates != 0 {
+		t.Errorf("Expected 2 scores and no duplicates, got %+v", stats)
+	}
+}
+
+func TestDedupIndex_Evicts(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	record := func(id string, at time.Duration) models.ScoreRecord {
+		return models.ScoreRecord{EventID: id, ReceivedAt: start.Add(at)}
+	}
+	key := func(id string) string {
+		return dedupKey(record(id, 0))
+	}
+
+	d := newDedupIndex(time.Minute, 2, true)
+	d.remember(record("a", 0))
+	d.remember(record("b", 30*time.Second))
+
+	if !d.contains(key("a"), start.Add(time.Minute)) {
+		t.Error("Expected a to be remembered within the window")
+	}
+	if d.contains(key("a"), start.Add(time.Minute+time.Second)) {
+		t.Error("Expected a to be forgotten after the window")
+	}
+
+	// A third key exceeds the size, dropping the oldest
+	d.remember(record("c", 40*time.Second))
+	if d.contains(key("a"), start.Add(40*time.Second)) {
+		t.Error("Expected a to be evicted over the size limit")
+	}
+	if !d.contains(key("b"), start.Add(40*time.Second)) || !d.contains(key("c"), start.Add(40*time.Second)) {
+		t.Error("Expected b and c to be remembered")
+	}
+
+	// Receiving b again keeps it past its first entry's expiry
+	d.remember(record("b", 80*time.Second))
+	d.remember(record("d", 95*time.Second))
+	if !d.contains(key("b"), start.Add(95*time.Second)) {
+		t.Error("Expected b to be remembered from its latest receipt")
+	}
+	if d.contains(key("c"), start.Add(95*time.Second)) {
+		t.Error("Expected c to be evicted")
+	}
+}
+
+func TestFileStore_DedupAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	event := models.ScoreEvent{ID: "42", Exam: 1, StudentID: "alice", Score: 0.8}
+
+	s, err := NewFileStore(dir, WithDedup(time.Hour, 100))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	s.AddScore(event)
+	if stored, _ := s.AddScore(event); stored {
+		t.Error("Expected duplicate to be ignored")
+	}
+	crash(t, s)
+
+	reopened, err := NewFileStore(dir, WithDedup(time.Hour, 100))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	// The duplicate was never logged, and the recovered score is remembered
+	if stats := reopened.Stats(); stats.Scores != 1 {
+		t.Errorf("Expected 1 recovered score, got %d", stats.Scores)
+	}
+	if stored, _ := reopened.AddScore(event); stored {
+		t.Error("Expected redelivery after reopening to be ignored")
+	}
+}
@@ -0,0 +1,552 @@
+package store
+
+import (
+	"bufio"
+	"channel-test/pkg/models"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	logFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+	snapshotVersion  = 1
+
+	// recordHeaderSize is the length and CRC32 prefix of each log record
+	recordHeaderSize = 8
+	// maxRecordSize guards against allocating garbage lengths from a corrupt log
+	maxRecordSize = 1 << 20
+
+	defaultCompactEvery = 10000
+)
+
+var (
+	// ErrStoreClosed is returned when writing to a closed FileStore
+	ErrStoreClosed = errors.New("store closed")
+
+	errCorruptRecord = errors.New("corrupt log record")
+)
+
+// logRecord is a single score as written to the write-ahead log and snapshot
+type logRecord struct {
+	Seq       uint64    `json:"seq,omitempty"`
+	Exam      int       `json:"exam"`
+	StudentID string    `json:"studentId"`
+	Score     float64   `json:"score"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source,omitempty"`
+	EventID   string    `json:"eventId,omitempty"`
+}
+
+// snapshot is the compacted state of the store
+type snapshot struct {
+	Version int         `json:"version"`
+	LastSeq uint64      `json:"lastSeq"`
+	Records []logRecord `json:"records"`
+}
+
+// FileStore is a Store that keeps its data in memory and persists every
+// AddScore to a write-ahead log in dir. The log is periodically compacted
+// into a snapshot, and both are replayed when the store is opened.
+type FileStore struct {
+	*MemoryStore
+
+	mu           sync.Mutex // serializes log writes
+	dir          string
+	log          *os.File
+	offset       int64  // end of the last complete record in the log
+	seq          uint64 // sequence number of the last logged record
+	pending      int    // records logged since the last compaction
+	compactEvery int
+	syncWrites   bool
+	logger       *slog.Logger
+	writeErr     error // last failed log write, cleared by the next success
+
+	// compactMu serializes compactions, and is taken before mu.
+	// compacting is set, under mu, while one runs in the background.
+	compactMu   sync.Mutex
+	compacting  bool
+	compactions sync.WaitGroup
+}
+
+// NewFileStore opens the file store in dir, creating it if needed, and
+// rebuilds its state from the snapshot and write-ahead log. A log that
+// ends in a partially written record is truncated to the last complete one.
+func NewFileStore(dir string, opts ...Option) (*FileStore, error) {
+	o := newOptions(opts)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	s := &FileStore{
+		MemoryStore:  NewMemoryStore(opts...),
+		dir:          dir,
+		compactEvery: o.compactEvery,
+		syncWrites:   o.syncWrites,
+		logger:       o.logger,
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	s.log = f
+
+	if err := s.replayLog(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// AddScore appends the event to the write-ahead log before applying it.
+// Duplicates are ignored without being logged.
+func (s *FileStore) AddScore(event models.ScoreEvent) (bool, error) {
+	stored, added, err := s.addScore(event)
+	if added {
+		// Hooks run once s.mu is released, so a slow hook doesn't hold up
+		// other writers and a hook may call back into the store
+		s.MemoryStore.publish(stored)
+	}
+	return added, err
+}
+
+// addScore logs and applies event, returning the record stored
+func (s *FileStore) addScore(event models.ScoreEvent) (models.ScoreRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return models.ScoreRecord{}, false, ErrStoreClosed
+	}
+
+	// s.mu keeps other writers out between this check and the add below
+	received := newScoreRecord(event, time.Now())
+	s.MemoryStore.mu.Lock()
+	duplicate := s.MemoryStore.duplicate(received)
+	s.MemoryStore.mu.Unlock()
+	if duplicate {
+		return models.ScoreRecord{}, false, nil
+	}
+
+	record := newLogRecord(received)
+	record.Seq = s.seq + 1
+	if err := s.appendRecord(record); err != nil {
+		s.writeErr = err
+		return models.ScoreRecord{}, false, err
+	}
+	s.writeErr = nil
+	s.seq = record.Seq
+
+	stored := record.scoreRecord()
+	s.MemoryStore.mu.Lock()
+	s.MemoryStore.add(stored)
+	s.MemoryStore.mu.Unlock()
+
+	s.pending++
+	if s.compactEvery > 0 && s.pending >= s.compactEvery && !s.compacting {
+		s.compacting = true
+		s.compactions.Add(1)
+		go s.compactInBackground()
+	}
+
+	return stored, true, nil
+}
+
+// compactInBackground compacts until fewer than compactEvery records
+// are left in the log, as writes may keep arriving while it runs
+func (s *FileStore) compactInBackground() {
+	defer s.compactions.Done()
+
+	for {
+		s.compactMu.Lock()
+		err := s.compact()
+		s.compactMu.Unlock()
+
+		s.mu.Lock()
+		again := err == nil && s.pending >= s.compactEvery
+		s.compacting = again
+		s.mu.Unlock()
+
+		if err != nil && !errors.Is(err, ErrStoreClosed) {
+			s.logger.Error("Failed to compact store log", "error", err)
+		}
+		if !again {
+			return
+		}
+	}
+}
+
+// Check implements HealthChecker. It fails once the store is closed or
+// while the most recent write to the log has failed.
+func (s *FileStore) Check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return ErrStoreClosed
+	}
+	return s.writeErr
+}
+
+// Restore writes a snapshot of the restored state and then loads it, so
+// the store reopens with the state it serves. If the snapshot can't be
+// written, the store keeps its previous state. A failure to drop the
+// restored-over records from the log is reported by Check until the next
+// successful write; they are skipped on replay either way.
+func (s *FileStore) Restore(snap *Snapshot, mode RestoreMode) (RestoreResult, error) {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return RestoreResult{}, ErrStoreClosed
+	}
+
+	s.MemoryStore.mu.RLock()
+	records, result, err := s.MemoryStore.mergeRestore(snap.Records, mode)
+	s.MemoryStore.mu.RUnlock()
+	if err != nil {
+		return RestoreResult{}, err
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFileName), newSnapshot(s.seq, records)); err != nil {
+		return RestoreResult{}, err
+	}
+
+	s.MemoryStore.mu.Lock()
+	s.MemoryStore.load(records, mode)
+	s.MemoryStore.mu.Unlock()
+
+	if err := s.dropLogPrefix(s.offset, s.pending); err != nil {
+		s.writeErr = err
+		return result, err
+	}
+	return result, nil
+}
+
+// Compact writes a snapshot of the current state and drops the records
+// it holds from the log. Writes continue while the snapshot is written.
+func (s *FileStore) Compact() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	return s.compact()
+}
+
+// Close compacts the log and closes the store. Further writes fail
+// with ErrStoreClosed.
+func (s *FileStore) Close() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+
+	compactErr := s.compactLocked()
+	closeErr := s.log.Close()
+	s.log = nil
+
+	if compactErr != nil {
+		return compactErr
+	}
+	return closeErr
+}
+
+// appendRecord writes one length-prefixed, checksummed record to the log.
+// A failed write is rolled back so the log never holds a torn record
+// ahead of later ones.
+func (s *FileStore) appendRecord(record logRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode log record: %w", err)
+	}
+
+	buf := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[recordHeaderSize:], payload)
+
+	if _, err := s.log.WriteAt(buf, s.offset); err != nil {
+		s.log.Truncate(s.offset)
+		return fmt.Errorf("failed to write log record: %w", err)
+	}
+	if s.syncWrites {
+		if err := s.log.Sync(); err != nil {
+			s.log.Truncate(s.offset)
+			return fmt.Errorf("failed to sync log: %w", err)
+		}
+	}
+
+	s.offset += int64(len(buf))
+	return nil
+}
+
+// replayLog applies every complete record in the log that is newer than
+// the snapshot and truncates anything after the last complete record
+func (s *FileStore) replayLog() error {
+	reader := bufio.NewReader(s.log)
+	var offset int64
+	var replayed int
+
+	for {
+		record, n, err := readRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.logger.Warn("Discarding store log after corrupt record", "offset", offset, "error", err)
+			if err := s.log.Truncate(offset); err != nil {
+				return fmt.Errorf("failed to truncate log: %w", err)
+			}
+			break
+		}
+		offset += n
+
+		if record.Seq <= s.seq {
+			continue
+		}
+		s.MemoryStore.add(record.scoreRecord())
+		s.seq = record.Seq
+		replayed++
+	}
+
+	s.offset = offset
+	s.pending = replayed
+	return nil
+}
+
+// readRecord reads one record from r and returns it with its encoded
+// size. It returns io.EOF at a clean end of log.
+func readRecord(r io.Reader) (logRecord, int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return logRecord{}, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return logRecord{}, 0, fmt.Errorf("%w: record size %d", errCorruptRecord, size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return logRecord{}, 0, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return logRecord{}, 0, fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+	}
+
+	var record logRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return logRecord{}, 0, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+
+	return record, int64(recordHeaderSize) + int64(size), nil
+}
+
+// loadSnapshot applies the snapshot file, if one exists
+func (s *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	for _, record := range snap.Records {
+		s.MemoryStore.add(record.scoreRecord())
+	}
+	s.seq = snap.LastSeq
+	return nil
+}
+
+// compact atomically replaces the snapshot with the current state and
+// drops the records it holds from the log. Only copying the state and
+// rewriting the log hold s.mu, so writes don't wait for the snapshot to be
+// written. The caller must hold s.compactMu but not s.mu.
+func (s *FileStore) compact() error {
+	s.mu.Lock()
+	if s.log == nil {
+		s.mu.Unlock()
+		return ErrStoreClosed
+	}
+	snap, covered, pending := s.captureSnapshot()
+	s.mu.Unlock()
+
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFileName), snap); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return ErrStoreClosed
+	}
+	return s.dropLogPrefix(covered, pending)
+}
+
+// compactLocked is compact for callers that must keep writes out until it
+// is done. The caller must hold s.compactMu and s.mu.
+func (s *FileStore) compactLocked() error {
+	snap, covered, pending := s.captureSnapshot()
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFileName), snap); err != nil {
+		return err
+	}
+	return s.dropLogPrefix(covered, pending)
+}
+
+// captureSnapshot copies the current state, returning it with the length
+// of the log and the number of records in it that the state covers. The
+// caller must hold s.mu.
+func (s *FileStore) captureSnapshot() (snapshot, int64, int) {
+	return newSnapshot(s.seq, s.MemoryStore.Snapshot().Records), s.offset, s.pending
+}
+
+// newSnapshot returns the snapshot of records covering the log up to
+// lastSeq
+func newSnapshot(lastSeq uint64, records []models.ScoreRecord) snapshot {
+	snap := snapshot{
+		Version: snapshotVersion,
+		LastSeq: lastSeq,
+		Records: make([]logRecord, len(records)),
+	}
+	for i, record := range records {
+		snap.Records[i] = newLogRecord(record)
+	}
+	return snap
+}
+
+// dropLogPrefix removes the first covered bytes, holding pending records,
+// from the log now that the snapshot holds them, keeping any records
+// logged since. The caller must hold s.mu.
+func (s *FileStore) dropLogPrefix(covered int64, pending int) error {
+	// Records up to LastSeq are now in the snapshot; if we crash before
+	// the log is rewritten they are skipped on replay
+	if covered == s.offset {
+		if err := s.log.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate log: %w", err)
+		}
+		s.offset = 0
+		s.pending = 0
+		return nil
+	}
+
+	tail := make([]byte, s.offset-covered)
+	if _, err := s.log.ReadAt(tail, covered); err != nil {
+		return fmt.Errorf("failed to read log: %w", err)
+	}
+	path := filepath.Join(s.dir, logFileName)
+	err := writeAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(tail)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen log: %w", err)
+	}
+	s.log.Close()
+	s.log = f
+	s.offset = int64(len(tail))
+	s.pending -= pending
+	return nil
+}
+
+// writeFileAtomic writes v as JSON to a temporary file and renames it over path
+func writeFileAtomic(path string, v interface{}) error {
+	return writeAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
+
+// writeAtomic writes a temporary file with write, syncs it and renames it
+// over path
+func writeAtomic(path string, write func(w io.Writer) error) error {
+	name := filepath.Base(path)
+	tmp, err := os.CreateTemp(filepath.Dir(path), name+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	if err := write(writer); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", name, err)
+	}
+	return nil
+}
+
+func newLogRecord(record models.ScoreRecord) logRecord {
+	return logRecord{
+		Exam:      record.Exam,
+		StudentID: record.StudentID,
+		Score:     record.Score,
+		Timestamp: record.ReceivedAt,
+		Source:    record.Source,
+		EventID:   record.EventID,
+	}
+}
+
+// scoreRecord converts a log record back into the history entry it came from
+func (r logRecord) scoreRecord() models.ScoreRecord {
+	return models.ScoreRecord{
+		Exam:       r.Exam,
+		StudentID:  r.StudentID,
+		Score:      r.Score,
+		ReceivedAt: r.Timestamp,
+		Source:     r.Source,
+		EventID:    r.EventID,
+	}
+}
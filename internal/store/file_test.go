@@ -0,0 +1,389 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// crash closes the store's log without compacting, as if the process died
+func crash(t *testing.T, s *FileStore) {
+	t.Helper()
+	s.compactions.Wait()
+	if err := s.log.Close(); err != nil {
+		t.Fatalf("Failed to close log: %v", err)
+	}
+	s.log = nil
+}
+
+func addStudents(t *testing.T, s Store, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		event := models.ScoreEvent{Exam: 1, StudentID: fmt.Sprintf("student%d", i), Score: 0.5}
+		if _, err := s.AddScore(event); err != nil {
+			t.Fatalf("AddScore failed: %v", err)
+		}
+	}
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "alice", Score: 0.8})
+	s.AddScore(models.ScoreEvent{Exam: 2, StudentID: "alice", Score: 0.9})
+	s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "bob", Score: 0.7})
+	before, _ := s.GetStudent("alice")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if _, err := s.AddScore(models.ScoreEvent{Exam: 3, StudentID: "alice", Score: 1}); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if students := reopened.GetAllStudents(); len(students) != 2 {
+		t.Errorf("Expected 2 students, got %d", len(students))
+	}
+
+	after, err := reopened.GetStudent("alice")
+	if err != nil {
+		t.Fatalf("GetStudent failed: %v", err)
+	}
+	if len(after.Scores) != 2 {
+		t.Fatalf("Expected 2 scores, got %d", len(after.Scores))
+	}
+	for i := range after.Scores {
+		if after.Scores[i].Score != before.Scores[i].Score {
+			t.Errorf("Expected score %.2f, got %.2f", before.Scores[i].Score, after.Scores[i].Score)
+		}
+		if !after.Scores[i].Timestamp.Equal(before.Scores[i].Timestamp) {
+			t.Errorf("Expected timestamp %v, got %v", before.Scores[i].Timestamp, after.Scores[i].Timestamp)
+		}
+	}
+}
+
+func TestFileStore_RecoversFromTruncatedLog(t *testing.T) {
+	tests := []struct {
+		name string
+		keep func(recordSize int64) int64 // bytes of the last record left on disk
+	}{
+		{"mid-header", func(int64) int64 { return recordHeaderSize - 3 }},
+		{"mid-payload", func(int64) int64 { return recordHeaderSize + 5 }},
+		{"last byte missing", func(size int64) int64 { return size - 1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			logPath := filepath.Join(dir, logFileName)
+
+			s, err := NewFileStore(dir, WithCompactEvery(0))
+			if err != nil {
+				t.Fatalf("NewFileStore failed: %v", err)
+			}
+			addStudents(t, s, 2)
+			info, _ := os.Stat(logPath)
+			complete := info.Size()
+			s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "torn", Score: 0.5})
+			crash(t, s)
+
+			info, _ = os.Stat(logPath)
+			if err := os.Truncate(logPath, complete+tt.keep(info.Size()-complete)); err != nil {
+				t.Fatalf("Truncate failed: %v", err)
+			}
+
+			recovered, err := NewFileStore(dir, WithCompactEvery(0))
+			if err != nil {
+				t.Fatalf("NewFileStore failed: %v", err)
+			}
+			if students := recovered.GetAllStudents(); len(students) != 2 {
+				t.Fatalf("Expected 2 students after recovery, got %d", len(students))
+			}
+
+			// The torn record must be gone so new writes replay cleanly
+			recovered.AddScore(models.ScoreEvent{Exam: 1, StudentID: "late", Score: 1})
+			crash(t, recovered)
+
+			reopened, err := NewFileStore(dir, WithCompactEvery(0))
+			if err != nil {
+				t.Fatalf("NewFileStore failed: %v", err)
+			}
+			defer reopened.Close()
+
+			if students := reopened.GetAllStudents(); len(students) != 3 {
+				t.Errorf("Expected 3 students after reopen, got %d", len(students))
+			}
+			if _, err := reopened.GetStudent("late"); err != nil {
+				t.Errorf("Expected record written after recovery, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStore_DiscardsCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, logFileName)
+
+	s, err := NewFileStore(dir, WithCompactEvery(0))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	addStudents(t, s, 3)
+	crash(t, s)
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[len(data)-2] ^= 0xFF
+	if err := os.WriteFile(logPath, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	recovered, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer recovered.Close()
+
+	if students := recovered.GetAllStudents(); len(students) != 2 {
+		t.Errorf("Expected 2 students after recovery, got %d", len(students))
+	}
+}
+
+func TestFileStore_Compaction(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, WithCompactEvery(2))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	addStudents(t, s, 5)
+	s.compactions.Wait()
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("Expected snapshot file: %v", err)
+	}
+	if s.pending >= 2 {
+		t.Errorf("Expected under 2 records in the log after compaction, got %d", s.pending)
+	}
+	crash(t, s)
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if students := reopened.GetAllStudents(); len(students) != 5 {
+		t.Errorf("Expected 5 students, got %d", len(students))
+	}
+}
+
+// Records logged while the snapshot is being written stay in the log
+func TestFileStore_CompactionKeepsNewRecords(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, WithCompactEvery(0))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	addStudents(t, s, 3)
+
+	s.mu.Lock()
+	snap, covered, pending := s.captureSnapshot()
+	s.mu.Unlock()
+
+	for _, id := range []string{"late1", "late2"} {
+		if _, err := s.AddScore(models.ScoreEvent{Exam: 1, StudentID: id, Score: 0.5}); err != nil {
+			t.Fatalf("AddScore failed: %v", err)
+		}
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, snapshotFileName), snap); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	s.mu.Lock()
+	err = s.dropLogPrefix(covered, pending)
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatalf("dropLogPrefix failed: %v", err)
+	}
+	if s.pending != 2 {
+		t.Errorf("Expected 2 records in the log, got %d", s.pending)
+	}
+
+	// The rewritten log takes further writes
+	if _, err := s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "late3", Score: 0.5}); err != nil {
+		t.Fatalf("AddScore failed: %v", err)
+	}
+	crash(t, s)
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if students := reopened.GetAllStudents(); len(students) != 6 {
+		t.Errorf("Expected 6 students, got %d", len(students))
+	}
+}
+
+func TestFileStore_CrashDuringCompaction(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, logFileName)
+
+	s, err := NewFileStore(dir, WithCompactEvery(0))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	addStudents(t, s, 3)
+
+	// Simulate a crash after the snapshot was written but before the
+	// log was truncated
+	logData, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	crash(t, s)
+	if err := os.WriteFile(logPath, logData, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if students := reopened.GetAllStudents(); len(students) != 3 {
+		t.Errorf("Expected 3 students, got %d", len(students))
+	}
+	if reopened.pending != 0 {
+		t.Errorf("Expected snapshotted records to be skipped, replayed %d", reopened.pending)
+	}
+	if history, _ := reopened.GetScoreHistory("student0", 1); len(history) != 1 {
+		t.Errorf("Expected 1 history entry, got %d", len(history))
+	}
+}
+
+func TestFileStore_KeepsHistory(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFileStore(dir, WithCompactEvery(2), WithScorePolicy(PolicyBest))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	for _, score := range []float64{0.4, 0.9, 0.6} {
+		s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "alice", Score: score, Source: "sse"})
+	}
+	crash(t, s)
+
+	reopened, err := NewFileStore(dir, WithScorePolicy(PolicyBest))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	history, err := reopened.GetScoreHistory("alice", 1)
+	if err != nil {
+		t.Fatalf("GetScoreHistory failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 history entries, got %d", len(history))
+	}
+	for i, expected := range []float64{0.4, 0.9, 0.6} {
+		if history[i].Score != expected || history[i].Source != "sse" {
+			t.Errorf("Entry %d: expected %.1f from sse, got %.1f from %s", i, expected, history[i].Score, history[i].Source)
+		}
+	}
+
+	student, _ := reopened.GetStudent("alice")
+	if student.Scores[0].Score != 0.9 {
+		t.Errorf("Expected best score 0.9, got %.1f", student.Scores[0].Score)
+	}
+}
+
+func TestFileStore_HooksRunUnlocked(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer s.Close()
+
+	// Check takes the lock AddScore holds while logging
+	checked := make(chan error, 1)
+	s.OnScore(func(models.ScoreRecord) {
+		checked <- s.Check()
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.AddScore(models.ScoreEvent{Exam: 1, StudentID: "alice", Score: 0.9})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AddScore failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected AddScore to release the store before running hooks")
+	}
+	if err := <-checked; err != nil {
+		t.Errorf("Expected Check to pass in a hook, got %v", err)
+	}
+}
+
+func TestFileStore_RestoreKeepsStateWhenSnapshotFails(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	addStudents(t, s, 3)
+
+	// A non-empty directory in the snapshot's place can't be renamed over
+	if err := os.MkdirAll(filepath.Join(dir, snapshotFileName, "blocked"), 0o755); err != nil {
+		t.Fatalf("Failed to block snapshot: %v", err)
+	}
+
+	if _, err := s.Restore(&Snapshot{Records: snapshotRecords()}, RestoreReplace); err == nil {
+		t.Fatal("Expected Restore to fail")
+	}
+	if got := len(s.GetAllStudents()); got != 3 {
+		t.Errorf("Expected the 3 students from before the failed restore, got %d", got)
+	}
+
+	crash(t, s)
+	if err := os.RemoveAll(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("Failed to unblock snapshot: %v", err)
+	}
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if got := len(reopened.GetAllStudents()); got != 3 {
+		t.Errorf("Expected the reopened store to hold 3 students, got %d", got)
+	}
+}
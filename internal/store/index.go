@@ -0,0 +1,104 @@
+package store
+
+import (
+	"slices"
+	"sort"
+)
+
+// examScores indexes one exam: the attempts of every student who took it,
+// their effective scores ranked, and running sums so the average and
+// statistics can be read without a full pass. The histories are shared
+// with MemoryStore.scores, so both indexes see every attempt.
+type examScores struct {
+	students map[string]*examHistory // studentID -> attempts
+	byID     []examStudent           // the same, in ascending ID order
+	ranked   rankIndex
+	sum      float64
+	// m2 is the sum of squared differences from the mean, kept with
+	// Welford's method so the variance doesn't lose precision the way
+	// subtracting the squared mean from the mean square does
+	m2 float64
+}
+
+// examStudent is one student's attempts on an exam
+type examStudent struct {
+	id      string
+	history *examHistory
+}
+
+func newExamScores() *examScores {
+	return &examScores{students: make(map[string]*examHistory)}
+}
+
+// add indexes a student's first attempt on the exam
+func (e *examScores) add(studentID string, history *examHistory, score float64) {
+	e.students[studentID] = history
+	i := sort.Search(len(e.byID), func(i int) bool { return e.byID[i].id >= studentID })
+	e.byID = slices.Insert(e.byID, i, examStudent{id: studentID, history: history})
+	e.insert(score, studentID)
+}
+
+// insert adds a student's score
+func (e *examScores) insert(score float64, studentID string) {
+	mean := 0.0
+	if e.len() > 0 {
+		mean = e.average()
+	}
+	e.ranked.insert(score, studentID)
+	e.sum += score
+	e.m2 += (score - mean) * (score - e.average())
+}
+
+// replace moves a student's score from old to score
+func (e *examScores) replace(old, score float64, studentID string) {
+	if old == score {
+		return
+	}
+	mean := e.average()
+	e.ranked.remove(old, studentID)
+	e.ranked.insert(score, studentID)
+	e.sum += score - old
+
+	// With the count unchanged, M2 moves by (score-old) times the sum of
+	// each score's distance from its mean
+	e.m2 += (score - old) * (score - e.average() + old - mean)
+}
+
+// len returns the number of students who took the exam
+func (e *examScores) len() int {
+	return e.ranked.len()
+}
+
+// average returns the mean effective score
+func (e *examScores) average() float64 {
+	return e.sum / float64(e.len())
+}
+
+// scoreMoments are the count, sum and M2 of a group of scores
+type scoreMoments struct {
+	count int
+	sum   float64
+	m2    float64
+}
+
+func (e *examScores) moments() scoreMoments {
+	return scoreMoments{count: e.len(), sum: e.sum, m2: e.m2}
+}
+
+// merge returns the moments of both groups of scores taken together, using
+// Chan et al.'s parallel combination of M2
+func (m scoreMoments) merge(other scoreMoments) scoreMoments {
+	if m.count == 0 {
+		return other
+	}
+	if other.count == 0 {
+		return m
+	}
+	n, k := float64(m.count), float64(other.count)
+	delta := other.sum/k - m.sum/n
+	return scoreMoments{
+		count: m.count + other.count,
+		sum:   m.sum + other.sum,
+		m2:    m.m2 + other.m2 + delta*delta*n*k/(n+k),
+	}
+}
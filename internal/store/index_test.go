@@ -0,0 +1,155 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"testing"
+	"testing/quick"
+)
+
+// scoreOp is one generated AddScore. Students, exams and scores are drawn
+// from small ranges so students retake exams and scores tie often. Scores
+// are eighths, which add up exactly in any order.
+type scoreOp struct {
+	Student uint8
+	Exam    uint8
+	Score   uint8
+}
+
+func (op scoreOp) event() models.ScoreEvent {
+	return models.ScoreEvent{
+		StudentID: string(rune('a' + op.Student%8)),
+		Exam:      1 + int(op.Exam%5),
+		Score:     float64(op.Score%9) / 8,
+	}
+}
+
+// naiveExam builds an exam by scanning every student, as GetExam did
+// before the exam index
+func naiveExam(s *MemoryStore, number int) (*models.Exam, bool) {
+	exam := &models.Exam{Number: number, Results: []models.ExamResult{}}
+	var total float64
+	for studentID, exams := range s.scores {
+		if history, took := exams[number]; took {
+			score := history.score(s.policy)
+			exam.Results = append(exam.Results, models.ExamResult{StudentID: studentID, Score: score})
+			total += score
+		}
+	}
+	if len(exam.Results) == 0 {
+		return nil, false
+	}
+	sort.Slice(exam.Results, func(i, j int) bool {
+		return exam.Results[i].StudentID < exam.Results[j].StudentID
+	})
+	exam.AverageScore = total / float64(len(exam.Results))
+	return exam, true
+}
+
+// naiveExams lists every exam any student took, by scanning every student
+func naiveExams(s *MemoryStore) []int {
+	seen := make(map[int]bool)
+	for _, exams := range s.scores {
+		for number := range exams {
+			seen[number] = true
+		}
+	}
+	numbers := make([]int, 0, len(seen))
+	for number := range seen {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// checkIndexes reports how s's exam index disagrees with a scan of its
+// student map, or "" if they agree
+func checkIndexes(s *MemoryStore) string {
+	numbers := s.GetAllExams()
+	if !slices.Equal(numbers, naiveExams(s)) {
+		return "GetAllExams differs from scan"
+	}
+
+	// Both indexes share each history
+	for studentID, exams := range s.scores {
+		for number, history := range exams {
+			if s.exams[number].students[studentID] != history {
+				return "exam index does not share history of " + studentID
+			}
+		}
+	}
+
+	page, _ := s.QueryExams(ExamQuery{})
+	if page.Total != len(numbers) {
+		return "QueryExams total differs from scan"
+	}
+	for _, summary := range page.Exams {
+		expected, _ := naiveExam(s, summary.Number)
+		if summary.StudentCount != len(expected.Results) || !closeTo(summary.AverageScore, expected.AverageScore) {
+			return "QueryExams summary differs from scan"
+		}
+	}
+
+	for _, number := range append(numbers, 99) {
+		exam, err := s.GetExam(number)
+		expected, exists := naiveExam(s, number)
+		if !exists {
+			if !errors.Is(err, ErrExamNotFound) {
+				return "GetExam found an exam the scan did not"
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(exam.Results, expected.Results) || !closeTo(exam.AverageScore, expected.AverageScore) {
+			return "GetExam differs from scan"
+		}
+
+		stats, err := s.GetExamStats(number, ExamStatsQuery{})
+		if err != nil || stats.Count != len(expected.Results) || !closeTo(stats.Mean, expected.AverageScore) {
+			return "GetExamStats differs from scan"
+		}
+	}
+
+	for studentID := range s.scores {
+		page, _ := s.QueryExams(ExamQuery{Student: studentID})
+		if page.Total != len(s.scores[studentID]) {
+			return "QueryExams for " + studentID + " differs from scan"
+		}
+	}
+	return ""
+}
+
+func TestMemoryStore_ExamIndexMatchesScan(t *testing.T) {
+	policies := []ScorePolicy{PolicyLatest, PolicyBest, PolicyFirst, PolicyAverage}
+
+	property := func(ops []scoreOp, policy uint8) bool {
+		s := NewMemoryStore(WithScorePolicy(policies[int(policy)%len(policies)]))
+		for i, op := range ops {
+			s.AddScore(op.event())
+
+			// Check along the way too, so an update that is later undone
+			// by another can't hide
+			if i%7 == 0 {
+				if problem := checkIndexes(s); problem != "" {
+					t.Logf("after %d of %v: %s", i+1, ops, problem)
+					return false
+				}
+			}
+		}
+		if problem := checkIndexes(s); problem != "" {
+			t.Logf("after %v: %s", ops, problem)
+			return false
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
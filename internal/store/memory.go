@@ -1,9 +1,13 @@
 package store
 
 import (
+	"channel-test/internal/validation"
 	"channel-test/pkg/models"
 	"errors"
+	"fmt"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,32 +23,279 @@ var (
 // MemoryStore implements the Store interface using in-memory storage
 type MemoryStore struct {
 	mu     sync.RWMutex
-	scores map[string]map[int]models.StudentScore // studentID -> examNumber -> score
+	scores map[string]map[int]*examHistory // studentID -> examNumber -> attempts
+	policy ScorePolicy
+	hooks  []ScoreHook
+	count  int // history entries across all students and exams
+
+	// exams indexes the same attempts by exam, and numbers holds the exam
+	// numbers in ascending order
+	exams   map[int]*examScores // examNumber -> studentID -> attempts
+	numbers []int
+
+	// dedup remembers recent scores when deduplication is on, and
+	// duplicates counts the scores it caused to be ignored
+	dedup      *dedupIndex
+	duplicates int
+
+	// restoreRules checks records restored from a snapshot
+	restoreRules validation.Validator
+
+	// averages ranks students by overall average; average holds each
+	// student's current key in it and total the sum of their exam scores
+	averages rankIndex
+	average  map[string]float64
+	total    map[string]float64
+
+	// studentOrders and examOrders keep students and exams in the order
+	// of each field they can be sorted by, so query pages are read from
+	// them instead of sorting every summary
+	studentOrders map[StudentSort]*rankIndex
+	examOrders    map[ExamSort]*rankIndex
 }
 
 // NewMemoryStore creates a new in-memory store
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
-		scores: make(map[string]map[int]models.StudentScore),
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	o := newOptions(opts)
+
+	s := &MemoryStore{
+		scores:  make(map[string]map[int]*examHistory),
+		exams:   make(map[int]*examScores),
+		policy:  o.policy,
+		average: make(map[string]float64),
+		total:   make(map[string]float64),
+
+		studentOrders: newStudentOrders(),
+		examOrders:    newExamOrders(),
+
+		restoreRules: o.restoreRules,
+	}
+	if o.dedupWindow > 0 {
+		s.dedup = newDedupIndex(o.dedupWindow, o.dedupSize, o.dedupContent)
+	}
+	return s
+}
+
+// AddScore adds a new score event to the store, unless deduplication is
+// on and the event was already received
+func (s *MemoryStore) AddScore(event models.ScoreEvent) (bool, error) {
+	record := newScoreRecord(event, time.Now())
+
+	s.mu.Lock()
+	if s.duplicate(record) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.add(record)
+	s.mu.Unlock()
+
+	s.publish(record)
+	return true, nil
+}
+
+// newScoreRecord is the history entry for an event, received at its own
+// ReceivedAt when set and at now otherwise
+func newScoreRecord(event models.ScoreEvent, now time.Time) models.ScoreRecord {
+	receivedAt := event.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = now
+	}
+	return models.ScoreRecord{
+		Exam:       event.Exam,
+		StudentID:  event.StudentID,
+		Score:      event.Score,
+		ReceivedAt: receivedAt,
+		Source:     event.Source,
+		EventID:    event.ID,
+	}
+}
+
+// duplicate reports whether record repeats a recently received score,
+// counting it if so. The caller must hold s.mu.
+func (s *MemoryStore) duplicate(record models.ScoreRecord) bool {
+	if s.dedup == nil {
+		return false
+	}
+	key, ok := s.dedup.key(record)
+	if !ok || !s.dedup.contains(key, record.ReceivedAt) {
+		return false
+	}
+	s.duplicates++
+	return true
+}
+
+// OnScore registers a hook that runs after each AddScore
+func (s *MemoryStore) OnScore(hook ScoreHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hooks = append(s.hooks, hook)
+}
+
+// publish runs the registered hooks for record. It must be called
+// without holding s.mu so hooks may read from the store.
+func (s *MemoryStore) publish(record models.ScoreRecord) {
+	s.mu.RLock()
+	hooks := s.hooks
+	s.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(record)
+	}
+}
+
+// add appends record to the student's history and updates the exam index
+// and overall rankings. The caller must hold s.mu.
+func (s *MemoryStore) add(record models.ScoreRecord) {
+	student, hadStudent := s.studentSummary(record.StudentID)
+	exam, hadExam := s.examSummary(record.Exam)
+
+	exams := s.scores[record.StudentID]
+	if exams == nil {
+		exams = make(map[int]*examHistory)
+		s.scores[record.StudentID] = exams
+	}
+
+	index := s.exams[record.Exam]
+	if index == nil {
+		index = newExamScores()
+		s.exams[record.Exam] = index
+		i := sort.SearchInts(s.numbers, record.Exam)
+		s.numbers = slices.Insert(s.numbers, i, record.Exam)
+	}
+
+	history := exams[record.Exam]
+	if history == nil {
+		history = &examHistory{}
+		exams[record.Exam] = history
+		history.add(record)
+		score := history.score(s.policy)
+		index.add(record.StudentID, history, score)
+		s.updateAverage(record.StudentID, score, len(exams))
+	} else {
+		old := history.score(s.policy)
+		history.add(record)
+		score := history.score(s.policy)
+		index.replace(old, score, record.StudentID)
+		s.updateAverage(record.StudentID, score-old, len(exams))
+	}
+
+	current, _ := s.studentSummary(record.StudentID)
+	for sortBy, order := range s.studentOrders {
+		position := studentPosition(sortBy)
+		reorder(order, position(student), hadStudent, position(current))
+	}
+	currentExam, _ := s.examSummary(record.Exam)
+	for sortBy, order := range s.examOrders {
+		position := examPosition(sortBy)
+		reorder(order, position(exam), hadExam, position(currentExam))
+	}
+
+	s.count++
+
+	if s.dedup != nil {
+		s.dedup.remember(record)
+	}
+}
+
+// updateAverage adds change to a student's total over their exams and
+// moves them in the overall ranking by their new average. The caller must
+// hold s.mu.
+func (s *MemoryStore) updateAverage(studentID string, change float64, examCount int) {
+	total := s.total[studentID] + change
+	s.total[studentID] = total
+	average := total / float64(examCount)
+
+	if old, ranked := s.average[studentID]; ranked {
+		s.averages.remove(old, studentID)
 	}
+	s.averages.insert(average, studentID)
+	s.average[studentID] = average
 }
 
-// AddScore adds a new score event to the store
-func (s *MemoryStore) AddScore(event models.ScoreEvent) error {
+// Snapshot returns every score received, in the order it was received
+func (s *MemoryStore) Snapshot() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &Snapshot{CreatedAt: time.Now(), Records: s.records()}
+}
+
+// Restore loads snap's scores, rebuilding the store's indexes from them.
+// Deduplication remembers the restored scores as received at their
+// original times.
+func (s *MemoryStore) Restore(snap *Snapshot, mode RestoreMode) (RestoreResult, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.scores[event.StudentID] == nil {
-		s.scores[event.StudentID] = make(map[int]models.StudentScore)
+	return s.restore(snap.Records, mode)
+}
+
+// restore rebuilds the store from its records merged with incoming in
+// mode. The caller must hold s.mu.
+func (s *MemoryStore) restore(incoming []models.ScoreRecord, mode RestoreMode) (RestoreResult, error) {
+	records, result, err := s.mergeRestore(incoming, mode)
+	if err != nil {
+		return RestoreResult{}, err
 	}
+	s.load(records, mode)
+	return result, nil
+}
 
-	s.scores[event.StudentID][event.Exam] = models.StudentScore{
-		Exam:      event.Exam,
-		Score:     event.Score,
-		Timestamp: time.Now(),
+// mergeRestore checks incoming and merges it with the store's records in
+// mode, returning every record the store holds once restored. It changes
+// nothing, so the caller only needs to hold s.mu for reading.
+func (s *MemoryStore) mergeRestore(incoming []models.ScoreRecord, mode RestoreMode) ([]models.ScoreRecord, RestoreResult, error) {
+	if !mode.valid() {
+		return nil, RestoreResult{}, fmt.Errorf("%w %q", ErrUnknownRestoreMode, mode)
+	}
+	if err := checkRecords(incoming, s.restoreRules); err != nil {
+		return nil, RestoreResult{}, err
 	}
 
-	return nil
+	records, restored, skipped := mergeRecords(s.records(), incoming, mode)
+	return records, RestoreResult{Mode: mode, Restored: restored, Skipped: skipped, Scores: len(records)}, nil
+}
+
+// load replaces the store's contents with records, as merged by
+// mergeRestore in mode. The caller must hold s.mu.
+func (s *MemoryStore) load(records []models.ScoreRecord, mode RestoreMode) {
+	s.scores = make(map[string]map[int]*examHistory)
+	s.exams = make(map[int]*examScores)
+	s.numbers = nil
+	s.count = 0
+	s.averages = rankIndex{}
+	s.average = make(map[string]float64)
+	s.total = make(map[string]float64)
+	s.studentOrders = newStudentOrders()
+	s.examOrders = newExamOrders()
+	if s.dedup != nil {
+		s.dedup = newDedupIndex(s.dedup.window, s.dedup.size, s.dedup.content)
+	}
+	if mode == RestoreReplace {
+		s.duplicates = 0
+	}
+
+	for _, record := range records {
+		s.add(record)
+	}
+}
+
+// records returns the full history of every score in the order it was
+// received. The caller must hold s.mu.
+func (s *MemoryStore) records() []models.ScoreRecord {
+	var records []models.ScoreRecord
+	for _, exams := range s.scores {
+		for _, history := range exams {
+			records = append(records, history.attempts...)
+		}
+	}
+
+	// Stable so attempts on the same exam keep their order on timestamp ties
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].ReceivedAt.Before(records[j].ReceivedAt)
+	})
+	return records
 }
 
 // GetAllStudents returns a sorted list of all student IDs
@@ -71,12 +322,25 @@ func (s *MemoryStore) GetStudent(id string) (*models.Student, error) {
 		return nil, ErrStudentNotFound
 	}
 
-	scores := make([]models.StudentScore, 0, len(exams))
-	var totalScore float64
+	examRank := func(number int, score float64) (int, int) {
+		index := s.exams[number]
+		return index.ranked.rank(score, id), index.len()
+	}
+	rank := s.averages.rank(s.average[id], id)
+	return newStudent(id, exams, s.policy, examRank, rank, s.averages.len()), nil
+}
 
-	for _, score := range exams {
+// newStudent summarizes a student's exams. examRank returns the rank of a
+// score on an exam and how many students took it; rank is the student's
+// overall rank among ranked students.
+func newStudent(id string, exams map[int]*examHistory, policy ScorePolicy, examRank func(number int, score float64) (int, int), rank, ranked int) *models.Student {
+	scores := make([]models.StudentScore, 0, len(exams))
+	for number, history := range exams {
+		score := history.studentScore(policy)
+		var took int
+		score.Rank, took = examRank(number, score.Score)
+		score.Percentile = percentileRank(score.Rank, took)
 		scores = append(scores, score)
-		totalScore += score.Score
 	}
 
 	// Sort scores by exam number
@@ -84,6 +348,12 @@ func (s *MemoryStore) GetStudent(id string) (*models.Student, error) {
 		return scores[i].Exam < scores[j].Exam
 	})
 
+	// Sum in exam order so the average does not depend on map order
+	var totalScore float64
+	for _, score := range scores {
+		totalScore += score.Score
+	}
+
 	averageScore := 0.0
 	if len(scores) > 0 {
 		averageScore = totalScore / float64(len(scores))
@@ -93,7 +363,9 @@ func (s *MemoryStore) GetStudent(id string) (*models.Student, error) {
 		ID:           id,
 		Scores:       scores,
 		AverageScore: averageScore,
-	}, nil
+		Rank:         rank,
+		Percentile:   percentileRank(rank, ranked),
+	}
 }
 
 // GetAllExams returns a sorted list of all exam numbers
@@ -101,54 +373,304 @@ func (s *MemoryStore) GetAllExams() []int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	examSet := make(map[int]bool)
-	for _, exams := range s.scores {
-		for examNum := range exams {
-			examSet[examNum] = true
+	exams := make([]int, len(s.numbers))
+	copy(exams, s.numbers)
+	return exams
+}
+
+// GetExam returns detailed information about a specific exam, with
+// results sorted by student ID
+func (s *MemoryStore) GetExam(number int) (*models.Exam, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, exists := s.exams[number]
+	if !exists {
+		return nil, ErrExamNotFound
+	}
+
+	results := make([]models.ExamResult, len(index.byID))
+	for i, student := range index.byID {
+		results[i] = models.ExamResult{
+			StudentID: student.id,
+			Score:     student.history.score(s.policy),
 		}
 	}
 
-	exams := make([]int, 0, len(examSet))
-	for examNum := range examSet {
-		exams = append(exams, examNum)
+	return &models.Exam{
+		Number:       number,
+		Results:      results,
+		AverageScore: index.average(),
+	}, nil
+}
+
+// GetExamStats returns the distribution of scores on an exam, read from
+// the index kept up to date by AddScore
+func (s *MemoryStore) GetExamStats(number int, q ExamStatsQuery) (*models.ExamStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, exists := s.exams[number]
+	if !exists {
+		return nil, ErrExamNotFound
 	}
 
-	sort.Ints(exams)
-	return exams
+	return index.stats(number, q)
 }
 
-// GetExam returns detailed information about a specific exam
-func (s *MemoryStore) GetExam(number int) (*models.Exam, error) {
+// GetExamLeaderboard returns the top students on an exam, highest score
+// first with ties broken by student ID. A top of 0 returns everyone.
+func (s *MemoryStore) GetExamLeaderboard(number int, top int) ([]models.RankedScore, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	results := make([]models.ExamResult, 0)
-	var totalScore float64
+	index, exists := s.exams[number]
+	if !exists {
+		return nil, ErrExamNotFound
+	}
+
+	entries := index.ranked.top(top)
+	leaderboard := make([]models.RankedScore, len(entries))
+	for i, entry := range entries {
+		leaderboard[i] = models.RankedScore{
+			Rank:      i + 1,
+			StudentID: entry.id,
+			Score:     entry.key,
+		}
+	}
+	return leaderboard, nil
+}
+
+// GetLeaderboard returns the top students by overall average, counting
+// only students who took at least minExams exams. Ranks are among those
+// students. A top of 0 returns everyone.
+func (s *MemoryStore) GetLeaderboard(top int, minExams int) []models.RankedStudent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	leaderboard := make([]models.RankedStudent, 0)
+	for entry := range s.averages.all() {
+		if top > 0 && len(leaderboard) == top {
+			break
+		}
 
-	for studentID, exams := range s.scores {
-		if score, exists := exams[number]; exists {
-			results = append(results, models.ExamResult{
-				StudentID: studentID,
-				Score:     score.Score,
-			})
-			totalScore += score.Score
+		examCount := len(s.scores[entry.id])
+		if examCount < minExams {
+			continue
 		}
+
+		leaderboard = append(leaderboard, models.RankedStudent{
+			Rank:         len(leaderboard) + 1,
+			StudentID:    entry.id,
+			AverageScore: entry.key,
+			ExamCount:    examCount,
+		})
+	}
+	return leaderboard
+}
+
+// GetScoreHistory returns every score received for a student on an exam,
+// oldest first
+func (s *MemoryStore) GetScoreHistory(studentID string, number int) ([]models.ScoreRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exams, exists := s.scores[studentID]
+	if !exists {
+		return nil, ErrStudentNotFound
 	}
 
-	if len(results) == 0 {
+	history, exists := exams[number]
+	if !exists {
 		return nil, ErrExamNotFound
 	}
 
-	// Sort results by student ID
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].StudentID < results[j].StudentID
-	})
+	records := make([]models.ScoreRecord, len(history.attempts))
+	copy(records, history.attempts)
+	return records, nil
+}
 
-	averageScore := totalScore / float64(len(results))
+// QueryStudents returns a filtered, sorted page of student summaries
+func (s *MemoryStore) QueryStudents(q StudentQuery) (*StudentPage, error) {
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
 
-	return &models.Exam{
-		Number:       number,
-		Results:      results,
-		AverageScore: averageScore,
+	s.mu.RLock()
+	students, total := s.studentPage(q, after, pageSize(q.Limit))
+	s.mu.RUnlock()
+
+	page, next := cutPage(students, studentPosition(q.SortBy), q.Limit)
+	return &StudentPage{
+		Students:   page,
+		Total:      total,
+		NextCursor: next,
 	}, nil
 }
+
+// studentPage reads up to size students matching q's filters (every one
+// when size is 0) from the order q sorts by, starting after after, and
+// counts how many match in all. The caller must hold s.mu.
+func (s *MemoryStore) studentPage(q StudentQuery, after *cursor, size int) ([]models.StudentSummary, int) {
+	order, ok := s.studentOrders[q.SortBy]
+	if !ok {
+		order = s.studentOrders[SortStudentID]
+	}
+
+	total := order.len()
+	if q.Prefix != "" || q.MinAverage != nil || q.MaxAverage != nil || q.Exam != nil {
+		total = 0
+		for studentID := range s.scores {
+			if s.matchesStudent(q, studentID) {
+				total++
+			}
+		}
+	}
+
+	students := make([]models.StudentSummary, 0)
+	for entry := range order.walk(afterEntry(after), q.Descending) {
+		if size > 0 && len(students) == size {
+			break
+		}
+		if !s.matchesStudent(q, entry.id) {
+			continue
+		}
+		student, _ := s.studentSummary(entry.id)
+		students = append(students, student)
+	}
+	return students, total
+}
+
+// matchesStudent reports whether a stored student passes q's filters. The
+// caller must hold s.mu.
+func (s *MemoryStore) matchesStudent(q StudentQuery, studentID string) bool {
+	if !strings.HasPrefix(studentID, q.Prefix) {
+		return false
+	}
+	if q.Exam != nil {
+		if _, took := s.scores[studentID][*q.Exam]; !took {
+			return false
+		}
+	}
+	return matchesAverage(s.average[studentID], q.MinAverage, q.MaxAverage)
+}
+
+// studentSummary summarizes a student, reporting false if they have no
+// scores. The caller must hold s.mu.
+func (s *MemoryStore) studentSummary(studentID string) (models.StudentSummary, bool) {
+	exams, exists := s.scores[studentID]
+	if !exists {
+		return models.StudentSummary{}, false
+	}
+	return models.StudentSummary{
+		ID:           studentID,
+		AverageScore: s.average[studentID],
+		ExamCount:    len(exams),
+	}, true
+}
+
+// QueryExams returns a filtered, sorted page of exam summaries
+func (s *MemoryStore) QueryExams(q ExamQuery) (*ExamPage, error) {
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.examOrders[q.SortBy]
+	if !ok {
+		order = s.examOrders[SortExamNumber]
+	}
+
+	total := order.len()
+	if q.Student != "" || q.MinAverage != nil || q.MaxAverage != nil {
+		total = 0
+		for number := range s.exams {
+			if s.matchesExam(q, number) {
+				total++
+			}
+		}
+	}
+
+	size := pageSize(q.Limit)
+	exams := make([]models.ExamSummary, 0)
+	for entry := range order.walk(afterEntry(after), q.Descending) {
+		if size > 0 && len(exams) == size {
+			break
+		}
+		if !s.matchesExam(q, entry.number) {
+			continue
+		}
+		exam, _ := s.examSummary(entry.number)
+		exams = append(exams, exam)
+	}
+
+	page, next := cutPage(exams, examPosition(q.SortBy), q.Limit)
+	return &ExamPage{
+		Exams:      page,
+		Total:      total,
+		NextCursor: next,
+	}, nil
+}
+
+// matchesExam reports whether a recorded exam passes q's filters. The
+// caller must hold s.mu.
+func (s *MemoryStore) matchesExam(q ExamQuery, number int) bool {
+	index := s.exams[number]
+	if q.Student != "" {
+		if _, took := index.students[q.Student]; !took {
+			return false
+		}
+	}
+	return matchesAverage(index.average(), q.MinAverage, q.MaxAverage)
+}
+
+// examSummary summarizes an exam, reporting false if it has no scores. The
+// caller must hold s.mu.
+func (s *MemoryStore) examSummary(number int) (models.ExamSummary, bool) {
+	index, exists := s.exams[number]
+	if !exists {
+		return models.ExamSummary{}, false
+	}
+	return models.ExamSummary{
+		Number:       number,
+		AverageScore: index.average(),
+		StudentCount: index.len(),
+	}, true
+}
+
+// newStudentOrders returns an empty index for each field students can be
+// sorted by
+func newStudentOrders() map[StudentSort]*rankIndex {
+	return map[StudentSort]*rankIndex{
+		SortStudentID:      {},
+		SortStudentAverage: {},
+		SortStudentExams:   {},
+	}
+}
+
+// newExamOrders returns an empty index for each field exams can be sorted
+// by
+func newExamOrders() map[ExamSort]*rankIndex {
+	return map[ExamSort]*rankIndex{
+		SortExamNumber:   {},
+		SortExamAverage:  {},
+		SortExamStudents: {},
+	}
+}
+
+// Stats returns the number of students, exams and scores stored
+func (s *MemoryStore) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Stats{
+		Students:   len(s.scores),
+		Exams:      len(s.exams),
+		Scores:     s.count,
+		Duplicates: s.duplicates,
+	}
+}
@@ -14,7 +14,7 @@ func TestMemoryStore_AddScore(t *testing.T) {
 		Score:     0.85,
 	}
 
-	err := store.AddScore(event)
+	_, err := store.AddScore(event)
 	if err != nil {
 		t.Fatalf("AddScore failed: %v", err)
 	}
@@ -223,3 +223,117 @@ func TestMemoryStore_ConcurrentAccess(t *testing.T) {
 		t.Errorf("Expected 10 students after concurrent writes, got %d", len(students))
 	}
 }
+
+func TestMemoryStore_GetScoreHistory(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.60, Source: "sse"})
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.90, Source: "manual"})
+	store.AddScore(models.ScoreEvent{Exam: 2, StudentID: "student1", Score: 0.70})
+
+	history, err := store.GetScoreHistory("student1", 1)
+	if err != nil {
+		t.Fatalf("GetScoreHistory failed: %v", err)
+	}
+
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+	if history[0].Score != 0.60 || history[0].Source != "sse" {
+		t.Errorf("Expected first entry 0.60 from sse, got %.2f from %s", history[0].Score, history[0].Source)
+	}
+	if history[1].Score != 0.90 || history[1].Source != "manual" {
+		t.Errorf("Expected second entry 0.90 from manual, got %.2f from %s", history[1].Score, history[1].Source)
+	}
+	if history[1].ReceivedAt.Before(history[0].ReceivedAt) {
+		t.Error("Expected history in receive order")
+	}
+
+	if _, err := store.GetScoreHistory("nonexistent", 1); err != ErrStudentNotFound {
+		t.Errorf("Expected ErrStudentNotFound, got %v", err)
+	}
+	if _, err := store.GetScoreHistory("student1", 3); err != ErrExamNotFound {
+		t.Errorf("Expected ErrExamNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStore_ScorePolicy(t *testing.T) {
+	tests := []struct {
+		policy   ScorePolicy
+		expected float64
+	}{
+		{PolicyLatest, 0.70},
+		{PolicyBest, 0.90},
+		{PolicyFirst, 0.50},
+		{PolicyAverage, 0.70},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryStore(WithScorePolicy(tt.policy))
+		for _, score := range []float64{0.50, 0.90, 0.70} {
+			store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: score})
+		}
+		store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student2", Score: 0.30})
+
+		student, _ := store.GetStudent("student1")
+		if len(student.Scores) != 1 {
+			t.Fatalf("%s: Expected 1 score, got %d", tt.policy, len(student.Scores))
+		}
+		if diff := student.Scores[0].Score - tt.expected; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s: Expected score %.2f, got %.2f", tt.policy, tt.expected, student.Scores[0].Score)
+		}
+		if student.Scores[0].Attempts != 3 {
+			t.Errorf("%s: Expected 3 attempts, got %d", tt.policy, student.Scores[0].Attempts)
+		}
+
+		exam, _ := store.GetExam(1)
+		expectedAvg := (tt.expected + 0.30) / 2
+		if diff := exam.AverageScore - expectedAvg; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s: Expected exam average %.2f, got %.2f", tt.policy, expectedAvg, exam.AverageScore)
+		}
+	}
+}
+
+func TestParseScorePolicy(t *testing.T) {
+	if policy, err := ParseScorePolicy("best"); err != nil || policy != PolicyBest {
+		t.Errorf("Expected PolicyBest, got %q (%v)", policy, err)
+	}
+	if _, err := ParseScorePolicy("median"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+func TestMemoryStore_OnScore(t *testing.T) {
+	store := NewMemoryStore()
+
+	var published []models.ScoreRecord
+	store.OnScore(func(record models.ScoreRecord) {
+		// Hooks run after the score is visible to readers
+		if _, err := store.GetStudent(record.StudentID); err != nil {
+			t.Errorf("Expected stored student in hook, got %v", err)
+		}
+		published = append(published, record)
+	})
+
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.85, Source: "sse"})
+
+	if len(published) != 1 {
+		t.Fatalf("Expected 1 published record, got %d", len(published))
+	}
+	if published[0].StudentID != "student1" || published[0].Source != "sse" || published[0].ReceivedAt.IsZero() {
+		t.Errorf("Unexpected published record %+v", published[0])
+	}
+}
+
+func TestMemoryStore_Stats(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.85})
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.90})
+	store.AddScore(models.ScoreEvent{Exam: 2, StudentID: "student2", Score: 0.70})
+
+	stats := store.Stats()
+	if stats.Students != 2 || stats.Exams != 2 || stats.Scores != 3 {
+		t.Errorf("Expected 2 students, 2 exams and 3 scores, got %+v", stats)
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"channel-test/internal/validation"
+	"log/slog"
+	"time"
+)
+
+// Option configures a store
+type Option func(*options)
+
+type options struct {
+	policy       ScorePolicy
+	compactEvery int
+	syncWrites   bool
+	logger       *slog.Logger
+	dedupWindow  time.Duration
+	dedupSize    int
+	dedupContent bool
+	restoreRules validation.Validator
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		policy:       PolicyLatest,
+		compactEvery: defaultCompactEvery,
+		logger:       slog.Default(),
+		restoreRules: validation.Default(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithScorePolicy sets which attempt counts as a student's score when an
+// exam is scored more than once. The default is PolicyLatest.
+func WithScorePolicy(policy ScorePolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithCompactEvery sets how many log records a FileStore accumulates
+// before compacting them into a snapshot. Zero disables automatic compaction.
+// Compaction runs in the background; writes only wait while the state is
+// copied and the log is rewritten, not while the snapshot is written.
+func WithCompactEvery(n int) Option {
+	return func(o *options) {
+		o.compactEvery = n
+	}
+}
+
+// WithRestoreValidator sets the rules every record restored from a
+// snapshot must pass, such as custom score scales; one failing record
+// rejects the whole snapshot. Rules that compare with earlier scores
+// don't fit a restored history, so build it without one. The default is
+// validation.Default().
+func WithRestoreValidator(validator validation.Validator) Option {
+	return func(o *options) {
+		o.restoreRules = validator
+	}
+}
+
+// WithSyncWrites makes a FileStore fsync the log after every AddScore,
+// trading write throughput for durability across power loss
+func WithSyncWrites(sync bool) Option {
+	return func(o *options) {
+		o.syncWrites = sync
+	}
+}
+
+// WithLogger sets the logger used to report recovery and compaction
+// problems. The default is slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithDedup makes the store ignore a score whose event ID from the same
+// source was received within window. At most size scores are remembered,
+// DefaultDedupSize if size is not positive. Deduplication is off by
+// default, and scores without an ID are only deduplicated with
+// WithContentDedup.
+func WithDedup(window time.Duration, size int) Option {
+	return func(o *options) {
+		o.dedupWindow = window
+		o.dedupSize = size
+		if o.dedupSize <= 0 {
+			o.dedupSize = DefaultDedupSize
+		}
+	}
+}
+
+// WithContentDedup makes deduplication also ignore a score without an event
+// ID when a score with the same exam, student and score was received within
+// the window. A student genuinely given the same score twice within the
+// window, such as a retake, then loses the second attempt, so it is off
+// by default. It has no effect without WithDedup.
+func WithContentDedup() Option {
+	return func(o *options) {
+		o.dedupContent = true
+	}
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"fmt"
+)
+
+// ScorePolicy selects which attempt counts as a student's score on an
+// exam when the exam has been scored more than once
+type ScorePolicy string
+
+const (
+	// PolicyLatest counts the most recently received score
+	PolicyLatest ScorePolicy = "latest"
+	// PolicyBest counts the highest score
+	PolicyBest ScorePolicy = "best"
+	// PolicyFirst counts the first received score
+	PolicyFirst ScorePolicy = "first"
+	// PolicyAverage counts the mean of all attempts
+	PolicyAverage ScorePolicy = "average"
+)
+
+// ParseScorePolicy parses a policy name
+func ParseScorePolicy(name string) (ScorePolicy, error) {
+	switch policy := ScorePolicy(name); policy {
+	case PolicyLatest, PolicyBest, PolicyFirst, PolicyAverage:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown score policy %q", name)
+	}
+}
+
+// examHistory holds every attempt a student made on one exam along with
+// running values needed to apply any ScorePolicy in constant time
+type examHistory struct {
+	attempts []models.ScoreRecord
+	sum      float64
+	best     float64
+}
+
+func (h *examHistory) add(record models.ScoreRecord) {
+	if len(h.attempts) == 0 || record.Score > h.best {
+		h.best = record.Score
+	}
+	h.sum += record.Score
+	h.attempts = append(h.attempts, record)
+}
+
+// score returns the effective score under policy
+func (h *examHistory) score(policy ScorePolicy) float64 {
+	switch policy {
+	case PolicyBest:
+		return h.best
+	case PolicyFirst:
+		return h.attempts[0].Score
+	case PolicyAverage:
+		return h.sum / float64(len(h.attempts))
+	default:
+		return h.attempts[len(h.attempts)-1].Score
+	}
+}
+
+// studentScore summarizes the history as the student's score on the exam
+func (h *examHistory) studentScore(policy ScorePolicy) models.StudentScore {
+	latest := h.attempts[len(h.attempts)-1]
+	return models.StudentScore{
+		Exam:      latest.Exam,
+		Score:     h.score(policy),
+		Attempts:  len(h.attempts),
+		Timestamp: latest.ReceivedAt,
+	}
+}
@@ -0,0 +1,261 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidCursor is returned when a query cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// StudentSort is a field students can be sorted by
+type StudentSort string
+
+const (
+	SortStudentID      StudentSort = "id"
+	SortStudentAverage StudentSort = "average"
+	SortStudentExams   StudentSort = "exams"
+)
+
+// ExamSort is a field exams can be sorted by
+type ExamSort string
+
+const (
+	SortExamNumber   ExamSort = "number"
+	SortExamAverage  ExamSort = "average"
+	SortExamStudents ExamSort = "students"
+)
+
+// StudentQuery filters, sorts and pages the list of students.
+// Zero values mean no filter; results default to ascending ID order.
+type StudentQuery struct {
+	Prefix     string   // only IDs starting with Prefix
+	MinAverage *float64 // only students averaging at least this
+	MaxAverage *float64 // only students averaging at most this
+	Exam       *int     // only students who took this exam
+
+	SortBy     StudentSort
+	Descending bool
+
+	Limit  int    // maximum results per page, 0 for all
+	Cursor string // NextCursor from the previous page
+}
+
+// StudentPage is one page of a student query
+type StudentPage struct {
+	Students   []models.StudentSummary
+	Total      int    // number of students matching the filters
+	NextCursor string // empty on the last page
+}
+
+// ExamQuery filters, sorts and pages the list of exams.
+// Zero values mean no filter; results default to ascending exam number.
+type ExamQuery struct {
+	MinAverage *float64 // only exams averaging at least this
+	MaxAverage *float64 // only exams averaging at most this
+	Student    string   // only exams taken by this student
+
+	SortBy     ExamSort
+	Descending bool
+
+	Limit  int    // maximum results per page, 0 for all
+	Cursor string // NextCursor from the previous page
+}
+
+// ExamPage is one page of an exam query
+type ExamPage struct {
+	Exams      []models.ExamSummary
+	Total      int    // number of exams matching the filters
+	NextCursor string // empty on the last page
+}
+
+// ParseStudentSort parses a student sort field name
+func ParseStudentSort(name string) (StudentSort, error) {
+	switch sortBy := StudentSort(name); sortBy {
+	case SortStudentID, SortStudentAverage, SortStudentExams:
+		return sortBy, nil
+	default:
+		return "", fmt.Errorf("unknown student sort %q", name)
+	}
+}
+
+// ParseExamSort parses an exam sort field name
+func ParseExamSort(name string) (ExamSort, error) {
+	switch sortBy := ExamSort(name); sortBy {
+	case SortExamNumber, SortExamAverage, SortExamStudents:
+		return sortBy, nil
+	default:
+		return "", fmt.Errorf("unknown exam sort %q", name)
+	}
+}
+
+// cursor is the position of the last item on a page. Items are ordered
+// by Key and then by ID or Number, so the next page starts strictly after it.
+type cursor struct {
+	Key    float64 `json:"k"`
+	ID     string  `json:"i,omitempty"`
+	Number int     `json:"n,omitempty"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// compare orders two cursor positions
+func (c cursor) compare(other cursor) int {
+	switch {
+	case c.Key < other.Key:
+		return -1
+	case c.Key > other.Key:
+		return 1
+	}
+
+	if cmp := strings.Compare(c.ID, other.ID); cmp != 0 {
+		return cmp
+	}
+
+	switch {
+	case c.Number < other.Number:
+		return -1
+	case c.Number > other.Number:
+		return 1
+	}
+	return 0
+}
+
+// less reports whether c comes before other in ascending or descending
+// order
+func (c cursor) less(other cursor, descending bool) bool {
+	if descending {
+		return c.compare(other) > 0
+	}
+	return c.compare(other) < 0
+}
+
+// entry places a cursor position in a rankIndex. Walking the index visits
+// positions in ascending order, so the same index serves both orders.
+func (c cursor) entry() rankEntry {
+	return rankEntry{key: -c.Key, id: c.ID, number: c.Number}
+}
+
+// afterEntry returns where a page following after starts in a rankIndex,
+// or nil for the first page
+func afterEntry(after *cursor) *rankEntry {
+	if after == nil {
+		return nil
+	}
+	entry := after.entry()
+	return &entry
+}
+
+// reorder moves an item in an ordering index from its old position, if it
+// was indexed, to position
+func reorder(index *rankIndex, old cursor, indexed bool, position cursor) {
+	if indexed {
+		if old == position {
+			return
+		}
+		index.removeEntry(old.entry())
+	}
+	index.insertEntry(position.entry())
+}
+
+// pageSize returns how many items to collect for a page of limit: one
+// more than the page, to tell whether another follows, or every item when
+// limit is 0
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	return limit + 1
+}
+
+// cutPage trims items, which are in page order and hold up to
+// pageSize(limit) of them, to the page, returning the cursor for the page
+// after it when more follow
+func cutPage[T any](items []T, position func(T) cursor, limit int) ([]T, string) {
+	if limit <= 0 || len(items) <= limit {
+		return items, ""
+	}
+
+	page := items[:limit]
+	return page, position(page[limit-1]).encode()
+}
+
+// paginate sorts items by their cursor position and returns the page
+// following after, along with the cursor for the page after that
+func paginate[T any](items []T, position func(T) cursor, descending bool, after *cursor, limit int) ([]T, string) {
+	sort.Slice(items, func(i, j int) bool {
+		return position(items[i]).less(position(items[j]), descending)
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return after.less(position(items[i]), descending)
+		})
+	}
+	return cutPage(items[start:], position, limit)
+}
+
+// matchesAverage reports whether average is within the optional bounds
+func matchesAverage(average float64, min, max *float64) bool {
+	if min != nil && average < *min {
+		return false
+	}
+	if max != nil && average > *max {
+		return false
+	}
+	return true
+}
+
+// studentPosition returns the cursor position of a student summary
+func studentPosition(sortBy StudentSort) func(models.StudentSummary) cursor {
+	return func(s models.StudentSummary) cursor {
+		switch sortBy {
+		case SortStudentAverage:
+			return cursor{Key: s.AverageScore, ID: s.ID}
+		case SortStudentExams:
+			return cursor{Key: float64(s.ExamCount), ID: s.ID}
+		default:
+			return cursor{ID: s.ID}
+		}
+	}
+}
+
+// examPosition returns the cursor position of an exam summary
+func examPosition(sortBy ExamSort) func(models.ExamSummary) cursor {
+	return func(e models.ExamSummary) cursor {
+		switch sortBy {
+		case SortExamAverage:
+			return cursor{Key: e.AverageScore, Number: e.Number}
+		case SortExamStudents:
+			return cursor{Key: float64(e.StudentCount), Number: e.Number}
+		default:
+			return cursor{Number: e.Number}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func setupQueryStore() *MemoryStore {
+	store := NewMemoryStore()
+
+	events := []models.ScoreEvent{
+		{Exam: 1, StudentID: "alice", Score: 0.90},
+		{Exam: 2, StudentID: "alice", Score: 0.70},
+		{Exam: 1, StudentID: "albert", Score: 0.60},
+		{Exam: 1, StudentID: "bob", Score: 0.80},
+		{Exam: 2, StudentID: "bob", Score: 0.80},
+		{Exam: 3, StudentID: "bob", Score: 0.80},
+		{Exam: 3, StudentID: "carol", Score: 0.50},
+	}
+	for _, event := range events {
+		store.AddScore(event)
+	}
+
+	return store
+}
+
+func studentIDs(page *StudentPage) []string {
+	ids := make([]string, len(page.Students))
+	for i, student := range page.Students {
+		ids[i] = student.ID
+	}
+	return ids
+}
+
+func TestMemoryStore_QueryStudents(t *testing.T) {
+	store := setupQueryStore()
+	exam3 := 3
+	minAverage := 0.75
+
+	tests := []struct {
+		name     string
+		query    StudentQuery
+		expected []string
+	}{
+		{"default order", StudentQuery{}, []string{"albert", "alice", "bob", "carol"}},
+		{"descending ID", StudentQuery{Descending: true}, []string{"carol", "bob", "alice", "albert"}},
+		{"by average", StudentQuery{SortBy: SortStudentAverage}, []string{"carol", "albert", "alice", "bob"}},
+		{"by exam count descending", StudentQuery{SortBy: SortStudentExams, Descending: true}, []string{"bob", "alice", "carol", "albert"}},
+		{"prefix", StudentQuery{Prefix: "al"}, []string{"albert", "alice"}},
+		{"took exam", StudentQuery{Exam: &exam3}, []string{"bob", "carol"}},
+		{"min average", StudentQuery{MinAverage: &minAverage}, []string{"alice", "bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.QueryStudents(tt.query)
+			if err != nil {
+				t.Fatalf("QueryStudents failed: %v", err)
+			}
+			if got := studentIDs(page); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+			if page.Total != len(tt.expected) {
+				t.Errorf("Expected total %d, got %d", len(tt.expected), page.Total)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_QueryStudents_Pagination(t *testing.T) {
+	store := setupQueryStore()
+
+	query := StudentQuery{SortBy: SortStudentAverage, Descending: true, Limit: 3}
+	var got []string
+	pages := 0
+
+	for {
+		page, err := store.QueryStudents(query)
+		if err != nil {
+			t.Fatalf("QueryStudents failed: %v", err)
+		}
+		got = append(got, studentIDs(page)...)
+		pages++
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+
+		// New students inserted before the cursor must not shift the next page
+		store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "zed", Score: 1.0})
+	}
+
+	expected := []string{"bob", "alice", "albert", "carol"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if pages != 2 {
+		t.Errorf("Expected 2 pages, got %d", pages)
+	}
+}
+
+func TestMemoryStore_QueryStudents_InvalidCursor(t *testing.T) {
+	store := setupQueryStore()
+
+	if _, err := store.QueryStudents(StudentQuery{Cursor: "not a cursor"}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestMemoryStore_QueryExams(t *testing.T) {
+	store := setupQueryStore()
+	maxAverage := 0.70
+
+	tests := []struct {
+		name     string
+		query    ExamQuery
+		expected []int
+	}{
+		{"default order", ExamQuery{}, []int{1, 2, 3}},
+		{"by average descending", ExamQuery{SortBy: SortExamAverage, Descending: true}, []int{1, 2, 3}},
+		{"by average", ExamQuery{SortBy: SortExamAverage}, []int{3, 2, 1}},
+		{"by students", ExamQuery{SortBy: SortExamStudents}, []int{2, 3, 1}},
+		{"taken by student", ExamQuery{Student: "carol"}, []int{3}},
+		{"max average", ExamQuery{MaxAverage: &maxAverage}, []int{3}},
+		{"unknown student", ExamQuery{Student: "nobody"}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.QueryExams(tt.query)
+			if err != nil {
+				t.Fatalf("QueryExams failed: %v", err)
+			}
+			got := make([]int, len(page.Exams))
+			for i, exam := range page.Exams {
+				got[i] = exam.Number
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	page, _ := store.QueryExams(ExamQuery{Limit: 2})
+	if len(page.Exams) != 2 || page.NextCursor == "" {
+		t.Fatalf("Expected first page of 2 with a cursor, got %d", len(page.Exams))
+	}
+	page, _ = store.QueryExams(ExamQuery{Limit: 2, Cursor: page.NextCursor})
+	if len(page.Exams) != 1 || page.Exams[0].Number != 3 || page.NextCursor != "" {
+		t.Errorf("Expected last page with exam 3, got %+v", page)
+	}
+}
+
+func TestQueryStudents_PagesMatchSortedSummaries(t *testing.T) {
+	memory := NewMemoryStore()
+	sharded := NewShardedStore(4)
+
+	random := rand.New(rand.NewPCG(3, 4))
+	for i := 0; i < 500; i++ {
+		// Quarter points keep the averages exact, so the expected order
+		// can be worked out from GetStudent
+		event := models.ScoreEvent{
+			Exam:      1 + random.IntN(5),
+			StudentID: fmt.Sprintf("s%02d", random.IntN(60)),
+			Score:     float64(random.IntN(5)) / 4,
+		}
+		memory.AddScore(event)
+		sharded.AddScore(event)
+	}
+
+	var summaries []models.StudentSummary
+	for _, id := range memory.GetAllStudents() {
+		student, _ := memory.GetStudent(id)
+		summaries = append(summaries, models.StudentSummary{ID: id, AverageScore: student.AverageScore, ExamCount: len(student.Scores)})
+	}
+
+	for _, store := range []Store{memory, sharded} {
+		for _, sortBy := range []StudentSort{SortStudentID, SortStudentAverage, SortStudentExams} {
+			for _, descending := range []bool{false, true} {
+				expected, _ := paginate(slices.Clone(summaries), studentPosition(sortBy), descending, nil, 0)
+
+				query := StudentQuery{SortBy: sortBy, Descending: descending, Limit: 7}
+				var got []models.StudentSummary
+				for {
+					page, err := store.QueryStudents(query)
+					if err != nil {
+						t.Fatalf("QueryStudents failed: %v", err)
+					}
+					if page.Total != len(summaries) {
+						t.Errorf("%s: Expected total %d, got %d", sortBy, len(summaries), page.Total)
+					}
+					got = append(got, page.Students...)
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					t.Errorf("%s descending %v: Expected %v, got %v", sortBy, descending, expected, got)
+				}
+			}
+		}
+	}
+}
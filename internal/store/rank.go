@@ -0,0 +1,284 @@
+package store
+
+import (
+	"iter"
+	"math"
+	"math/rand/v2"
+)
+
+// rankEntry is one student's position in a rankIndex, or an exam's when
+// the index orders exams by number
+type rankEntry struct {
+	key    float64
+	id     string
+	number int
+}
+
+// before reports whether e ranks ahead of other: higher keys first, ties
+// broken by ascending student ID, then exam number, so ranks are
+// deterministic
+func (e rankEntry) before(other rankEntry) bool {
+	if e.key != other.key {
+		return e.key > other.key
+	}
+	if e.id != other.id {
+		return e.id < other.id
+	}
+	return e.number < other.number
+}
+
+// rankIndex keeps students ordered by a score so ranks and top-N lists are
+// read by position instead of sorting on every request. It is a treap:
+// a binary search tree in rank order that stays balanced by keeping random
+// priorities in heap order, with subtree sizes so positions are found in
+// O(log n) and inserts and removals don't shift the entries after them.
+type rankIndex struct {
+	root *rankNode
+}
+
+// rankNode is one entry of a rankIndex and the subtree below it
+type rankNode struct {
+	entry       rankEntry
+	priority    uint32
+	size        int // entries in this subtree
+	left, right *rankNode
+}
+
+func (n *rankNode) len() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *rankNode) resize() {
+	n.size = 1 + n.left.len() + n.right.len()
+}
+
+// newRankIndex builds an index holding entries, which must already be in
+// rank order, in O(n)
+func newRankIndex(entries []rankEntry) rankIndex {
+	var build func(entries []rankEntry, depth int) *rankNode
+	build = func(entries []rankEntry, depth int) *rankNode {
+		if len(entries) == 0 {
+			return nil
+		}
+		mid := len(entries) / 2
+		// Priorities fall with depth so later inserts keep the heap order
+		n := &rankNode{entry: entries[mid], priority: math.MaxUint32 - uint32(depth)}
+		n.left = build(entries[:mid], depth+1)
+		n.right = build(entries[mid+1:], depth+1)
+		n.resize()
+		return n
+	}
+	return rankIndex{root: build(entries, 0)}
+}
+
+func (r *rankIndex) len() int {
+	return r.root.len()
+}
+
+// prefixLen returns how many entries, from the top, satisfy inPrefix, which
+// must hold for every entry up to some position and for none after it
+func (r *rankIndex) prefixLen(inPrefix func(rankEntry) bool) int {
+	count := 0
+	for n := r.root; n != nil; {
+		if inPrefix(n.entry) {
+			count += n.left.len() + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return count
+}
+
+// search returns the position at or after which entry belongs
+func (r *rankIndex) search(entry rankEntry) int {
+	return r.prefixLen(func(e rankEntry) bool { return e.before(entry) })
+}
+
+func (r *rankIndex) insert(key float64, id string) {
+	r.insertEntry(rankEntry{key: key, id: id})
+}
+
+func (r *rankIndex) insertEntry(entry rankEntry) {
+	r.root = insertNode(r.root, &rankNode{entry: entry, priority: rand.Uint32(), size: 1})
+}
+
+// insertNode adds node below n, returning the subtree's new root
+func insertNode(n, node *rankNode) *rankNode {
+	if n == nil {
+		return node
+	}
+	if node.priority > n.priority {
+		node.left, node.right = splitNodes(n, node.entry)
+		node.resize()
+		return node
+	}
+	if n.entry.before(node.entry) {
+		n.right = insertNode(n.right, node)
+	} else {
+		n.left = insertNode(n.left, node)
+	}
+	n.resize()
+	return n
+}
+
+// splitNodes divides the subtree at n into the entries ranked before entry
+// and the rest
+func splitNodes(n *rankNode, entry rankEntry) (*rankNode, *rankNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.entry.before(entry) {
+		left, right := splitNodes(n.right, entry)
+		n.right = left
+		n.resize()
+		return n, right
+	}
+	left, right := splitNodes(n.left, entry)
+	n.left = right
+	n.resize()
+	return left, n
+}
+
+func (r *rankIndex) remove(key float64, id string) {
+	r.removeEntry(rankEntry{key: key, id: id})
+}
+
+func (r *rankIndex) removeEntry(entry rankEntry) {
+	r.root = removeNode(r.root, entry)
+}
+
+// removeNode removes entry from the subtree at n, returning its new root
+func removeNode(n *rankNode, entry rankEntry) *rankNode {
+	switch {
+	case n == nil:
+		return nil
+	case n.entry == entry:
+		return joinNodes(n.left, n.right)
+	case n.entry.before(entry):
+		n.right = removeNode(n.right, entry)
+	default:
+		n.left = removeNode(n.left, entry)
+	}
+	n.resize()
+	return n
+}
+
+// joinNodes joins two subtrees, every entry of a ranking before every
+// entry of b
+func joinNodes(a, b *rankNode) *rankNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = joinNodes(a.right, b)
+		a.resize()
+		return a
+	}
+	b.left = joinNodes(a, b.left)
+	b.resize()
+	return b
+}
+
+// at returns the entry at position i, counting from 0 at the top
+func (r *rankIndex) at(i int) rankEntry {
+	n := r.root
+	for {
+		left := n.left.len()
+		switch {
+		case i < left:
+			n = n.left
+		case i == left:
+			return n.entry
+		default:
+			i -= left + 1
+			n = n.right
+		}
+	}
+}
+
+// all yields the entries in rank order
+func (r *rankIndex) all() iter.Seq[rankEntry] {
+	return func(yield func(rankEntry) bool) {
+		r.root.walk(yield)
+	}
+}
+
+// walk yields the subtree's entries in order, reporting whether yield
+// asked for more
+func (n *rankNode) walk(yield func(rankEntry) bool) bool {
+	if n == nil {
+		return true
+	}
+	return n.left.walk(yield) && yield(n.entry) && n.right.walk(yield)
+}
+
+// walk yields the entries ranked after after, in rank order, or those
+// ranked before it, closest first, when descending. A nil after starts
+// from the top, or from the bottom when descending.
+func (r *rankIndex) walk(after *rankEntry, descending bool) iter.Seq[rankEntry] {
+	return func(yield func(rankEntry) bool) {
+		if !descending {
+			i := 0
+			if after != nil {
+				i = r.prefixLen(func(e rankEntry) bool { return !after.before(e) })
+			}
+			for ; i < r.len(); i++ {
+				if !yield(r.at(i)) {
+					return
+				}
+			}
+			return
+		}
+
+		i := r.len() - 1
+		if after != nil {
+			i = r.search(*after) - 1
+		}
+		for ; i >= 0; i-- {
+			if !yield(r.at(i)) {
+				return
+			}
+		}
+	}
+}
+
+// top returns a copy of the first n entries, or of all of them when n is 0
+func (r *rankIndex) top(n int) []rankEntry {
+	if n <= 0 || n > r.len() {
+		n = r.len()
+	}
+	entries := make([]rankEntry, 0, n)
+	for entry := range r.all() {
+		if len(entries) == n {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// rank returns the 1-based position of an entry that is in the index
+func (r *rankIndex) rank(key float64, id string) int {
+	return r.search(rankEntry{key: key, id: id}) + 1
+}
+
+// countAtLeast returns how many entries have a key of at least key
+func (r *rankIndex) countAtLeast(key float64) int {
+	return r.prefixLen(func(e rankEntry) bool { return e.key >= key })
+}
+
+// percentileRank places a rank on a 0-100 scale where the top of n is 100
+// and the bottom is 0
+func percentileRank(rank, n int) float64 {
+	if n <= 1 {
+		return 100
+	}
+	return float64(n-rank) / float64(n-1) * 100
+}
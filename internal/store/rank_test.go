@@ -0,0 +1,177 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestRankIndex_MatchesSortedEntries(t *testing.T) {
+	var index rankIndex
+	var expected []rankEntry
+	keys := make(map[string]float64)
+
+	random := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprintf("s%03d", random.IntN(300))
+		key := float64(random.IntN(20))
+
+		// Rescore a student already in the index, as AddScore does
+		if old, ok := keys[id]; ok {
+			index.remove(old, id)
+			expected = slices.DeleteFunc(expected, func(e rankEntry) bool { return e.id == id })
+		}
+		if random.IntN(5) == 0 {
+			delete(keys, id)
+			continue
+		}
+		index.insert(key, id)
+		expected = append(expected, rankEntry{key: key, id: id})
+		keys[id] = key
+	}
+	slices.SortFunc(expected, func(a, b rankEntry) int {
+		if a.before(b) {
+			return -1
+		}
+		return 1
+	})
+
+	if index.len() != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), index.len())
+	}
+	if got := index.top(0); !slices.Equal(got, expected) {
+		t.Fatalf("Expected entries %v, got %v", expected, got)
+	}
+	for i, entry := range expected {
+		if index.at(i) != entry {
+			t.Errorf("Position %d: Expected %v, got %v", i, entry, index.at(i))
+		}
+		if rank := index.rank(entry.key, entry.id); rank != i+1 {
+			t.Errorf("%s: Expected rank %d, got %d", entry.id, i+1, rank)
+		}
+	}
+	for key := 0.0; key <= 20; key++ {
+		count := 0
+		for _, entry := range expected {
+			if entry.key >= key {
+				count++
+			}
+		}
+		if got := index.countAtLeast(key); got != count {
+			t.Errorf("Expected %d entries at least %g, got %d", count, key, got)
+		}
+	}
+
+	// A rebuilt index stays balanced for later inserts
+	rebuilt := newRankIndex(expected)
+	if got := rebuilt.top(10); !slices.Equal(got, expected[:10]) {
+		t.Errorf("Expected rebuilt top 10 %v, got %v", expected[:10], got)
+	}
+	rebuilt.insert(100, "top")
+	if rebuilt.at(0).id != "top" || rebuilt.len() != len(expected)+1 {
+		t.Errorf("Expected top first of %d, got %v of %d", len(expected)+1, rebuilt.at(0), rebuilt.len())
+	}
+}
+
+func TestMemoryStore_GetExamLeaderboard(t *testing.T) {
+	store := NewMemoryStore()
+
+	events := []models.ScoreEvent{
+		{Exam: 1, StudentID: "carol", Score: 0.80},
+		{Exam: 1, StudentID: "alice", Score: 0.90},
+		{Exam: 1, StudentID: "bob", Score: 0.80},
+		{Exam: 1, StudentID: "dave", Score: 0.70},
+		{Exam: 1, StudentID: "dave", Score: 0.95}, // rescore moves dave to the top
+	}
+	for _, event := range events {
+		store.AddScore(event)
+	}
+
+	leaderboard, err := store.GetExamLeaderboard(1, 3)
+	if err != nil {
+		t.Fatalf("GetExamLeaderboard failed: %v", err)
+	}
+
+	// Ties are broken by student ID
+	expected := []models.RankedScore{
+		{Rank: 1, StudentID: "dave", Score: 0.95},
+		{Rank: 2, StudentID: "alice", Score: 0.90},
+		{Rank: 3, StudentID: "bob", Score: 0.80},
+	}
+	if len(leaderboard) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), leaderboard)
+	}
+	for i := range expected {
+		if leaderboard[i] != expected[i] {
+			t.Errorf("Entry %d: Expected %+v, got %+v", i, expected[i], leaderboard[i])
+		}
+	}
+
+	if all, _ := store.GetExamLeaderboard(1, 0); len(all) != 4 {
+		t.Errorf("Expected 4 entries with no limit, got %d", len(all))
+	}
+	if _, err := store.GetExamLeaderboard(2, 3); err != ErrExamNotFound {
+		t.Errorf("Expected ErrExamNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStore_GetLeaderboard(t *testing.T) {
+	store := NewMemoryStore()
+
+	events := []models.ScoreEvent{
+		{Exam: 1, StudentID: "alice", Score: 0.90},
+		{Exam: 2, StudentID: "alice", Score: 0.70},
+		{Exam: 1, StudentID: "bob", Score: 0.85},
+		{Exam: 2, StudentID: "bob", Score: 0.85},
+		{Exam: 1, StudentID: "carol", Score: 1.00},
+	}
+	for _, event := range events {
+		store.AddScore(event)
+	}
+
+	leaderboard := store.GetLeaderboard(10, 0)
+	order := []string{"carol", "bob", "alice"}
+	if len(leaderboard) != len(order) {
+		t.Fatalf("Expected %d entries, got %+v", len(order), leaderboard)
+	}
+	for i, id := range order {
+		if leaderboard[i].StudentID != id || leaderboard[i].Rank != i+1 {
+			t.Errorf("Entry %d: Expected %s at rank %d, got %+v", i, id, i+1, leaderboard[i])
+		}
+	}
+
+	// carol has taken one exam, so bob leads among students with two
+	leaderboard = store.GetLeaderboard(1, 2)
+	if len(leaderboard) != 1 || leaderboard[0].StudentID != "bob" || leaderboard[0].Rank != 1 || leaderboard[0].ExamCount != 2 {
+		t.Errorf("Expected bob at rank 1 with 2 exams, got %+v", leaderboard)
+	}
+}
+
+func TestMemoryStore_GetStudent_Rank(t *testing.T) {
+	store := NewMemoryStore()
+
+	events := []models.ScoreEvent{
+		{Exam: 1, StudentID: "alice", Score: 0.90},
+		{Exam: 1, StudentID: "bob", Score: 0.80},
+		{Exam: 1, StudentID: "carol", Score: 0.70},
+		{Exam: 2, StudentID: "bob", Score: 0.60},
+	}
+	for _, event := range events {
+		store.AddScore(event)
+	}
+
+	bob, _ := store.GetStudent("bob")
+	if bob.Scores[0].Rank != 2 || bob.Scores[0].Percentile != 50 {
+		t.Errorf("Expected exam 1 rank 2 at percentile 50, got %d at %v", bob.Scores[0].Rank, bob.Scores[0].Percentile)
+	}
+	if bob.Scores[1].Rank != 1 || bob.Scores[1].Percentile != 100 {
+		t.Errorf("Expected exam 2 rank 1 at percentile 100, got %d at %v", bob.Scores[1].Rank, bob.Scores[1].Percentile)
+	}
+
+	// Overall averages: alice 0.90, carol 0.70, bob 0.70; ties by ID
+	if bob.Rank != 2 || bob.Percentile != 50 {
+		t.Errorf("Expected overall rank 2 at percentile 50, got %d at %v", bob.Rank, bob.Percentile)
+	}
+}
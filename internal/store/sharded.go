@@ -0,0 +1,560 @@
+package store
+
+import (
+	"channel-test/internal/validation"
+	"channel-test/pkg/models"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultShards is the number of shards used when none is configured
+const DefaultShards = 16
+
+// ShardedStore implements the Store interface by spreading students over
+// lock-striped shards, each a MemoryStore holding the students whose ID
+// hashes to it. AddScore locks only its student's shard, so writes to
+// different shards run in parallel and each shard's indexes stay small.
+//
+// Queries that look at one student's history lock only that shard. Queries
+// across students read-lock one shard at a time, copying what they need,
+// and merge the copies with no lock held, so a long query never stalls
+// writers to the other shards. Each shard's part of a result is consistent,
+// but a write to a shard read later may show while an earlier write to a
+// shard read before it does not.
+type ShardedStore struct {
+	shards       []*MemoryStore
+	policy       ScorePolicy
+	restoreRules validation.Validator
+
+	hooksMu sync.RWMutex
+	hooks   []ScoreHook
+}
+
+// NewShardedStore creates an in-memory store split into shards lock
+// stripes. Each shard remembers its share of the scores kept for
+// deduplication.
+func NewShardedStore(shards int, opts ...Option) *ShardedStore {
+	shards = max(shards, 1)
+	opts = append(slices.Clone(opts), func(o *options) {
+		o.dedupSize = (o.dedupSize + shards - 1) / shards
+	})
+
+	o := newOptions(opts)
+	s := &ShardedStore{
+		shards:       make([]*MemoryStore, shards),
+		policy:       o.policy,
+		restoreRules: o.restoreRules,
+	}
+	for i := range s.shards {
+		s.shards[i] = NewMemoryStore(opts...)
+	}
+	return s
+}
+
+// shard returns the shard holding a student, picked by FNV-1a hash of the ID
+func (s *ShardedStore) shard(studentID string) *MemoryStore {
+	hash := uint32(2166136261)
+	for i := 0; i < len(studentID); i++ {
+		hash ^= uint32(studentID[i])
+		hash *= 16777619
+	}
+	return s.shards[hash%uint32(len(s.shards))]
+}
+
+// eachShard calls read on every shard in order, read-locking one shard at
+// a time so writers to the others carry on. read should copy what it needs
+// and leave merging until every shard has been read.
+func (s *ShardedStore) eachShard(read func(shard *MemoryStore)) {
+	for _, shard := range s.shards {
+		readShard(shard, read)
+	}
+}
+
+// readShard calls read with shard read-locked
+func readShard(shard *MemoryStore, read func(shard *MemoryStore)) {
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	read(shard)
+}
+
+// AddScore adds a new score event to the student's shard, unless
+// deduplication is on and the event was already received
+func (s *ShardedStore) AddScore(event models.ScoreEvent) (bool, error) {
+	record := newScoreRecord(event, time.Now())
+	shard := s.shard(record.StudentID)
+
+	shard.mu.Lock()
+	if shard.duplicate(record) {
+		shard.mu.Unlock()
+		return false, nil
+	}
+	shard.add(record)
+	shard.mu.Unlock()
+
+	s.hooksMu.RLock()
+	hooks := s.hooks
+	s.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(record)
+	}
+	return true, nil
+}
+
+// OnScore registers a hook that runs after each AddScore
+func (s *ShardedStore) OnScore(hook ScoreHook) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	s.hooks = append(s.hooks, hook)
+}
+
+// GetAllStudents returns a sorted list of all student IDs
+func (s *ShardedStore) GetAllStudents() []string {
+	students := []string{}
+	s.eachShard(func(shard *MemoryStore) {
+		for studentID := range shard.scores {
+			students = append(students, studentID)
+		}
+	})
+
+	sort.Strings(students)
+	return students
+}
+
+// GetStudent returns detailed information about a specific student, ranked
+// among the students of every shard
+func (s *ShardedStore) GetStudent(id string) (*models.Student, error) {
+	home := s.shard(id)
+
+	// Read the student from its shard, then rank each of their scores and
+	// their average against every shard
+	var student *models.Student
+	var average float64
+	readShard(home, func(shard *MemoryStore) {
+		if exams, exists := shard.scores[id]; exists {
+			student = newStudent(id, exams, s.policy, func(int, float64) (int, int) { return 0, 0 }, 0, 0)
+			average = shard.average[id]
+		}
+	})
+	if student == nil {
+		return nil, ErrStudentNotFound
+	}
+
+	ahead := make([]int, len(student.Scores))
+	took := make([]int, len(student.Scores))
+	averageAhead, ranked := 0, 0
+	s.eachShard(func(shard *MemoryStore) {
+		for i, score := range student.Scores {
+			if index, ok := shard.exams[score.Exam]; ok {
+				ahead[i] += index.ranked.search(rankEntry{key: score.Score, id: id})
+				took[i] += index.len()
+			}
+		}
+		averageAhead += shard.averages.search(rankEntry{key: average, id: id})
+		ranked += shard.averages.len()
+	})
+
+	for i := range student.Scores {
+		score := &student.Scores[i]
+		score.Rank = ahead[i] + 1
+		score.Percentile = percentileRank(score.Rank, max(took[i], score.Rank))
+	}
+	student.Rank = averageAhead + 1
+	student.Percentile = percentileRank(student.Rank, max(ranked, student.Rank))
+	return student, nil
+}
+
+// GetAllExams returns a sorted list of all exam numbers
+func (s *ShardedStore) GetAllExams() []int {
+	return s.examNumbers()
+}
+
+// examNumbers merges the exam numbers of every shard
+func (s *ShardedStore) examNumbers() []int {
+	lists := make([][]int, 0, len(s.shards))
+	s.eachShard(func(shard *MemoryStore) {
+		lists = append(lists, slices.Clone(shard.numbers))
+	})
+
+	numbers := []int{}
+	for _, number := range mergeSorted(lists, func(a, b int) bool { return a < b }) {
+		if len(numbers) == 0 || numbers[len(numbers)-1] != number {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
+
+// GetExam returns detailed information about a specific exam, with
+// results from every shard sorted by student ID
+func (s *ShardedStore) GetExam(number int) (*models.Exam, error) {
+	var sum float64
+	count := 0
+	var lists [][]models.ExamResult
+	s.eachShard(func(shard *MemoryStore) {
+		index, exists := shard.exams[number]
+		if !exists {
+			return
+		}
+		results := make([]models.ExamResult, len(index.byID))
+		for i, student := range index.byID {
+			results[i] = models.ExamResult{
+				StudentID: student.id,
+				Score:     student.history.score(s.policy),
+			}
+		}
+		lists = append(lists, results)
+		sum += index.sum
+		count += index.len()
+	})
+	if len(lists) == 0 {
+		return nil, ErrExamNotFound
+	}
+
+	results := make([]models.ExamResult, 0, count)
+	for _, result := range mergeSorted(lists, func(a, b models.ExamResult) bool { return a.StudentID < b.StudentID }) {
+		results = append(results, result)
+	}
+
+	return &models.Exam{
+		Number:       number,
+		Results:      results,
+		AverageScore: sum / float64(count),
+	}, nil
+}
+
+// rankedScores copies up to top of the highest ranked scores on an exam
+// from each shard that has it, or all of them when top is 0
+func (s *ShardedStore) rankedScores(number int, top int) [][]rankEntry {
+	var lists [][]rankEntry
+	s.eachShard(func(shard *MemoryStore) {
+		if index, exists := shard.exams[number]; exists {
+			lists = append(lists, index.ranked.top(top))
+		}
+	})
+	return lists
+}
+
+// GetExamStats returns the distribution of scores on an exam, merging the
+// ranked scores and running sums of every shard
+func (s *ShardedStore) GetExamStats(number int, q ExamStatsQuery) (*models.ExamStats, error) {
+	var lists [][]rankEntry
+	var moments scoreMoments
+	s.eachShard(func(shard *MemoryStore) {
+		if index, exists := shard.exams[number]; exists {
+			lists = append(lists, index.ranked.top(0))
+			moments = moments.merge(index.moments())
+		}
+	})
+	if len(lists) == 0 {
+		return nil, ErrExamNotFound
+	}
+
+	merged := &examScores{sum: moments.sum, m2: moments.m2}
+	entries := make([]rankEntry, 0, moments.count)
+	for _, entry := range mergeSorted(lists, rankEntry.before) {
+		entries = append(entries, entry)
+	}
+	merged.ranked = newRankIndex(entries)
+
+	return merged.stats(number, q)
+}
+
+// GetExamLeaderboard returns the top students on an exam across every
+// shard, highest score first with ties broken by student ID. A top of 0
+// returns everyone.
+func (s *ShardedStore) GetExamLeaderboard(number int, top int) ([]models.RankedScore, error) {
+	lists := s.rankedScores(number, top)
+	if len(lists) == 0 {
+		return nil, ErrExamNotFound
+	}
+
+	leaderboard := make([]models.RankedScore, 0)
+	for _, entry := range mergeSorted(lists, rankEntry.before) {
+		if top > 0 && len(leaderboard) == top {
+			break
+		}
+		leaderboard = append(leaderboard, models.RankedScore{
+			Rank:      len(leaderboard) + 1,
+			StudentID: entry.id,
+			Score:     entry.key,
+		})
+	}
+	return leaderboard, nil
+}
+
+// GetLeaderboard returns the top students by overall average across every
+// shard, counting only students who took at least minExams exams. Ranks
+// are among those students. A top of 0 returns everyone.
+func (s *ShardedStore) GetLeaderboard(top int, minExams int) []models.RankedStudent {
+	// Each shard contributes at most top qualifying students
+	var lists [][]models.RankedStudent
+	s.eachShard(func(shard *MemoryStore) {
+		var list []models.RankedStudent
+		for entry := range shard.averages.all() {
+			if top > 0 && len(list) == top {
+				break
+			}
+			examCount := len(shard.scores[entry.id])
+			if examCount < minExams {
+				continue
+			}
+			list = append(list, models.RankedStudent{
+				StudentID:    entry.id,
+				AverageScore: entry.key,
+				ExamCount:    examCount,
+			})
+		}
+		lists = append(lists, list)
+	})
+
+	leaderboard := make([]models.RankedStudent, 0)
+	for _, student := range mergeSorted(lists, func(a, b models.RankedStudent) bool {
+		return rankEntry{key: a.AverageScore, id: a.StudentID}.before(rankEntry{key: b.AverageScore, id: b.StudentID})
+	}) {
+		if top > 0 && len(leaderboard) == top {
+			break
+		}
+		student.Rank = len(leaderboard) + 1
+		leaderboard = append(leaderboard, student)
+	}
+	return leaderboard
+}
+
+// GetScoreHistory returns every score received for a student on an exam,
+// oldest first. Only the student's shard is locked.
+func (s *ShardedStore) GetScoreHistory(studentID string, number int) ([]models.ScoreRecord, error) {
+	return s.shard(studentID).GetScoreHistory(studentID, number)
+}
+
+// QueryStudents returns a filtered, sorted page of student summaries,
+// merging the start of the page from every shard
+func (s *ShardedStore) QueryStudents(q StudentQuery) (*StudentPage, error) {
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	size := pageSize(q.Limit)
+	lists := make([][]models.StudentSummary, 0, len(s.shards))
+	total := 0
+	s.eachShard(func(shard *MemoryStore) {
+		students, count := shard.studentPage(q, after, size)
+		lists = append(lists, students)
+		total += count
+	})
+
+	position := studentPosition(q.SortBy)
+	students := make([]models.StudentSummary, 0)
+	for _, student := range mergeSorted(lists, func(a, b models.StudentSummary) bool {
+		return position(a).less(position(b), q.Descending)
+	}) {
+		if size > 0 && len(students) == size {
+			break
+		}
+		students = append(students, student)
+	}
+
+	page, next := cutPage(students, position, q.Limit)
+	return &StudentPage{
+		Students:   page,
+		Total:      total,
+		NextCursor: next,
+	}, nil
+}
+
+// QueryExams returns a filtered, sorted page of exam summaries, adding up
+// the running sums of every shard. Each exam's students are spread over the
+// shards, so unlike students the summaries are merged and sorted here.
+func (s *ShardedStore) QueryExams(q ExamQuery) (*ExamPage, error) {
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	type examTotals struct {
+		sum   float64
+		count int
+	}
+
+	var taken map[int]bool
+	if q.Student != "" {
+		taken = make(map[int]bool)
+		readShard(s.shard(q.Student), func(shard *MemoryStore) {
+			for number := range shard.scores[q.Student] {
+				taken[number] = true
+			}
+		})
+	}
+
+	totals := make(map[int]*examTotals)
+	s.eachShard(func(shard *MemoryStore) {
+		for number, index := range shard.exams {
+			if q.Student != "" && !taken[number] {
+				continue
+			}
+			t := totals[number]
+			if t == nil {
+				t = &examTotals{}
+				totals[number] = t
+			}
+			t.sum += index.sum
+			t.count += index.len()
+		}
+	})
+
+	summaries := make([]models.ExamSummary, 0, len(totals))
+	for number, t := range totals {
+		average := t.sum / float64(t.count)
+		if !matchesAverage(average, q.MinAverage, q.MaxAverage) {
+			continue
+		}
+		summaries = append(summaries, models.ExamSummary{
+			Number:       number,
+			AverageScore: average,
+			StudentCount: t.count,
+		})
+	}
+
+	page, next := paginate(summaries, examPosition(q.SortBy), q.Descending, after, q.Limit)
+	return &ExamPage{
+		Exams:      page,
+		Total:      len(summaries),
+		NextCursor: next,
+	}, nil
+}
+
+// Stats returns the number of students, exams and scores stored across
+// every shard
+func (s *ShardedStore) Stats() Stats {
+	var stats Stats
+	exams := make(map[int]bool)
+	s.eachShard(func(shard *MemoryStore) {
+		stats.Students += len(shard.scores)
+		stats.Scores += shard.count
+		stats.Duplicates += shard.duplicates
+		for _, number := range shard.numbers {
+			exams[number] = true
+		}
+	})
+	stats.Exams = len(exams)
+	return stats
+}
+
+// Snapshot returns every score received across every shard, in the order
+// it was received
+func (s *ShardedStore) Snapshot() *Snapshot {
+	lists := make([][]models.ScoreRecord, 0, len(s.shards))
+	var total int
+	s.eachShard(func(shard *MemoryStore) {
+		records := shard.records()
+		lists = append(lists, records)
+		total += len(records)
+	})
+
+	snap := &Snapshot{CreatedAt: time.Now(), Records: make([]models.ScoreRecord, 0, total)}
+	for _, record := range mergeSorted(lists, func(a, b models.ScoreRecord) bool {
+		return a.ReceivedAt.Before(b.ReceivedAt)
+	}) {
+		snap.Records = append(snap.Records, record)
+	}
+	return snap
+}
+
+// Restore loads snap's scores into the shards holding their students. It
+// write-locks every shard, in order, so each shard is restored whole,
+// though a query reading the shards alongside may see some of them before
+// the restore and others after.
+func (s *ShardedStore) Restore(snap *Snapshot, mode RestoreMode) (RestoreResult, error) {
+	if !mode.valid() {
+		return RestoreResult{}, fmt.Errorf("%w %q", ErrUnknownRestoreMode, mode)
+	}
+	// Before any shard is touched, so a bad record can't leave some
+	// shards restored and others not
+	if err := checkRecords(snap.Records, s.restoreRules); err != nil {
+		return RestoreResult{}, err
+	}
+
+	incoming := make(map[*MemoryStore][]models.ScoreRecord, len(s.shards))
+	for _, record := range snap.Records {
+		shard := s.shard(record.StudentID)
+		incoming[shard] = append(incoming[shard], record)
+	}
+
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+	}
+	defer func() {
+		for _, shard := range s.shards {
+			shard.mu.Unlock()
+		}
+	}()
+
+	result := RestoreResult{Mode: mode}
+	for _, shard := range s.shards {
+		restored, err := shard.restore(incoming[shard], mode)
+		if err != nil {
+			return RestoreResult{}, err
+		}
+		result.Restored += restored.Restored
+		result.Skipped += restored.Skipped
+		result.Scores += restored.Scores
+	}
+	return result, nil
+}
+
+// mergeSorted yields the elements of lists, each already sorted by less,
+// as one sorted sequence, along with the index of the list each came from
+func mergeSorted[T any](lists [][]T, less func(a, b T) bool) iter.Seq2[int, T] {
+	type head struct {
+		list      int
+		remaining []T
+	}
+
+	return func(yield func(int, T) bool) {
+		// heads is a heap of the lists not yet used up, ordered by their
+		// next element
+		heads := make([]head, 0, len(lists))
+		for i, list := range lists {
+			if len(list) > 0 {
+				heads = append(heads, head{list: i, remaining: list})
+			}
+		}
+
+		down := func(i int) {
+			for {
+				child := 2*i + 1
+				if child >= len(heads) {
+					return
+				}
+				if child+1 < len(heads) && less(heads[child+1].remaining[0], heads[child].remaining[0]) {
+					child++
+				}
+				if !less(heads[child].remaining[0], heads[i].remaining[0]) {
+					return
+				}
+				heads[i], heads[child] = heads[child], heads[i]
+				i = child
+			}
+		}
+		for i := len(heads)/2 - 1; i >= 0; i-- {
+			down(i)
+		}
+
+		for len(heads) > 0 {
+			if !yield(heads[0].list, heads[0].remaining[0]) {
+				return
+			}
+			if heads[0].remaining = heads[0].remaining[1:]; len(heads[0].remaining) == 0 {
+				heads[0] = heads[len(heads)-1]
+				heads = heads[:len(heads)-1]
+			}
+			down(0)
+		}
+	}
+}
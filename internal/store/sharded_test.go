@@ -0,0 +1,232 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// canonical reduces v to its JSON form with receive times and cursors
+// dropped and floats rounded, so stores that summed the same scores in a
+// different order compare equal
+func canonical(v interface{}) interface{} {
+	data, _ := json.Marshal(v)
+	var decoded interface{}
+	json.Unmarshal(data, &decoded)
+	return normalize(decoded)
+}
+
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		delete(v, "timestamp")
+		delete(v, "receivedAt")
+		delete(v, "NextCursor")
+		for key, value := range v {
+			v[key] = normalize(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalize(value)
+		}
+	case float64:
+		return math.Round(v*1e9) / 1e9
+	}
+	return v
+}
+
+// storeAnswers asks s every query about the students and exams ops touch
+func storeAnswers(s Store) map[string]interface{} {
+	answers := map[string]interface{}{
+		"students":    s.GetAllStudents(),
+		"exams":       s.GetAllExams(),
+		"leaderboard": s.GetLeaderboard(0, 0),
+		"top3":        s.GetLeaderboard(3, 2),
+		"stats":       s.Stats(),
+	}
+
+	minAverage := 0.5
+	studentPage, _ := s.QueryStudents(StudentQuery{MinAverage: &minAverage, SortBy: SortStudentAverage, Descending: true, Limit: 4})
+	answers["queryStudents"] = studentPage
+	examPage, _ := s.QueryExams(ExamQuery{SortBy: SortExamAverage})
+	answers["queryExams"] = examPage
+
+	for i := 0; i < 8; i++ {
+		studentID := string(rune('a' + i))
+		student, err := s.GetStudent(studentID)
+		answers["student "+studentID] = []interface{}{student, fmt.Sprint(err)}
+		page, _ := s.QueryExams(ExamQuery{Student: studentID})
+		answers["queryExams "+studentID] = page
+		history, err := s.GetScoreHistory(studentID, 1)
+		answers["history "+studentID] = []interface{}{history, fmt.Sprint(err)}
+	}
+	for number := 1; number <= 6; number++ {
+		exam, err := s.GetExam(number)
+		answers[fmt.Sprint("exam ", number)] = []interface{}{exam, fmt.Sprint(err)}
+		stats, err := s.GetExamStats(number, ExamStatsQuery{})
+		answers[fmt.Sprint("examStats ", number)] = []interface{}{stats, fmt.Sprint(err)}
+		leaderboard, err := s.GetExamLeaderboard(number, 3)
+		answers[fmt.Sprint("examLeaderboard ", number)] = []interface{}{leaderboard, fmt.Sprint(err)}
+	}
+	return canonical(answers).(map[string]interface{})
+}
+
+func TestShardedStore_MatchesMemoryStore(t *testing.T) {
+	// PolicyAverage is left out: averaging attempts leaves scores that
+	// don't add up exactly, so equal averages can tie in a different order
+	policies := []ScorePolicy{PolicyLatest, PolicyBest, PolicyFirst}
+
+	property := func(ops []scoreOp, policy uint8, shards uint8) bool {
+		opts := []Option{WithScorePolicy(policies[int(policy)%len(policies)])}
+		memory := NewMemoryStore(opts...)
+		sharded := NewShardedStore(1+int(shards)%6, opts...)
+		for _, op := range ops {
+			memory.AddScore(op.event())
+			sharded.AddScore(op.event())
+		}
+
+		expected, got := storeAnswers(memory), storeAnswers(sharded)
+		for query, answer := range expected {
+			if !reflect.DeepEqual(answer, got[query]) {
+				t.Logf("%s with %d shards after %v: expected %v, got %v", query, len(sharded.shards), ops, answer, got[query])
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShardedStore_Dedup(t *testing.T) {
+	s := NewShardedStore(4, WithDedup(time.Minute, 0))
+
+	var published int
+	s.OnScore(func(record models.ScoreRecord) { published++ })
+
+	for i := 0; i < 2; i++ {
+		for _, id := range []string{"1", "2", "3"} {
+			s.AddScore(models.ScoreEvent{ID: id, Source: "sse", Exam: 1, StudentID: "student" + id, Score: 0.5})
+		}
+	}
+
+	if stats := s.Stats(); stats.Scores != 3 || stats.Duplicates != 3 || stats.Students != 3 || stats.Exams != 1 {
+		t.Errorf("Expected 3 scores, 3 duplicates, 3 students and 1 exam, got %+v", stats)
+	}
+	if published != 3 {
+		t.Errorf("Expected 3 published scores, got %d", published)
+	}
+}
+
+// Reads lock one shard at a time, so a reader may see a later write before
+// an earlier one on another shard, but never misses a write that finished
+// before it started, and each exam's results match its average
+func TestShardedStore_ReadsSeeCompletedWrites(t *testing.T) {
+	s := NewShardedStore(8)
+	const students = 2000
+	id := func(i int) string { return fmt.Sprintf("student-%05d", i) }
+
+	var written atomic.Bool
+	var completed atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer written.Store(true)
+		for i := 0; i < students; i++ {
+			s.AddScore(models.ScoreEvent{Exam: 1, StudentID: id(i), Score: float64(i%100) / 100})
+			completed.Store(int64(i + 1))
+		}
+	}()
+
+	errs := make(chan string, 4)
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !written.Load() {
+				done := int(completed.Load())
+				seen := make(map[string]bool)
+				for _, studentID := range s.GetAllStudents() {
+					seen[studentID] = true
+				}
+				for i := 0; i < done; i++ {
+					if !seen[id(i)] {
+						errs <- fmt.Sprintf("GetAllStudents missed %s, written before the read", id(i))
+						return
+					}
+				}
+
+				exam, err := s.GetExam(1)
+				if err != nil {
+					continue
+				}
+				var total float64
+				for i, result := range exam.Results {
+					if i > 0 && result.StudentID <= exam.Results[i-1].StudentID {
+						errs <- fmt.Sprintf("GetExam saw %s after %s", result.StudentID, exam.Results[i-1].StudentID)
+						return
+					}
+					total += result.Score
+				}
+				if average := total / float64(len(exam.Results)); math.Abs(average-exam.AverageScore) > 1e-9 {
+					errs <- fmt.Sprintf("GetExam average %v does not match its %d results", exam.AverageScore, len(exam.Results))
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if stats := s.Stats(); stats.Students != students {
+		t.Errorf("Expected %d students, got %d", students, stats.Students)
+	}
+}
+
+func TestShardedStore_ConcurrentAccess(t *testing.T) {
+	s := NewShardedStore(4, WithDedup(time.Minute, 0))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				s.AddScore(models.ScoreEvent{
+					ID:        fmt.Sprintf("%d-%d", w, i),
+					Exam:      1 + i%5,
+					StudentID: fmt.Sprintf("student%d", (w*200+i)%50),
+					Score:     float64(i%10) / 10,
+				})
+			}
+		}()
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				storeAnswers(s)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := s.Stats(); stats.Scores != 1600 || stats.Students != 50 || stats.Exams != 5 {
+		t.Errorf("Expected 1600 scores from 50 students on 5 exams, got %+v", stats)
+	}
+}
@@ -0,0 +1,579 @@
+package store
+
+import (
+	"bufio"
+	"channel-test/internal/validation"
+	"channel-test/pkg/models"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// archiveVersion is the version of the snapshot archive formats
+	archiveVersion = 1
+
+	// archiveMagic starts every binary archive
+	archiveMagic = "SCORESNP"
+
+	// maxArchiveString guards against allocating garbage lengths from a
+	// corrupt binary archive
+	maxArchiveString = 64 << 10
+)
+
+var (
+	// ErrInvalidSnapshot is returned when an archive is malformed, fails
+	// its checksum or has an unsupported version
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+	// ErrUnknownFormat is returned for an unrecognised snapshot format name
+	ErrUnknownFormat = errors.New("unknown snapshot format")
+
+	// ErrUnknownRestoreMode is returned for an unrecognised restore mode name
+	ErrUnknownRestoreMode = errors.New("unknown restore mode")
+)
+
+// Snapshot is every score a store held at one instant, oldest first
+type Snapshot struct {
+	CreatedAt time.Time
+	Records   []models.ScoreRecord
+}
+
+// SnapshotFormat is an encoding of a snapshot archive
+type SnapshotFormat string
+
+const (
+	// FormatJSON is a JSON document, readable with standard tools
+	FormatJSON SnapshotFormat = "json"
+
+	// FormatBinary is a compact varint encoding, a fraction of the size
+	FormatBinary SnapshotFormat = "binary"
+)
+
+// ParseSnapshotFormat parses a format name, defaulting to FormatJSON
+func ParseSnapshotFormat(s string) (SnapshotFormat, error) {
+	switch SnapshotFormat(s) {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatBinary:
+		return FormatBinary, nil
+	}
+	return "", fmt.Errorf("%w %q: must be json or binary", ErrUnknownFormat, s)
+}
+
+// RestoreMode decides what happens to a store's scores on restore
+type RestoreMode string
+
+const (
+	// RestoreMerge adds the snapshot's scores to those already held,
+	// skipping any the store already has
+	RestoreMerge RestoreMode = "merge"
+
+	// RestoreReplace drops every score held before loading the snapshot
+	RestoreReplace RestoreMode = "replace"
+)
+
+// ParseRestoreMode parses a restore mode name, defaulting to RestoreMerge
+func ParseRestoreMode(s string) (RestoreMode, error) {
+	switch RestoreMode(s) {
+	case "", RestoreMerge:
+		return RestoreMerge, nil
+	case RestoreReplace:
+		return RestoreReplace, nil
+	}
+	return "", fmt.Errorf("%w %q: must be merge or replace", ErrUnknownRestoreMode, s)
+}
+
+func (m RestoreMode) valid() bool {
+	return m == RestoreMerge || m == RestoreReplace
+}
+
+// RestoreResult summarizes a restore
+type RestoreResult struct {
+	Mode     RestoreMode `json:"mode"`
+	Restored int         `json:"restored"` // snapshot scores added to the store
+	Skipped  int         `json:"skipped"`  // snapshot scores the store already held
+	Scores   int         `json:"scores"`   // scores held after the restore
+}
+
+// Snapshotter is implemented by stores that can export every score they
+// hold and load such an export back
+type Snapshotter interface {
+	// Snapshot returns every score held, as of one instant
+	Snapshot() *Snapshot
+
+	// Restore loads a snapshot's scores. Restored scores are not passed
+	// to OnScore hooks.
+	Restore(snap *Snapshot, mode RestoreMode) (RestoreResult, error)
+}
+
+// recordKey identifies a history entry, so a merge can skip the scores a
+// store already holds
+type recordKey struct {
+	exam       int
+	studentID  string
+	score      float64
+	receivedAt int64
+	source     string
+	eventID    string
+}
+
+func newRecordKey(record models.ScoreRecord) recordKey {
+	return recordKey{
+		exam:       record.Exam,
+		studentID:  record.StudentID,
+		score:      record.Score,
+		receivedAt: record.ReceivedAt.UnixNano(),
+		source:     record.Source,
+		eventID:    record.EventID,
+	}
+}
+
+// mergeRecords returns the records a store holding current should be
+// rebuilt from to restore incoming in mode, oldest first, along with how
+// many of incoming were added and skipped
+func mergeRecords(current, incoming []models.ScoreRecord, mode RestoreMode) ([]models.ScoreRecord, int, int) {
+	if mode == RestoreReplace {
+		current = nil
+	}
+
+	held := make(map[recordKey]bool, len(current)+len(incoming))
+	for _, record := range current {
+		held[newRecordKey(record)] = true
+	}
+
+	merged := append(make([]models.ScoreRecord, 0, len(current)+len(incoming)), current...)
+	var skipped int
+	for _, record := range incoming {
+		key := newRecordKey(record)
+		if held[key] {
+			skipped++
+			continue
+		}
+		held[key] = true
+		merged = append(merged, record)
+	}
+
+	// Stable so attempts on the same exam keep their order on timestamp ties
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].ReceivedAt.Before(merged[j].ReceivedAt)
+	})
+	return merged, len(incoming) - skipped, skipped
+}
+
+// Encode writes snap to w as a checksummed archive in format
+func (snap *Snapshot) Encode(w io.Writer, format SnapshotFormat) error {
+	writer := bufio.NewWriter(w)
+
+	var err error
+	switch format {
+	case FormatJSON:
+		err = snap.encodeJSON(writer)
+	case FormatBinary:
+		err = snap.encodeBinary(writer)
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// DecodeSnapshot reads an archive in either format, telling them apart by
+// the binary archive's leading magic, and verifies its checksum and that
+// every record has a student ID and a finite score
+func DecodeSnapshot(r io.Reader) (*Snapshot, error) {
+	reader := bufio.NewReader(r)
+
+	var snap *Snapshot
+	magic, err := reader.Peek(len(archiveMagic))
+	if err == nil && string(magic) == archiveMagic {
+		snap, err = decodeBinary(reader)
+	} else {
+		snap, err = decodeJSON(reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkRecords(snap.Records, nil); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// checkRecords applies the checks ingest makes to records about to be
+// restored: a student ID, a finite score and, when validator is set, its
+// rules. A checksum only proves an archive wasn't damaged, not that what
+// was written is valid.
+func checkRecords(records []models.ScoreRecord, validator validation.Validator) error {
+	for i, record := range records {
+		if record.StudentID == "" {
+			return fmt.Errorf("%w: record %d: missing student ID", ErrInvalidSnapshot, i)
+		}
+		if math.IsNaN(record.Score) || math.IsInf(record.Score, 0) {
+			return fmt.Errorf("%w: record %d: score %v is not a number", ErrInvalidSnapshot, i, record.Score)
+		}
+		if validator == nil {
+			continue
+		}
+		event := models.ScoreEvent{Exam: record.Exam, StudentID: record.StudentID, Score: record.Score}
+		if violations := validator.Validate(event); len(violations) > 0 {
+			return fmt.Errorf("%w: record %d: %s", ErrInvalidSnapshot, i, validation.Summary(violations))
+		}
+	}
+	return nil
+}
+
+// archiveHeader opens a JSON archive. Records follow, then archiveTrailer.
+type archiveHeader struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Count     int       `json:"count"`
+}
+
+// archiveTrailer closes a JSON archive with the CRC32 of every record, as
+// written, in order
+type archiveTrailer struct {
+	Checksum string `json:"checksum"`
+}
+
+// encodeJSON writes the archive as one JSON object. Records are written
+// one at a time so a large snapshot is never held encoded in memory.
+func (snap *Snapshot) encodeJSON(w io.Writer) error {
+	header, err := json.Marshal(archiveHeader{Version: archiveVersion, CreatedAt: snap.CreatedAt, Count: len(snap.Records)})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	// Open the header object and add the records array to it
+	if _, err := fmt.Fprintf(w, "%s,\"records\":[", header[:len(header)-1]); err != nil {
+		return err
+	}
+
+	checksum := crc32.NewIEEE()
+	separator := "\n"
+	for _, record := range snap.Records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode snapshot record: %w", err)
+		}
+		checksum.Write(data)
+
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		separator = ",\n"
+	}
+
+	_, err = fmt.Fprintf(w, "\n],\"checksum\":%q}\n", formatChecksum(checksum))
+	return err
+}
+
+func formatChecksum(checksum hash.Hash32) string {
+	return fmt.Sprintf("%08x", checksum.Sum32())
+}
+
+// decodeJSON reads a JSON archive field by field, so its records are
+// checksummed exactly as they were written
+func decodeJSON(r io.Reader) (*Snapshot, error) {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	var header archiveHeader
+	var trailer archiveTrailer
+	var records []models.ScoreRecord
+	var sawRecords bool
+	checksum := crc32.NewIEEE()
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, invalidSnapshot(err)
+		}
+
+		switch token {
+		case "version":
+			err = decoder.Decode(&header.Version)
+			if err == nil && header.Version != archiveVersion {
+				return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, header.Version)
+			}
+		case "createdAt":
+			err = decoder.Decode(&header.CreatedAt)
+		case "count":
+			err = decoder.Decode(&header.Count)
+		case "checksum":
+			err = decoder.Decode(&trailer.Checksum)
+		case "records":
+			sawRecords = true
+			records, err = decodeJSONRecords(decoder, checksum)
+		default:
+			var skip json.RawMessage
+			err = decoder.Decode(&skip)
+		}
+		if err != nil {
+			return nil, invalidSnapshot(err)
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case header.Version == 0:
+		return nil, fmt.Errorf("%w: missing version", ErrInvalidSnapshot)
+	case !sawRecords:
+		return nil, fmt.Errorf("%w: missing records", ErrInvalidSnapshot)
+	case len(records) != header.Count:
+		return nil, fmt.Errorf("%w: expected %d records, got %d", ErrInvalidSnapshot, header.Count, len(records))
+	case trailer.Checksum != formatChecksum(checksum):
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+
+	return &Snapshot{CreatedAt: header.CreatedAt, Records: records}, nil
+}
+
+// decodeJSONRecords reads the records array, adding each record's raw
+// bytes to checksum
+func decodeJSONRecords(decoder *json.Decoder, checksum hash.Hash32) ([]models.ScoreRecord, error) {
+	if err := expectDelim(decoder, '['); err != nil {
+		return nil, err
+	}
+
+	records := []models.ScoreRecord{}
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		checksum.Write(raw)
+
+		var record models.ScoreRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, expectDelim(decoder, ']')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return invalidSnapshot(err)
+	}
+	if token != delim {
+		return fmt.Errorf("%w: expected %v, got %v", ErrInvalidSnapshot, delim, token)
+	}
+	return nil
+}
+
+func invalidSnapshot(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+}
+
+// encodeBinary writes the archive as the magic, then a checksummed body:
+//
+//	version    uvarint
+//	createdAt  varint seconds, uvarint nanoseconds
+//	count      uvarint
+//	records    count of: exam varint, studentId string, score 8 bytes,
+//	           receivedAt varint seconds and uvarint nanoseconds,
+//	           source string, eventId string
+//	checksum   4 bytes, the CRC32 of the body before it
+//
+// Strings are a uvarint length and their bytes, and fixed-size values big
+// endian. Times are stored in UTC.
+func (snap *Snapshot) encodeBinary(w io.Writer) error {
+	if _, err := io.WriteString(w, archiveMagic); err != nil {
+		return err
+	}
+
+	checksum := crc32.NewIEEE()
+	body := &binaryWriter{w: io.MultiWriter(w, checksum)}
+	body.uvarint(archiveVersion)
+	body.time(snap.CreatedAt)
+	body.uvarint(uint64(len(snap.Records)))
+	for _, record := range snap.Records {
+		body.varint(int64(record.Exam))
+		body.string(record.StudentID)
+		body.uint64(math.Float64bits(record.Score))
+		body.time(record.ReceivedAt)
+		body.string(record.Source)
+		body.string(record.EventID)
+	}
+	if body.err != nil {
+		return body.err
+	}
+
+	_, err := w.Write(binary.BigEndian.AppendUint32(nil, checksum.Sum32()))
+	return err
+}
+
+// binaryWriter writes the fields of a binary archive, keeping the first
+// error so each write need not be checked
+type binaryWriter struct {
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+func (b *binaryWriter) write(data []byte) {
+	if b.err == nil {
+		_, b.err = b.w.Write(data)
+	}
+}
+
+func (b *binaryWriter) uvarint(v uint64) {
+	b.buf = binary.AppendUvarint(b.buf[:0], v)
+	b.write(b.buf)
+}
+
+func (b *binaryWriter) varint(v int64) {
+	b.buf = binary.AppendVarint(b.buf[:0], v)
+	b.write(b.buf)
+}
+
+func (b *binaryWriter) uint64(v uint64) {
+	b.buf = binary.BigEndian.AppendUint64(b.buf[:0], v)
+	b.write(b.buf)
+}
+
+func (b *binaryWriter) string(s string) {
+	b.uvarint(uint64(len(s)))
+	b.buf = append(b.buf[:0], s...)
+	b.write(b.buf)
+}
+
+func (b *binaryWriter) time(t time.Time) {
+	b.varint(t.Unix())
+	b.uvarint(uint64(t.Nanosecond()))
+}
+
+// decodeBinary reads a binary archive, whose magic r has not yet consumed
+func decodeBinary(r *bufio.Reader) (*Snapshot, error) {
+	if _, err := r.Discard(len(archiveMagic)); err != nil {
+		return nil, invalidSnapshot(err)
+	}
+
+	body := &binaryReader{r: r, checksum: crc32.NewIEEE()}
+
+	if version := body.uvarint(); body.err == nil && version != archiveVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, version)
+	}
+	snap := &Snapshot{CreatedAt: body.time()}
+	count := body.uvarint()
+
+	// A corrupt count must not allocate, so grow as records are read
+	snap.Records = make([]models.ScoreRecord, 0, min(count, 1<<16))
+	for i := uint64(0); i < count && body.err == nil; i++ {
+		snap.Records = append(snap.Records, models.ScoreRecord{
+			Exam:       int(body.varint()),
+			StudentID:  body.string(),
+			Score:      math.Float64frombits(body.uint64()),
+			ReceivedAt: body.time(),
+			Source:     body.string(),
+			EventID:    body.string(),
+		})
+	}
+	if body.err != nil {
+		return nil, invalidSnapshot(body.err)
+	}
+
+	var trailer [4]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		return nil, fmt.Errorf("%w: missing checksum", ErrInvalidSnapshot)
+	}
+	if binary.BigEndian.Uint32(trailer[:]) != body.checksum.Sum32() {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+	return snap, nil
+}
+
+// binaryReader reads the fields of a binary archive, adding every byte it
+// consumes to checksum. It keeps the first error so each read need not be
+// checked.
+type binaryReader struct {
+	r        *bufio.Reader
+	checksum hash.Hash32
+	err      error
+}
+
+// ReadByte implements io.ByteReader for the varint decoders
+func (b *binaryReader) ReadByte() (byte, error) {
+	c, err := b.r.ReadByte()
+	if err == nil {
+		b.checksum.Write([]byte{c})
+	}
+	return c, err
+}
+
+func (b *binaryReader) uvarint() uint64 {
+	if b.err != nil {
+		return 0
+	}
+	var v uint64
+	v, b.err = binary.ReadUvarint(b)
+	return v
+}
+
+func (b *binaryReader) varint() int64 {
+	if b.err != nil {
+		return 0
+	}
+	var v int64
+	v, b.err = binary.ReadVarint(b)
+	return v
+}
+
+func (b *binaryReader) uint64() uint64 {
+	var buf [8]byte
+	b.read(buf[:])
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+func (b *binaryReader) string() string {
+	size := b.uvarint()
+	if b.err != nil {
+		return ""
+	}
+	if size > maxArchiveString {
+		b.err = fmt.Errorf("string length %d", size)
+		return ""
+	}
+	buf := make([]byte, size)
+	b.read(buf)
+	return string(buf)
+}
+
+func (b *binaryReader) time() time.Time {
+	seconds := b.varint()
+	nanos := b.uvarint()
+	if b.err == nil && nanos >= uint64(time.Second) {
+		b.err = fmt.Errorf("nanoseconds %d out of range", nanos)
+	}
+	return time.Unix(seconds, int64(nanos)).UTC()
+}
+
+func (b *binaryReader) read(buf []byte) {
+	if b.err != nil {
+		return
+	}
+	if _, b.err = io.ReadFull(b.r, buf); b.err == io.EOF {
+		b.err = io.ErrUnexpectedEOF
+	}
+	b.checksum.Write(buf)
+}
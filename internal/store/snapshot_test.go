@@ -0,0 +1,332 @@
+package store
+
+import (
+	"bytes"
+	"channel-test/internal/validation"
+	"channel-test/pkg/models"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func snapshotRecords() []models.ScoreRecord {
+	at := time.Date(2024, 3, 1, 9, 30, 0, 123456789, time.FixedZone("CET", 3600))
+	return []models.ScoreRecord{
+		{Exam: 1, StudentID: "alice", Score: 0.8, ReceivedAt: at, Source: "sse", EventID: "1"},
+		{Exam: -2, StudentID: "bøb \"quoted\"", Score: 1.0 / 3, ReceivedAt: at.Add(time.Second)},
+		{Exam: 1, StudentID: "alice", Score: 0, ReceivedAt: at.Add(2 * time.Second), Source: "api"},
+	}
+}
+
+// sameRecords reports whether a and b hold the same records, comparing
+// times by instant
+func sameRecords(a, b []models.ScoreRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if newRecordKey(a[i]) != newRecordKey(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	for _, format := range []SnapshotFormat{FormatJSON, FormatBinary} {
+		for _, records := range [][]models.ScoreRecord{snapshotRecords(), {}} {
+			snap := &Snapshot{CreatedAt: time.Now(), Records: records}
+
+			var buf bytes.Buffer
+			if err := snap.Encode(&buf, format); err != nil {
+				t.Fatalf("%s: Encode failed: %v", format, err)
+			}
+
+			decoded, err := DecodeSnapshot(&buf)
+			if err != nil {
+				t.Fatalf("%s: DecodeSnapshot failed: %v", format, err)
+			}
+			if !decoded.CreatedAt.Equal(snap.CreatedAt) {
+				t.Errorf("%s: Expected created at %v, got %v", format, snap.CreatedAt, decoded.CreatedAt)
+			}
+			if !sameRecords(decoded.Records, records) {
+				t.Errorf("%s: Expected %v, got %v", format, records, decoded.Records)
+			}
+		}
+	}
+}
+
+func TestSnapshot_BinaryIsSmaller(t *testing.T) {
+	snap := &Snapshot{CreatedAt: time.Now()}
+	for i := 0; i < 100; i++ {
+		snap.Records = append(snap.Records, snapshotRecords()...)
+	}
+
+	var jsonBuf, binaryBuf bytes.Buffer
+	snap.Encode(&jsonBuf, FormatJSON)
+	snap.Encode(&binaryBuf, FormatBinary)
+	if binaryBuf.Len()*2 > jsonBuf.Len() {
+		t.Errorf("Expected binary archive under half the JSON's %d bytes, got %d", jsonBuf.Len(), binaryBuf.Len())
+	}
+}
+
+func TestDecodeSnapshot_Invalid(t *testing.T) {
+	snap := &Snapshot{CreatedAt: time.Now(), Records: snapshotRecords()}
+	var jsonBuf, binaryBuf bytes.Buffer
+	snap.Encode(&jsonBuf, FormatJSON)
+	snap.Encode(&binaryBuf, FormatBinary)
+	jsonArchive, binaryArchive := jsonBuf.String(), binaryBuf.String()
+
+	tests := []struct {
+		name    string
+		archive string
+	}{
+		{"empty", ""},
+		{"not json", "scores"},
+		{"json altered score", strings.Replace(jsonArchive, `"score":0.8`, `"score":0.9`, 1)},
+		{"json dropped record", strings.Replace(jsonArchive, `"count":3`, `"count":2`, 1)},
+		{"json truncated", jsonArchive[:len(jsonArchive)/2]},
+		{"json wrong version", strings.Replace(jsonArchive, `"version":1`, `"version":2`, 1)},
+		{"json no records", `{"version":1,"count":0,"checksum":"00000000"}`},
+		{"binary altered byte", binaryArchive[:40] + "x" + binaryArchive[41:]},
+		{"binary truncated", binaryArchive[:len(binaryArchive)-2]},
+		{"binary no checksum", binaryArchive[:len(binaryArchive)-4]},
+		{"binary wrong version", archiveMagic + "\x02" + binaryArchive[len(archiveMagic)+1:]},
+	}
+
+	for _, tt := range tests {
+		if _, err := DecodeSnapshot(strings.NewReader(tt.archive)); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("%s: Expected ErrInvalidSnapshot, got %v", tt.name, err)
+		}
+	}
+}
+
+// Archives with intact checksums are still refused if a record could not
+// have been ingested
+func TestDecodeSnapshot_InvalidRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		record models.ScoreRecord
+		format SnapshotFormat
+	}{
+		{"json missing student", models.ScoreRecord{Exam: 1, Score: 0.5}, FormatJSON},
+		{"binary missing student", models.ScoreRecord{Exam: 1, Score: 0.5}, FormatBinary},
+		{"binary NaN score", models.ScoreRecord{Exam: 1, StudentID: "alice", Score: math.NaN()}, FormatBinary},
+		{"binary infinite score", models.ScoreRecord{Exam: 1, StudentID: "alice", Score: math.Inf(1)}, FormatBinary},
+	}
+
+	for _, tt := range tests {
+		snap := &Snapshot{CreatedAt: time.Now(), Records: append(snapshotRecords(), tt.record)}
+		var buf bytes.Buffer
+		if err := snap.Encode(&buf, tt.format); err != nil {
+			t.Fatalf("%s: Encode failed: %v", tt.name, err)
+		}
+
+		if _, err := DecodeSnapshot(&buf); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("%s: Expected ErrInvalidSnapshot, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestParseSnapshotFormat(t *testing.T) {
+	if format, err := ParseSnapshotFormat(""); err != nil || format != FormatJSON {
+		t.Errorf("Expected json by default, got %q, %v", format, err)
+	}
+	if format, err := ParseSnapshotFormat("binary"); err != nil || format != FormatBinary {
+		t.Errorf("Expected binary, got %q, %v", format, err)
+	}
+	if _, err := ParseSnapshotFormat("xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+
+	if mode, err := ParseRestoreMode(""); err != nil || mode != RestoreMerge {
+		t.Errorf("Expected merge by default, got %q, %v", mode, err)
+	}
+	if _, err := ParseRestoreMode("append"); !errors.Is(err, ErrUnknownRestoreMode) {
+		t.Errorf("Expected ErrUnknownRestoreMode, got %v", err)
+	}
+}
+
+type snapshotStore interface {
+	Store
+	Snapshotter
+}
+
+// snapshotStores are the stores that restore, each created empty
+var snapshotStores = []struct {
+	name     string
+	newStore func(t *testing.T) snapshotStore
+}{
+	{"memory", func(t *testing.T) snapshotStore {
+		return NewMemoryStore()
+	}},
+	{"sharded", func(t *testing.T) snapshotStore {
+		return NewShardedStore(4)
+	}},
+	{"file", func(t *testing.T) snapshotStore {
+		s, err := NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewFileStore failed: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}},
+}
+
+func TestStore_SnapshotRestore(t *testing.T) {
+	for _, tt := range snapshotStores {
+		source := tt.newStore(t)
+		for _, op := range []scoreOp{{0, 0, 8}, {1, 0, 4}, {0, 1, 2}, {0, 0, 6}, {2, 3, 0}} {
+			source.AddScore(op.event())
+		}
+		snap := source.Snapshot()
+		if len(snap.Records) != 5 {
+			t.Fatalf("%s: Expected 5 records, got %d", tt.name, len(snap.Records))
+		}
+
+		// Replace drops what the target held
+		target := tt.newStore(t)
+		target.AddScore(models.ScoreEvent{Exam: 9, StudentID: "zed", Score: 1})
+		result, err := target.Restore(snap, RestoreReplace)
+		if err != nil {
+			t.Fatalf("%s: Restore failed: %v", tt.name, err)
+		}
+		if result != (RestoreResult{Mode: RestoreReplace, Restored: 5, Scores: 5}) {
+			t.Errorf("%s: Expected 5 restored scores, got %+v", tt.name, result)
+		}
+		if expected, got := storeAnswers(source), storeAnswers(target); !reflect.DeepEqual(expected, got) {
+			t.Errorf("%s: Expected restored store to answer %v, got %v", tt.name, expected, got)
+		}
+		if !sameRecords(target.Snapshot().Records, snap.Records) {
+			t.Errorf("%s: Expected restored snapshot to match the original", tt.name)
+		}
+
+		// Merge keeps what the target held and skips what it already has
+		target.AddScore(models.ScoreEvent{Exam: 9, StudentID: "zed", Score: 1})
+		result, err = target.Restore(snap, RestoreMerge)
+		if err != nil {
+			t.Fatalf("%s: Restore failed: %v", tt.name, err)
+		}
+		if result != (RestoreResult{Mode: RestoreMerge, Skipped: 5, Scores: 6}) {
+			t.Errorf("%s: Expected 5 skipped of 6 scores, got %+v", tt.name, result)
+		}
+		if _, err := target.GetStudent("zed"); err != nil {
+			t.Errorf("%s: Expected merge to keep zed, got %v", tt.name, err)
+		}
+
+		if _, err := target.Restore(snap, "append"); !errors.Is(err, ErrUnknownRestoreMode) {
+			t.Errorf("%s: Expected ErrUnknownRestoreMode, got %v", tt.name, err)
+		}
+	}
+}
+
+// One invalid record rejects the whole snapshot, leaving the store as it was
+func TestStore_RestoreRejectsInvalidRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		record models.ScoreRecord
+	}{
+		{"missing student", models.ScoreRecord{Exam: 1, Score: 0.5}},
+		{"NaN score", models.ScoreRecord{Exam: 1, StudentID: "bob", Score: math.NaN()}},
+		{"out of range score", models.ScoreRecord{Exam: 1, StudentID: "bob", Score: 95}},
+	}
+
+	for _, st := range snapshotStores {
+		for _, tt := range tests {
+			s := st.newStore(t)
+			s.AddScore(models.ScoreEvent{Exam: 9, StudentID: "zed", Score: 1})
+
+			snap := &Snapshot{Records: []models.ScoreRecord{
+				{Exam: 1, StudentID: "alice", Score: 0.5, ReceivedAt: time.Now()},
+				tt.record,
+			}}
+			for _, mode := range []RestoreMode{RestoreMerge, RestoreReplace} {
+				if _, err := s.Restore(snap, mode); !errors.Is(err, ErrInvalidSnapshot) {
+					t.Errorf("%s %s %s: Expected ErrInvalidSnapshot, got %v", st.name, tt.name, mode, err)
+				}
+			}
+			if students := s.GetAllStudents(); len(students) != 1 || students[0] != "zed" {
+				t.Errorf("%s %s: Expected the store to still hold only zed, got %v", st.name, tt.name, students)
+			}
+		}
+	}
+}
+
+func TestStore_RestoreValidator(t *testing.T) {
+	percent := WithRestoreValidator(validation.ScoreScale{Default: validation.Scale{Min: 0, Max: 100}})
+	snap := &Snapshot{Records: []models.ScoreRecord{{Exam: 1, StudentID: "alice", Score: 95, ReceivedAt: time.Now()}}}
+
+	for _, s := range []snapshotStore{NewMemoryStore(percent), NewShardedStore(4, percent)} {
+		if result, err := s.Restore(snap, RestoreMerge); err != nil || result.Restored != 1 {
+			t.Errorf("Expected a score on a 0-100 scale to be restored, got %+v, %v", result, err)
+		}
+	}
+}
+
+// Merged attempts are ordered by when they were received, so the policy
+// picks the same attempt as if they had arrived in one store
+func TestStore_RestoreMergeInterleaves(t *testing.T) {
+	at := time.Now()
+	first := &Snapshot{Records: []models.ScoreRecord{
+		{Exam: 1, StudentID: "alice", Score: 0.5, ReceivedAt: at},
+		{Exam: 1, StudentID: "alice", Score: 0.9, ReceivedAt: at.Add(2 * time.Second)},
+	}}
+	second := &Snapshot{Records: []models.ScoreRecord{
+		{Exam: 1, StudentID: "alice", Score: 0.7, ReceivedAt: at.Add(time.Second)},
+	}}
+
+	for _, tt := range snapshotStores {
+		s := tt.newStore(t)
+		s.Restore(first, RestoreMerge)
+		s.Restore(second, RestoreMerge)
+
+		history, _ := s.GetScoreHistory("alice", 1)
+		var scores []float64
+		for _, record := range history {
+			scores = append(scores, record.Score)
+		}
+		if !reflect.DeepEqual(scores, []float64{0.5, 0.7, 0.9}) {
+			t.Errorf("%s: Expected history [0.5 0.7 0.9], got %v", tt.name, scores)
+		}
+		if student, _ := s.GetStudent("alice"); student.AverageScore != 0.9 {
+			t.Errorf("%s: Expected latest score 0.9, got %v", tt.name, student.AverageScore)
+		}
+	}
+}
+
+func TestFileStore_RestorePersists(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	addStudents(t, s, 3)
+
+	snap := &Snapshot{Records: snapshotRecords()}
+	if _, err := s.Restore(snap, RestoreReplace); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	crash(t, s)
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if !sameRecords(reopened.Snapshot().Records, snap.Records) {
+		t.Errorf("Expected reopened store to hold the restored records, got %v", reopened.Snapshot().Records)
+	}
+	if _, err := reopened.Restore(snap, RestoreMerge); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := reopened.Restore(snap, RestoreMerge); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	// DefaultBucketWidth is the histogram bucket width used when a query
+	// does not set one, for scores spanning at most 1. It grows with the
+	// span of wider scales, so a 0-100 exam gets buckets of 10.
+	DefaultBucketWidth = 0.1
+
+	// MaxHistogramBuckets caps how many buckets a bucket width may split
+	// an exam's scores into
+	MaxHistogramBuckets = 1000
+
+	// boundPrecision rounds histogram bounds so multiples of the bucket
+	// width do not drift (0.30000000000000004)
+	boundPrecision = 1e9
+)
+
+// DefaultPercentiles are reported when a query does not ask for any
+var DefaultPercentiles = []float64{10, 25, 50, 75, 90, 95, 99}
+
+// ErrInvalidStatsQuery is returned for percentiles or a bucket width an
+// exam's statistics can't be computed with
+var ErrInvalidStatsQuery = errors.New("invalid stats query")
+
+// ExamStatsQuery selects the percentiles and histogram returned with an
+// exam's statistics
+type ExamStatsQuery struct {
+	Percentiles []float64 // each between 0 and 100, DefaultPercentiles if empty
+	BucketWidth float64   // must be positive, DefaultBucketWidth if zero
+}
+
+// validate checks the query against the exam's scores, which decide how
+// fine a bucket width may be
+func (q ExamStatsQuery) validate(e *examScores) error {
+	for _, p := range q.Percentiles {
+		if math.IsNaN(p) || p < 0 || p > 100 {
+			return fmt.Errorf("%w: percentile %g is not between 0 and 100", ErrInvalidStatsQuery, p)
+		}
+	}
+
+	width := q.BucketWidth
+	if width == 0 {
+		return nil
+	}
+	if math.IsNaN(width) || math.IsInf(width, 0) || width < 0 {
+		return fmt.Errorf("%w: bucketWidth must be a positive number", ErrInvalidStatsQuery)
+	}
+	if bucketCount(e.value(0), e.value(e.len()-1), width) > MaxHistogramBuckets {
+		return fmt.Errorf("%w: bucketWidth %g splits scores from %g to %g into more than %d buckets",
+			ErrInvalidStatsQuery, width, e.value(0), e.value(e.len()-1), MaxHistogramBuckets)
+	}
+	return nil
+}
+
+// value returns the i-th lowest score
+func (e *examScores) value(i int) float64 {
+	return e.ranked.at(e.len() - 1 - i).key
+}
+
+// countBelow returns how many scores are lower than score
+func (e *examScores) countBelow(score float64) int {
+	return e.len() - e.ranked.countAtLeast(score)
+}
+
+// stats summarizes the distribution of scores
+func (e *examScores) stats(number int, q ExamStatsQuery) (*models.ExamStats, error) {
+	if err := q.validate(e); err != nil {
+		return nil, err
+	}
+
+	n := e.len()
+	mean := e.sum / float64(n)
+
+	// Rounding in the running updates can leave M2 a hair below zero
+	variance := math.Max(e.m2/float64(n), 0)
+
+	percentiles := q.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+	values := make(map[string]float64, len(percentiles))
+	for _, p := range percentiles {
+		values["p"+strconv.FormatFloat(p, 'f', -1, 64)] = e.percentile(p)
+	}
+
+	width := q.BucketWidth
+	if width <= 0 {
+		width = defaultBucketWidth(e.value(n-1) - e.value(0))
+	}
+
+	return &models.ExamStats{
+		Number:            number,
+		Count:             n,
+		Min:               e.value(0),
+		Max:               e.value(n - 1),
+		Mean:              mean,
+		Median:            e.percentile(50),
+		StandardDeviation: math.Sqrt(variance),
+		Percentiles:       values,
+		Histogram:         e.histogram(width),
+	}, nil
+}
+
+// defaultBucketWidth scales DefaultBucketWidth to scores spanning span
+func defaultBucketWidth(span float64) float64 {
+	return DefaultBucketWidth * math.Max(1, math.Ceil(span))
+}
+
+// percentile returns the p-th percentile, interpolating linearly between
+// the closest ranks
+func (e *examScores) percentile(p float64) float64 {
+	// Ranks outside the scores would index past either end
+	if math.IsNaN(p) || p <= 0 {
+		return e.value(0)
+	}
+	if p >= 100 {
+		return e.value(e.len() - 1)
+	}
+
+	rank := p / 100 * float64(e.len()-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return e.value(lower)
+	}
+	fraction := rank - float64(lower)
+	return e.value(lower) + fraction*(e.value(upper)-e.value(lower))
+}
+
+// histogram counts scores into buckets of the given width, from the bucket
+// holding the lowest score to the one holding the highest. Each bucket
+// includes its lower bound and excludes its upper bound. It returns nil
+// for a width that isn't positive and finite or would need more than
+// MaxHistogramBuckets buckets.
+func (e *examScores) histogram(width float64) []models.HistogramBucket {
+	min, max := e.value(0), e.value(e.len()-1)
+	if !(width > 0) || math.IsInf(width, 1) {
+		return nil
+	}
+	count := bucketCount(min, max, width)
+	if count > MaxHistogramBuckets {
+		return nil
+	}
+	first := bucketIndex(min, width)
+
+	buckets := make([]models.HistogramBucket, count)
+	for i := range buckets {
+		lower := roundBound((first + float64(i)) * width)
+		upper := roundBound((first + float64(i+1)) * width)
+
+		from := e.countBelow(lower)
+		to := e.countBelow(upper)
+		if i == 0 {
+			from = 0
+		}
+		if i == count-1 {
+			to = e.len()
+		}
+
+		buckets[i] = models.HistogramBucket{
+			Lower: lower,
+			Upper: upper,
+			Count: to - from,
+		}
+	}
+	return buckets
+}
+
+// bucketCount returns how many buckets of width scores from min to max
+// fall into, capped just past MaxHistogramBuckets. A width tiny enough
+// to overflow the bucket indexes counts as too many.
+func bucketCount(min, max, width float64) int {
+	count := bucketIndex(max, width) - bucketIndex(min, width) + 1
+	if !(count <= MaxHistogramBuckets) {
+		return MaxHistogramBuckets + 1
+	}
+	return int(count)
+}
+
+// bucketIndex returns which multiple of width v falls into, so that a
+// score of 0.3 lands in the 0.3 bucket rather than 0.2
+func bucketIndex(v, width float64) float64 {
+	return math.Floor(roundBound(v / width))
+}
+
+func roundBound(v float64) float64 {
+	return math.Round(v*boundPrecision) / boundPrecision
+}
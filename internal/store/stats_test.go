@@ -0,0 +1,190 @@
+package store
+
+import (
+	"channel-test/pkg/models"
+	"errors"
+	"math"
+	"testing"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMemoryStore_GetExamStats(t *testing.T) {
+	store := NewMemoryStore()
+
+	for i, score := range []float64{0.5, 0.9, 0.7, 0.6, 0.8} {
+		store.AddScore(models.ScoreEvent{Exam: 1, StudentID: string(rune('a' + i)), Score: score})
+	}
+
+	stats, err := store.GetExamStats(1, ExamStatsQuery{Percentiles: []float64{10, 50, 99.5}, BucketWidth: 0.25})
+	if err != nil {
+		t.Fatalf("GetExamStats failed: %v", err)
+	}
+
+	if stats.Count != 5 {
+		t.Errorf("Expected count 5, got %d", stats.Count)
+	}
+	if stats.Min != 0.5 || stats.Max != 0.9 {
+		t.Errorf("Expected min 0.5 and max 0.9, got %v and %v", stats.Min, stats.Max)
+	}
+	if !approxEqual(stats.Mean, 0.7) || !approxEqual(stats.Median, 0.7) {
+		t.Errorf("Expected mean and median 0.7, got %v and %v", stats.Mean, stats.Median)
+	}
+	if !approxEqual(stats.StandardDeviation, math.Sqrt(0.02)) {
+		t.Errorf("Expected standard deviation %v, got %v", math.Sqrt(0.02), stats.StandardDeviation)
+	}
+
+	expectedPercentiles := map[string]float64{"p10": 0.54, "p50": 0.7, "p99.5": 0.898}
+	for name, expected := range expectedPercentiles {
+		if got, ok := stats.Percentiles[name]; !ok || !approxEqual(got, expected) {
+			t.Errorf("Expected %s %v, got %v", name, expected, got)
+		}
+	}
+
+	// Buckets of width 0.25 from 0.5: [0.5, 0.75) and [0.75, 1)
+	expectedBuckets := []models.HistogramBucket{
+		{Lower: 0.5, Upper: 0.75, Count: 3},
+		{Lower: 0.75, Upper: 1, Count: 2},
+	}
+	if len(stats.Histogram) != len(expectedBuckets) {
+		t.Fatalf("Expected %d buckets, got %+v", len(expectedBuckets), stats.Histogram)
+	}
+	for i, expected := range expectedBuckets {
+		if stats.Histogram[i] != expected {
+			t.Errorf("Bucket %d: Expected %+v, got %+v", i, expected, stats.Histogram[i])
+		}
+	}
+}
+
+func TestMemoryStore_GetExamStats_Defaults(t *testing.T) {
+	store := NewMemoryStore()
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.3})
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student2", Score: 1.0})
+
+	stats, err := store.GetExamStats(1, ExamStatsQuery{})
+	if err != nil {
+		t.Fatalf("GetExamStats failed: %v", err)
+	}
+
+	if len(stats.Percentiles) != len(DefaultPercentiles) {
+		t.Errorf("Expected %d percentiles, got %d", len(DefaultPercentiles), len(stats.Percentiles))
+	}
+
+	// 0.3 up to a bucket holding exactly 1.0
+	if len(stats.Histogram) != 8 {
+		t.Fatalf("Expected 8 buckets, got %+v", stats.Histogram)
+	}
+	if first := stats.Histogram[0]; first.Lower != 0.3 || first.Count != 1 {
+		t.Errorf("Expected first bucket from 0.3 with 1 score, got %+v", first)
+	}
+	if last := stats.Histogram[7]; last.Lower != 1 || last.Count != 1 {
+		t.Errorf("Expected last bucket from 1 with 1 score, got %+v", last)
+	}
+
+	if _, err := store.GetExamStats(2, ExamStatsQuery{}); err != ErrExamNotFound {
+		t.Errorf("Expected ErrExamNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStore_GetExamStats_InvalidQuery(t *testing.T) {
+	store := NewMemoryStore()
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.2})
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student2", Score: 0.8})
+
+	tests := []struct {
+		name  string
+		query ExamStatsQuery
+	}{
+		{"NaN percentile", ExamStatsQuery{Percentiles: []float64{math.NaN()}}},
+		{"infinite percentile", ExamStatsQuery{Percentiles: []float64{math.Inf(1)}}},
+		{"negative percentile", ExamStatsQuery{Percentiles: []float64{-1}}},
+		{"NaN width", ExamStatsQuery{BucketWidth: math.NaN()}},
+		{"infinite width", ExamStatsQuery{BucketWidth: math.Inf(1)}},
+		{"negative width", ExamStatsQuery{BucketWidth: -0.1}},
+		{"too many buckets", ExamStatsQuery{BucketWidth: 0.0001}},
+		{"overflowing buckets", ExamStatsQuery{BucketWidth: math.SmallestNonzeroFloat64}},
+	}
+
+	for _, tt := range tests {
+		if _, err := store.GetExamStats(1, tt.query); !errors.Is(err, ErrInvalidStatsQuery) {
+			t.Errorf("%s: Expected ErrInvalidStatsQuery, got %v", tt.name, err)
+		}
+	}
+}
+
+// The helpers stay in bounds even when called without a validated query
+func TestExamScores_GuardsInputs(t *testing.T) {
+	store := NewMemoryStore()
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.2})
+	store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student2", Score: 0.8})
+	index := store.exams[1]
+
+	for _, p := range []float64{math.NaN(), math.Inf(-1), -5} {
+		if got := index.percentile(p); got != 0.2 {
+			t.Errorf("Expected percentile %v to be the lowest score, got %v", p, got)
+		}
+	}
+	if got := index.percentile(math.Inf(1)); got != 0.8 {
+		t.Errorf("Expected infinite percentile to be the highest score, got %v", got)
+	}
+
+	for _, width := range []float64{math.NaN(), math.Inf(1), 0, -1, 1e-9, math.SmallestNonzeroFloat64} {
+		if buckets := index.histogram(width); buckets != nil {
+			t.Errorf("Expected no histogram for width %v, got %d buckets", width, len(buckets))
+		}
+	}
+}
+
+func TestMemoryStore_GetExamStats_Rescore(t *testing.T) {
+	tests := []struct {
+		policy   ScorePolicy
+		expected []float64 // sorted effective scores
+	}{
+		{PolicyLatest, []float64{0.4, 0.6}},
+		{PolicyBest, []float64{0.6, 0.9}},
+		{PolicyFirst, []float64{0.6, 0.9}},
+		{PolicyAverage, []float64{0.6, 0.65}},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryStore(WithScorePolicy(tt.policy))
+		store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.9})
+		store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student2", Score: 0.6})
+		store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "student1", Score: 0.4})
+
+		stats, _ := store.GetExamStats(1, ExamStatsQuery{})
+		if stats.Count != 2 {
+			t.Errorf("%s: Expected count 2, got %d", tt.policy, stats.Count)
+		}
+		if !approxEqual(stats.Min, tt.expected[0]) || !approxEqual(stats.Max, tt.expected[1]) {
+			t.Errorf("%s: Expected range %v, got %v-%v", tt.policy, tt.expected, stats.Min, stats.Max)
+		}
+		if expectedMean := (tt.expected[0] + tt.expected[1]) / 2; !approxEqual(stats.Mean, expectedMean) {
+			t.Errorf("%s: Expected mean %v, got %v", tt.policy, expectedMean, stats.Mean)
+		}
+	}
+}
+
+func TestGetExamStats_StandardDeviationIsStable(t *testing.T) {
+	// Squaring scores this far from 0 leaves too few bits for a spread of
+	// 0.1, so the variance must come from differences to the mean
+	const offset = 1e6
+
+	for _, store := range []Store{NewMemoryStore(), NewShardedStore(4)} {
+		for i, score := range []float64{0.5, 0.9, 0.7, 0.6, 0.2} {
+			store.AddScore(models.ScoreEvent{Exam: 1, StudentID: string(rune('a' + i)), Score: offset + score})
+		}
+		// A rescore replaces e's 0.2 with 0.8
+		store.AddScore(models.ScoreEvent{Exam: 1, StudentID: "e", Score: offset + 0.8})
+
+		stats, err := store.GetExamStats(1, ExamStatsQuery{})
+		if err != nil {
+			t.Fatalf("GetExamStats failed: %v", err)
+		}
+		if math.Abs(stats.StandardDeviation-math.Sqrt(0.02)) > 1e-6 {
+			t.Errorf("%T: Expected standard deviation %v, got %v", store, math.Sqrt(0.02), stats.StandardDeviation)
+		}
+	}
+}
@@ -2,10 +2,35 @@ package store
 
 import "channel-test/pkg/models"
 
+// ScoreHook is called with every score added to a store
+type ScoreHook func(record models.ScoreRecord)
+
+// Stats summarizes the size of a store
+type Stats struct {
+	Students int `json:"students"`
+	Exams    int `json:"exams"`
+	Scores   int `json:"scores"` // every received score, including rescores
+
+	// Duplicates counts scores ignored because they were already received
+	Duplicates int `json:"duplicates"`
+}
+
+// HealthChecker is implemented by stores that can become unable to
+// accept writes, such as a FileStore that has been closed
+type HealthChecker interface {
+	// Check returns nil if the store can accept writes
+	Check() error
+}
+
 // Store defines the interface for storing and retrieving test scores
 type Store interface {
-	// AddScore adds a new score event to the store
-	AddScore(event models.ScoreEvent) error
+	// AddScore adds a new score event to the store. It reports whether the
+	// store changed, which is false when the event is ignored as a duplicate.
+	AddScore(event models.ScoreEvent) (bool, error)
+
+	// OnScore registers a hook that runs synchronously after each
+	// AddScore has stored its record
+	OnScore(hook ScoreHook)
 
 	// GetAllStudents returns a list of all student IDs
 	GetAllStudents() []string
@@ -18,4 +43,28 @@ type Store interface {
 
 	// GetExam returns detailed information about a specific exam
 	GetExam(number int) (*models.Exam, error)
+
+	// GetExamStats returns the distribution of scores on an exam
+	GetExamStats(number int, q ExamStatsQuery) (*models.ExamStats, error)
+
+	// GetExamLeaderboard returns the top students on an exam, highest
+	// score first. A top of 0 returns everyone.
+	GetExamLeaderboard(number int, top int) ([]models.RankedScore, error)
+
+	// GetLeaderboard returns the top students by overall average among
+	// those who took at least minExams exams. A top of 0 returns everyone.
+	GetLeaderboard(top int, minExams int) []models.RankedStudent
+
+	// QueryStudents returns a filtered, sorted page of student summaries
+	QueryStudents(q StudentQuery) (*StudentPage, error)
+
+	// QueryExams returns a filtered, sorted page of exam summaries
+	QueryExams(q ExamQuery) (*ExamPage, error)
+
+	// GetScoreHistory returns every score received for a student on an
+	// exam, oldest first
+	GetScoreHistory(studentID string, exam int) ([]models.ScoreRecord, error)
+
+	// Stats returns the number of students, exams and scores stored
+	Stats() Stats
 }
@@ -0,0 +1,222 @@
+package stream
+
+import (
+	"channel-test/pkg/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReplaySize       = 1000
+	defaultSubscriberBuffer = 64
+	defaultHeartbeat        = 15 * time.Second
+)
+
+// Event is a stored score as published to subscribers
+type Event struct {
+	ID     uint64
+	Record models.ScoreRecord
+}
+
+// Filter selects which events a subscriber receives
+type Filter func(models.ScoreRecord) bool
+
+// AllScores is a Filter that accepts every event
+func AllScores(models.ScoreRecord) bool { return true }
+
+// StudentFilter accepts events for one student
+func StudentFilter(studentID string) Filter {
+	return func(r models.ScoreRecord) bool { return r.StudentID == studentID }
+}
+
+// ExamFilter accepts events for one exam
+func ExamFilter(exam int) Filter {
+	return func(r models.ScoreRecord) bool { return r.Exam == exam }
+}
+
+// Subscription delivers events to one subscriber. C is closed when the
+// subscriber is evicted for falling behind or the subscription is closed.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	filter Filter
+	b      *Broadcaster
+}
+
+// Close unsubscribes and releases the subscription
+func (s *Subscription) Close() {
+	s.b.remove(s)
+}
+
+// Broadcaster fans stored scores out to subscribers and keeps the most
+// recent events in a ring buffer so reconnecting clients can catch up
+type Broadcaster struct {
+	mu          sync.Mutex
+	nextID      uint64
+	ring        []Event
+	start       int // index of the oldest event in ring
+	size        int // number of events in ring
+	subscribers map[*Subscription]struct{}
+	evicted     uint64
+	closed      bool
+
+	subscriberBuffer int
+	heartbeat        time.Duration
+}
+
+// Option configures a Broadcaster
+type Option func(*Broadcaster)
+
+// WithReplaySize sets how many recent events are kept for Last-Event-ID replay
+func WithReplaySize(n int) Option {
+	return func(b *Broadcaster) {
+		b.ring = make([]Event, n)
+	}
+}
+
+// WithSubscriberBuffer sets how many events may queue for a subscriber
+// before it is considered too slow and evicted
+func WithSubscriberBuffer(n int) Option {
+	return func(b *Broadcaster) {
+		b.subscriberBuffer = n
+	}
+}
+
+// WithHeartbeat sets the interval between keep-alive comments sent to
+// idle subscribers
+func WithHeartbeat(d time.Duration) Option {
+	return func(b *Broadcaster) {
+		b.heartbeat = d
+	}
+}
+
+// NewBroadcaster creates a new broadcaster
+func NewBroadcaster(opts ...Option) *Broadcaster {
+	b := &Broadcaster{
+		nextID:           1,
+		ring:             make([]Event, defaultReplaySize),
+		subscribers:      make(map[*Subscription]struct{}),
+		subscriberBuffer: defaultSubscriberBuffer,
+		heartbeat:        defaultHeartbeat,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Publish assigns the record the next event ID, stores it for replay and
+// delivers it to every matching subscriber without blocking. Subscribers
+// whose buffer is full are evicted.
+func (b *Broadcaster) Publish(record models.ScoreRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{ID: b.nextID, Record: record}
+	b.nextID++
+
+	if len(b.ring) > 0 {
+		if b.size < len(b.ring) {
+			b.ring[(b.start+b.size)%len(b.ring)] = event
+			b.size++
+		} else {
+			b.ring[b.start] = event
+			b.start = (b.start + 1) % len(b.ring)
+		}
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter(record) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			b.evict(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for events matching filter. If
+// lastEventID is set, buffered events after it are returned for replay;
+// an ID the buffer no longer holds (or never held, such as one from
+// before a restart) replays the whole buffer.
+func (b *Broadcaster) Subscribe(filter Filter, lastEventID string) (*Subscription, []Event) {
+	ch := make(chan Event, b.subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, b: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if lastEventID != "" {
+		after, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil || after >= b.nextID {
+			after = 0
+		}
+		for i := 0; i < b.size; i++ {
+			event := b.ring[(b.start+i)%len(b.ring)]
+			if event.ID > after && filter(event.Record) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	if b.closed {
+		close(ch)
+		return sub, replay
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub, replay
+}
+
+// Close ends every subscription so streaming responses finish, letting
+// the HTTP server shut down. Later subscriptions are closed immediately.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+	b.closed = true
+}
+
+// Subscribers returns the number of active subscribers
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+// Evicted returns the number of subscribers dropped for falling behind
+func (b *Broadcaster) Evicted() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.evicted
+}
+
+func (b *Broadcaster) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// evict drops a slow subscriber. The caller must hold b.mu.
+func (b *Broadcaster) evict(sub *Subscription) {
+	delete(b.subscribers, sub)
+	close(sub.ch)
+	b.evicted++
+}
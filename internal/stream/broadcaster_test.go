@@ -0,0 +1,156 @@
+package stream
+
+import (
+	"channel-test/internal/consumer"
+	"channel-test/pkg/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func record(studentID string, exam int) models.ScoreRecord {
+	return models.ScoreRecord{StudentID: studentID, Exam: exam, Score: 0.5}
+}
+
+func TestBroadcaster_PublishFiltersSubscribers(t *testing.T) {
+	b := NewBroadcaster()
+
+	all, _ := b.Subscribe(AllScores, "")
+	alice, _ := b.Subscribe(StudentFilter("alice"), "")
+	exam2, _ := b.Subscribe(ExamFilter(2), "")
+
+	b.Publish(record("alice", 1))
+	b.Publish(record("bob", 2))
+
+	if len(all.C) != 2 {
+		t.Errorf("Expected 2 events for all scores, got %d", len(all.C))
+	}
+	if len(alice.C) != 1 {
+		t.Errorf("Expected 1 event for alice, got %d", len(alice.C))
+	}
+	if event := <-exam2.C; event.ID != 2 || event.Record.StudentID != "bob" {
+		t.Errorf("Expected event 2 for bob, got %+v", event)
+	}
+}
+
+func TestBroadcaster_Replay(t *testing.T) {
+	b := NewBroadcaster(WithReplaySize(3))
+	for i := 1; i <= 5; i++ {
+		b.Publish(record("alice", i))
+	}
+
+	tests := []struct {
+		lastEventID string
+		expected    []uint64
+	}{
+		{"", nil},
+		{"4", []uint64{5}},
+		{"3", []uint64{4, 5}},
+		{"1", []uint64{3, 4, 5}},  // older than the buffer
+		{"99", []uint64{3, 4, 5}}, // from before a restart
+		{"abc", []uint64{3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		sub, replay := b.Subscribe(AllScores, tt.lastEventID)
+		sub.Close()
+
+		if len(replay) != len(tt.expected) {
+			t.Errorf("Last-Event-ID %q: expected %d events, got %d", tt.lastEventID, len(tt.expected), len(replay))
+			continue
+		}
+		for i, event := range replay {
+			if event.ID != tt.expected[i] {
+				t.Errorf("Last-Event-ID %q: expected event %d at %d, got %d", tt.lastEventID, tt.expected[i], i, event.ID)
+			}
+		}
+	}
+}
+
+func TestBroadcaster_EvictsSlowSubscriber(t *testing.T) {
+	b := NewBroadcaster(WithSubscriberBuffer(2))
+
+	slow, _ := b.Subscribe(AllScores, "")
+	for i := 0; i < 3; i++ {
+		b.Publish(record("alice", i))
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("Expected 2 buffered events before eviction, got %d", received)
+	}
+	if b.Subscribers() != 0 {
+		t.Errorf("Expected no subscribers after eviction, got %d", b.Subscribers())
+	}
+	if b.Evicted() != 1 {
+		t.Errorf("Expected 1 eviction, got %d", b.Evicted())
+	}
+
+	// Closing an evicted subscription is harmless
+	slow.Close()
+}
+
+func TestBroadcaster_Serve(t *testing.T) {
+	b := NewBroadcaster(WithHeartbeat(10 * time.Millisecond))
+	b.Publish(record("alice", 1))
+	b.Publish(record("bob", 1))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Serve(w, r, StudentFilter("alice"))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", ct)
+	}
+
+	decoder := consumer.NewDecoder(resp.Body)
+
+	// Replayed event
+	event, err := decoder.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if event.ID != "1" || event.Type != "score" {
+		t.Errorf("Expected replayed score event 1, got %+v", event)
+	}
+
+	// Live event
+	b.Publish(record("bob", 2))
+	b.Publish(record("alice", 2))
+
+	event, err = decoder.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	var got models.ScoreRecord
+	if err := json.Unmarshal([]byte(event.Data), &got); err != nil {
+		t.Fatalf("Failed to decode event data: %v", err)
+	}
+	if event.ID != "4" || got.StudentID != "alice" || got.Exam != 2 {
+		t.Errorf("Expected live event 4 for alice exam 2, got %s %+v", event.ID, got)
+	}
+
+	// Closing the broadcaster ends the stream
+	b.Close()
+	if _, err := decoder.Decode(); err == nil {
+		t.Error("Expected the stream to end after Close")
+	}
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config selects and parameterises the rules of a validator. It is read
+// from a JSON file such as
+//
+//	{
+//	  "examRanges": ["1-100", "200"],
+//	  "studentIdPattern": "^[A-Za-z]+\\.[A-Za-z]+[0-9]*$",
+//	  "scoreDecimals": 4,
+//	  "scoreScale": {"min": 0, "max": 1},
+//	  "examScales": {"7": {"min": 0, "max": 100}},
+//	  "rejectDecreases": true,
+//	  "duplicateWindow": "5m"
+//	}
+//
+// Every field is optional; omitted fields disable their rule, except
+// scoreScale, which defaults to [0,1].
+type Config struct {
+	ExamRanges       []string         `json:"examRanges"`
+	StudentIDPattern string           `json:"studentIdPattern"`
+	ScoreDecimals    *int             `json:"scoreDecimals"`
+	ScoreScale       *Scale           `json:"scoreScale"`
+	ExamScales       map[string]Scale `json:"examScales"`
+	RejectDecreases  bool             `json:"rejectDecreases"`
+	DuplicateWindow  string           `json:"duplicateWindow"`
+}
+
+// LoadConfig reads rules from the JSON file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation rules: %w", err)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig reads rules from JSON, rejecting unknown fields
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid validation rules: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Build creates the rule chain described by the config. Rules that compare
+// with earlier scores use history and are skipped when it is nil. All
+// invalid settings are reported together.
+func (c *Config) Build(history History) (Validator, error) {
+	var chain Chain
+	var errs []error
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if len(c.ExamRanges) > 0 {
+		var ranges ExamRange
+		for _, raw := range c.ExamRanges {
+			r, err := parseRange(raw)
+			if err != nil {
+				invalid("examRanges", "%v", err)
+				continue
+			}
+			ranges = append(ranges, r)
+		}
+		chain = append(chain, ranges)
+	}
+
+	if c.StudentIDPattern != "" {
+		pattern, err := regexp.Compile(c.StudentIDPattern)
+		if err != nil {
+			invalid("studentIdPattern", "%v", err)
+		} else {
+			chain = append(chain, StudentIDPattern{Pattern: pattern})
+		}
+	}
+
+	if c.ScoreDecimals != nil {
+		if *c.ScoreDecimals < 0 || *c.ScoreDecimals > 15 {
+			invalid("scoreDecimals", "must be between 0 and 15, got %d", *c.ScoreDecimals)
+		} else {
+			chain = append(chain, ScorePrecision{Decimals: *c.ScoreDecimals})
+		}
+	}
+
+	scale := ScoreScale{Default: UnitScale, Exams: make(map[int]Scale)}
+	if c.ScoreScale != nil {
+		scale.Default = *c.ScoreScale
+		if err := checkScale(scale.Default); err != nil {
+			invalid("scoreScale", "%v", err)
+		}
+	}
+	exams := make([]string, 0, len(c.ExamScales))
+	for exam := range c.ExamScales {
+		exams = append(exams, exam)
+	}
+	sort.Strings(exams)
+	for _, raw := range exams {
+		exam, err := strconv.Atoi(raw)
+		if err != nil {
+			invalid("examScales", "invalid exam number %q", raw)
+			continue
+		}
+		if err := checkScale(c.ExamScales[raw]); err != nil {
+			invalid("examScales", "exam %d: %v", exam, err)
+			continue
+		}
+		scale.Exams[exam] = c.ExamScales[raw]
+	}
+	chain = append(chain, scale)
+
+	if c.RejectDecreases && history != nil {
+		chain = append(chain, NoDecrease{History: history})
+	}
+
+	if c.DuplicateWindow != "" {
+		window, err := time.ParseDuration(c.DuplicateWindow)
+		if err != nil || window <= 0 {
+			invalid("duplicateWindow", "must be a positive duration, got %q", c.DuplicateWindow)
+		} else if history != nil {
+			chain = append(chain, DuplicateWindow{History: history, Window: window})
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// parseRange reads "7", "1-100" or "-1..5". A "-" after the first
+// character separates the bounds, so "-5--1" is also read.
+func parseRange(raw string) (Range, error) {
+	s := strings.TrimSpace(raw)
+	lo, hi, isRange := strings.Cut(s, "..")
+	if !isRange && len(s) > 1 {
+		if i := strings.Index(s[1:], "-"); i >= 0 {
+			lo, hi, isRange = s[:i+1], s[i+2:], true
+		}
+	}
+	if !isRange {
+		hi = lo
+	}
+
+	min, err1 := strconv.Atoi(strings.TrimSpace(lo))
+	max, err2 := strconv.Atoi(strings.TrimSpace(hi))
+	if err1 != nil || err2 != nil {
+		return Range{}, fmt.Errorf("invalid exam range %q: want an exam number, or two joined by \"-\" or \"..\"", raw)
+	}
+	if min > max {
+		return Range{}, fmt.Errorf("invalid exam range %q: %d is above %d", raw, min, max)
+	}
+	return Range{Min: min, Max: max}, nil
+}
+
+func checkScale(s Scale) error {
+	if s.Min >= s.Max {
+		return fmt.Errorf("min %g must be below max %g", s.Min, s.Max)
+	}
+	return nil
+}
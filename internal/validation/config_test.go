@@ -0,0 +1,118 @@
+package validation
+
+import (
+	"channel-test/pkg/models"
+	"strings"
+	"testing"
+)
+
+func TestConfig_Build(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{
+		"examRanges": ["1-10", "20"],
+		"studentIdPattern": "^[a-z]+$",
+		"scoreDecimals": 2,
+		"examScales": {"7": {"min": 0, "max": 100}},
+		"rejectDecreases": true,
+		"duplicateWindow": "5m"
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	validator, err := cfg.Build(fakeHistory{{Score: 50}})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		event    models.ScoreEvent
+		expected []string
+	}{
+		{"valid", models.ScoreEvent{Exam: 7, StudentID: "alice", Score: 87.5}, nil},
+		{"default scale still applies", models.ScoreEvent{Exam: 3, StudentID: "alice", Score: 87.5}, []string{ReasonScoreOutOfRange}},
+		{"several rules", models.ScoreEvent{Exam: 15, StudentID: "Bob", Score: 60.125}, []string{ReasonExamOutOfRange, ReasonInvalidStudentID, ReasonScorePrecision, ReasonScoreOutOfRange}},
+		{"decrease", models.ScoreEvent{Exam: 7, StudentID: "alice", Score: 40}, []string{ReasonScoreDecrease}},
+	}
+
+	for _, tt := range tests {
+		got := reasons(validator.Validate(tt.event))
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: Expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestConfig_BuildWithoutHistory(t *testing.T) {
+	cfg := &Config{RejectDecreases: true, DuplicateWindow: "1m"}
+
+	validator, err := cfg.Build(nil)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// Only the default scale is left
+	if chain, ok := validator.(Chain); !ok || len(chain) != 1 {
+		t.Errorf("Expected history rules to be skipped, got %#v", validator)
+	}
+}
+
+func TestConfig_Errors(t *testing.T) {
+	if _, err := ParseConfig([]byte(`{"examRange": ["1-10"]}`)); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+
+	decimals := -1
+	cfg := &Config{
+		ExamRanges:       []string{"10-1", "x"},
+		StudentIDPattern: "(",
+		ScoreDecimals:    &decimals,
+		ScoreScale:       &Scale{Min: 1, Max: 0},
+		ExamScales:       map[string]Scale{"x": {0, 1}},
+		DuplicateWindow:  "soon",
+	}
+
+	_, err := cfg.Build(nil)
+	if err == nil {
+		t.Fatal("Expected errors")
+	}
+
+	// Every problem is reported at once
+	for _, key := range []string{`"10-1"`, `"x"`, "studentIdPattern", "scoreDecimals", "scoreScale", "examScales", "duplicateWindow"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error to mention %s, got %v", key, err)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected Range
+		err      string
+	}{
+		{"7", Range{Min: 7, Max: 7}, ""},
+		{" 1 - 100 ", Range{Min: 1, Max: 100}, ""},
+		{"1..100", Range{Min: 1, Max: 100}, ""},
+		{"-3", Range{Min: -3, Max: -3}, ""},
+		{"-1..5", Range{Min: -1, Max: 5}, ""},
+		{"-5--1", Range{Min: -5, Max: -1}, ""},
+		{"-5..-1", Range{Min: -5, Max: -1}, ""},
+		{"10-1", Range{}, "10 is above 1"},
+		{"1-x", Range{}, "want an exam number"},
+		{"", Range{}, "want an exam number"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRange(tt.raw)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: Expected error containing %q, got %v", tt.raw, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("%q: Expected %+v, got %+v (%v)", tt.raw, tt.expected, got, err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"channel-test/pkg/models"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+// History gives rules that compare with earlier scores access to them.
+// store.Store implements it.
+type History interface {
+	GetScoreHistory(studentID string, exam int) ([]models.ScoreRecord, error)
+}
+
+// Range is an inclusive range of exam numbers
+type Range struct {
+	Min, Max int
+}
+
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprint(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// ExamRange accepts only exam numbers inside one of its ranges
+type ExamRange []Range
+
+// Validate implements Validator
+func (r ExamRange) Validate(event models.ScoreEvent) []Violation {
+	for _, allowed := range r {
+		if event.Exam >= allowed.Min && event.Exam <= allowed.Max {
+			return nil
+		}
+	}
+	return []Violation{{
+		Rule:    "examRanges",
+		Reason:  ReasonExamOutOfRange,
+		Message: fmt.Sprintf("exam %d is not in an allowed range %v", event.Exam, []Range(r)),
+	}}
+}
+
+// StudentIDPattern accepts only student IDs matching a regular expression
+type StudentIDPattern struct {
+	Pattern *regexp.Regexp
+}
+
+// Validate implements Validator
+func (p StudentIDPattern) Validate(event models.ScoreEvent) []Violation {
+	if p.Pattern.MatchString(event.StudentID) {
+		return nil
+	}
+	return []Violation{{
+		Rule:    "studentIdPattern",
+		Reason:  ReasonInvalidStudentID,
+		Message: fmt.Sprintf("student ID %q does not match %s", event.StudentID, p.Pattern),
+	}}
+}
+
+// ScorePrecision accepts scores with at most Decimals decimal places
+type ScorePrecision struct {
+	Decimals int
+}
+
+// Validate implements Validator
+func (p ScorePrecision) Validate(event models.ScoreEvent) []Violation {
+	scaled := event.Score * math.Pow10(p.Decimals)
+	if math.Abs(scaled-math.Round(scaled)) <= 1e-9*math.Max(1, math.Abs(scaled)) {
+		return nil
+	}
+	return []Violation{{
+		Rule:    "scoreDecimals",
+		Reason:  ReasonScorePrecision,
+		Message: fmt.Sprintf("score %v has more than %d decimal places", event.Score, p.Decimals),
+	}}
+}
+
+// Scale is the inclusive range scores on an exam are given in
+type Scale struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// UnitScale is the [0,1] scale scores are given in unless configured
+var UnitScale = Scale{Min: 0, Max: 1}
+
+// ScoreScale accepts scores inside the exam's scale, or Default for exams
+// without one
+type ScoreScale struct {
+	Default Scale
+	Exams   map[int]Scale
+}
+
+// Validate implements Validator
+func (s ScoreScale) Validate(event models.ScoreEvent) []Violation {
+	scale, ok := s.Exams[event.Exam]
+	if !ok {
+		scale = s.Default
+	}
+	if event.Score >= scale.Min && event.Score <= scale.Max {
+		return nil
+	}
+	return []Violation{{
+		Rule:    "scoreScale",
+		Reason:  ReasonScoreOutOfRange,
+		Message: fmt.Sprintf("score out of range [%g,%g]: %f", scale.Min, scale.Max, event.Score),
+	}}
+}
+
+// NoDecrease rejects a score lower than the student's latest score on the
+// same exam. It is best-effort: the history is read before the score is
+// stored, so two scores for the same student and exam validated at once,
+// from different sources or concurrent requests, can both pass. Events from
+// one source are validated in order.
+type NoDecrease struct {
+	History History
+}
+
+// Validate implements Validator
+func (n NoDecrease) Validate(event models.ScoreEvent) []Violation {
+	records, err := n.History.GetScoreHistory(event.StudentID, event.Exam)
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+
+	latest := records[len(records)-1]
+	if event.Score >= latest.Score {
+		return nil
+	}
+	return []Violation{{
+		Rule:    "rejectDecreases",
+		Reason:  ReasonScoreDecrease,
+		Message: fmt.Sprintf("score %v is lower than the latest score %v", event.Score, latest.Score),
+	}}
+}
+
+// DuplicateWindow rejects a score equal to one the student received on the
+// same exam within Window. Like NoDecrease it is best-effort when the same
+// score arrives concurrently; the store's event deduplication is the
+// guarantee for redelivered events.
+type DuplicateWindow struct {
+	History History
+	Window  time.Duration
+	Now     func() time.Time // defaults to time.Now
+}
+
+// Validate implements Validator
+func (d DuplicateWindow) Validate(event models.ScoreEvent) []Violation {
+	records, err := d.History.GetScoreHistory(event.StudentID, event.Exam)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	if d.Now != nil {
+		now = d.Now()
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if now.Sub(record.ReceivedAt) > d.Window {
+			break
+		}
+		if record.Score == event.Score {
+			return []Violation{{
+				Rule:    "duplicateWindow",
+				Reason:  ReasonDuplicate,
+				Message: fmt.Sprintf("score %v was already received %s ago", event.Score, now.Sub(record.ReceivedAt).Round(time.Millisecond)),
+			}}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package validation checks incoming score events against a configurable
+// chain of rules, reporting every rule an event breaks.
+package validation
+
+import (
+	"channel-test/pkg/models"
+	"fmt"
+	"strings"
+)
+
+// Reasons reported by the built-in rules, used as metrics labels
+const (
+	ReasonExamOutOfRange   = "exam_out_of_range"
+	ReasonInvalidStudentID = "invalid_student_id"
+	ReasonScorePrecision   = "score_precision"
+	ReasonScoreOutOfRange  = "score_out_of_range"
+	ReasonScoreDecrease    = "score_decrease"
+	ReasonDuplicate        = "duplicate"
+)
+
+// Violation describes one rule a score event breaks
+type Violation struct {
+	Rule    string `json:"rule"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+// Validator checks a decoded score event, returning no violations if the
+// event may be stored
+type Validator interface {
+	Validate(event models.ScoreEvent) []Violation
+}
+
+// Chain runs every validator in order and collects all of their violations
+type Chain []Validator
+
+// Validate implements Validator
+func (c Chain) Validate(event models.ScoreEvent) []Violation {
+	var violations []Violation
+	for _, v := range c {
+		violations = append(violations, v.Validate(event)...)
+	}
+	return violations
+}
+
+// Default returns the validator used when no rules are configured: scores
+// must lie in [0,1]
+func Default() Validator {
+	return Chain{ScoreScale{Default: UnitScale}}
+}
+
+// Summary joins the messages of violations into one line
+func Summary(violations []Violation) string {
+	messages := make([]string, len(violations))
+	for i, v := range violations {
+		messages[i] = v.Message
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"channel-test/pkg/models"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// fakeHistory returns fixed records for every student and exam
+type fakeHistory []models.ScoreRecord
+
+func (f fakeHistory) GetScoreHistory(studentID string, exam int) ([]models.ScoreRecord, error) {
+	return f, nil
+}
+
+func reasons(violations []Violation) []string {
+	var out []string
+	for _, v := range violations {
+		out = append(out, v.Reason)
+	}
+	return out
+}
+
+func TestRules(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	history := fakeHistory{
+		{Score: 0.8, ReceivedAt: now.Add(-time.Hour)},
+		{Score: 0.6, ReceivedAt: now.Add(-time.Minute)},
+	}
+
+	tests := []struct {
+		name      string
+		validator Validator
+		event     models.ScoreEvent
+		expected  string
+	}{
+		{"exam in range", ExamRange{{1, 10}, {20, 20}}, models.ScoreEvent{Exam: 20}, ""},
+		{"exam out of range", ExamRange{{1, 10}, {20, 20}}, models.ScoreEvent{Exam: 11}, ReasonExamOutOfRange},
+		{"student ID matches", StudentIDPattern{regexp.MustCompile(`^[a-z]+$`)}, models.ScoreEvent{StudentID: "alice"}, ""},
+		{"student ID mismatch", StudentIDPattern{regexp.MustCompile(`^[a-z]+$`)}, models.ScoreEvent{StudentID: "Alice1"}, ReasonInvalidStudentID},
+		{"precision ok", ScorePrecision{2}, models.ScoreEvent{Score: 0.29}, ""},
+		{"precision integer", ScorePrecision{0}, models.ScoreEvent{Score: 87}, ""},
+		{"precision exceeded", ScorePrecision{2}, models.ScoreEvent{Score: 0.291}, ReasonScorePrecision},
+		{"default scale", ScoreScale{Default: UnitScale}, models.ScoreEvent{Score: 1}, ""},
+		{"outside default scale", ScoreScale{Default: UnitScale}, models.ScoreEvent{Score: 1.01}, ReasonScoreOutOfRange},
+		{"exam scale", ScoreScale{Default: UnitScale, Exams: map[int]Scale{7: {0, 100}}}, models.ScoreEvent{Exam: 7, Score: 87}, ""},
+		{"outside exam scale", ScoreScale{Default: UnitScale, Exams: map[int]Scale{7: {0, 100}}}, models.ScoreEvent{Exam: 7, Score: -1}, ReasonScoreOutOfRange},
+		{"no decrease from latest", NoDecrease{history}, models.ScoreEvent{Score: 0.6}, ""},
+		{"decrease", NoDecrease{history}, models.ScoreEvent{Score: 0.5}, ReasonScoreDecrease},
+		{"no history", NoDecrease{fakeHistory{}}, models.ScoreEvent{Score: 0.1}, ""},
+		{"duplicate in window", DuplicateWindow{history, 5 * time.Minute, func() time.Time { return now }}, models.ScoreEvent{Score: 0.6}, ReasonDuplicate},
+		{"duplicate outside window", DuplicateWindow{history, 5 * time.Minute, func() time.Time { return now }}, models.ScoreEvent{Score: 0.8}, ""},
+		{"different score in window", DuplicateWindow{history, 5 * time.Minute, func() time.Time { return now }}, models.ScoreEvent{Score: 0.7}, ""},
+	}
+
+	for _, tt := range tests {
+		violations := tt.validator.Validate(tt.event)
+		got := ""
+		if len(violations) > 0 {
+			got = violations[0].Reason
+		}
+		if len(violations) > 1 || got != tt.expected {
+			t.Errorf("%s: Expected %q, got %v", tt.name, tt.expected, violations)
+		}
+	}
+}
+
+func TestChain_CollectsAllViolations(t *testing.T) {
+	chain := Chain{
+		ExamRange{{1, 10}},
+		StudentIDPattern{regexp.MustCompile(`^[a-z]+$`)},
+		ScoreScale{Default: UnitScale},
+	}
+
+	violations := chain.Validate(models.ScoreEvent{Exam: 99, StudentID: "Alice", Score: 2})
+	got := reasons(violations)
+	if len(got) != 3 || got[0] != ReasonExamOutOfRange || got[1] != ReasonInvalidStudentID || got[2] != ReasonScoreOutOfRange {
+		t.Errorf("Expected three violations in rule order, got %v", got)
+	}
+
+	if violations := chain.Validate(models.ScoreEvent{Exam: 3, StudentID: "alice", Score: 0.5}); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+}
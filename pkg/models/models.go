@@ -7,13 +7,42 @@ type ScoreEvent struct {
 	Exam      int     `json:"exam"`
 	StudentID string  `json:"studentId"`
 	Score     float64 `json:"score"`
+
+	// ID identifies the event so redeliveries can be ignored. Ingesters
+	// fill it from the transport, such as the SSE id field, when set.
+	ID string `json:"id,omitempty"`
+
+	// Source names where the event was received from; it is set by the
+	// ingesting component, not by the sender
+	Source string `json:"-"`
+
+	// ReceivedAt is when the event was first received, set by ingesters
+	// that backfill recorded events. Stores use the current time when it
+	// is zero.
+	ReceivedAt time.Time `json:"-"`
+}
+
+// ScoreRecord is an immutable history entry for one received score
+type ScoreRecord struct {
+	Exam       int       `json:"exam"`
+	StudentID  string    `json:"studentId"`
+	Score      float64   `json:"score"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	Source     string    `json:"source,omitempty"`
+	EventID    string    `json:"eventId,omitempty"`
 }
 
 // StudentScore represents a single test score for a student
 type StudentScore struct {
 	Exam      int       `json:"exam"`
 	Score     float64   `json:"score"`
+	Attempts  int       `json:"attempts"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// Rank is the student's 1-based position on the exam's leaderboard and
+	// Percentile places it on a 0-100 scale where 100 is the top
+	Rank       int     `json:"rank"`
+	Percentile float64 `json:"percentile"`
 }
 
 // Student represents a student with all their scores
@@ -21,6 +50,10 @@ type Student struct {
 	ID           string         `json:"id"`
 	Scores       []StudentScore `json:"scores"`
 	AverageScore float64        `json:"averageScore"`
+
+	// Rank and Percentile place the student on the overall leaderboard
+	Rank       int     `json:"rank"`
+	Percentile float64 `json:"percentile"`
 }
 
 // ExamResult represents a single student's result on an exam
@@ -35,3 +68,52 @@ type Exam struct {
 	Results      []ExamResult `json:"results"`
 	AverageScore float64      `json:"averageScore"`
 }
+
+// StudentSummary is a student's aggregate figures used when listing students
+type StudentSummary struct {
+	ID           string  `json:"id"`
+	AverageScore float64 `json:"averageScore"`
+	ExamCount    int     `json:"examCount"`
+}
+
+// ExamSummary is an exam's aggregate figures used when listing exams
+type ExamSummary struct {
+	Number       int     `json:"number"`
+	AverageScore float64 `json:"averageScore"`
+	StudentCount int     `json:"studentCount"`
+}
+
+// RankedScore is one entry on an exam's leaderboard
+type RankedScore struct {
+	Rank      int     `json:"rank"`
+	StudentID string  `json:"studentId"`
+	Score     float64 `json:"score"`
+}
+
+// RankedStudent is one entry on the overall leaderboard
+type RankedStudent struct {
+	Rank         int     `json:"rank"`
+	StudentID    string  `json:"studentId"`
+	AverageScore float64 `json:"averageScore"`
+	ExamCount    int     `json:"examCount"`
+}
+
+// ExamStats describes the distribution of scores on an exam
+type ExamStats struct {
+	Number            int                `json:"number"`
+	Count             int                `json:"count"`
+	Min               float64            `json:"min"`
+	Max               float64            `json:"max"`
+	Mean              float64            `json:"mean"`
+	Median            float64            `json:"median"`
+	StandardDeviation float64            `json:"standardDeviation"`
+	Percentiles       map[string]float64 `json:"percentiles"` // keyed p10, p90, ...
+	Histogram         []HistogramBucket  `json:"histogram"`
+}
+
+// HistogramBucket counts scores from Lower up to, but not including, Upper
+type HistogramBucket struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}